@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"log"
 	"os"
@@ -31,11 +32,40 @@ func loadConfig() (system.Config, error) {
 	keysToBind := []string{
 		"http.client.baseurl",
 		"http.client.lang",
+		"http.client.requesttimeout",
+		"http.client.retry.maxattempts",
+		"http.client.retry.initialbackoff",
+		"http.client.retry.maxbackoff",
+		"http.client.circuitbreaker.failurethreshold",
+		"http.client.circuitbreaker.opentimeout",
+		"http.client.ratelimit.requestspersecond",
+		"http.client.ratelimit.burst",
+		"http.client.ratelimit.maxconcurrency",
+		"http.storefronts",
+		"http.defaultstorefront",
 		"watch.updateinterval",
+		"watch.mininterval",
+		"watch.maxinterval",
+		"watch.concurrency",
+		"watch.jitter",
+		"watch.notfoundthreshold",
+		"watch.timezone",
+		"watch.pollingwindow.start",
+		"watch.pollingwindow.end",
 		"bot.allowedusers",
 		"bot.token",
+		"bot.timezone",
+		"bot.quiethours",
 		"storage.driver",
 		"storage.options",
+		"outbox.pollinterval",
+		"outbox.initialbackoff",
+		"outbox.maxbackoff",
+		"outbox.maxattempts",
+		"outbox.deliveredretention",
+		"history.retention",
+		"history.cleanupinterval",
+		"metrics.listen",
 	}
 	if err := func(keys []string) error {
 		for _, k := range keys {
@@ -72,7 +102,10 @@ func loadConfig() (system.Config, error) {
 func runSystem(config system.Config) int {
 	system := system.New(config)
 
-	if err := system.Start(); err != nil {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := system.Start(ctx); err != nil {
 		log.Fatal(err)
 	}
 
@@ -93,7 +126,7 @@ func runSystem(config system.Config) int {
 				log.Println("Performing graceful shutdown. Press Ctrl+C again to force.")
 				gracefulShutdownInProgress = true
 
-				system.Stop()
+				cancel()
 			}
 
 		case <-system.StoppedCh():
@@ -6,39 +6,42 @@ import (
 	"net/url"
 	"strings"
 
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next"
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
 )
 
-func parseArticleByLink(l string) (string, error) {
+func parseArticleByLink(l string) (string, string, error) {
 	parsedURL, err := url.Parse(l)
 
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	if !parsedURL.IsAbs() {
-		return "", errors.New("only absolute URLs are supported")
+		return "", "", errors.New("only absolute URLs are supported")
 	}
 
 	if parsedURL.Scheme != "https" {
-		return "", errors.New("only https:// scheme is supported")
+		return "", "", errors.New("only https:// scheme is supported")
 	}
 
 	pathComponents := strings.Split(parsedURL.Path, "/")
 	article := shop.NormalizeArticle(pathComponents[len(pathComponents)-1])
 	if len(article) == 6 {
-		return article, nil
+		return article, next.StorefrontIDFromHost(parsedURL.Host), nil
 	}
 
-	return "", errors.New("cannot extract article from the link")
+	return "", "", errors.New("cannot extract article from the link")
 }
 
-// ParseStringWithArticle parses a string and extracts the article number
+// ParseStringWithArticle parses a string and extracts the article number and the storefront
 //
 // Current implementation supports the following formats:
 //	1. Raw article number: 111222, 111-222, 111_222
 //	2. Link to shop item page: https://www.domain.com/.../111222
-func ParseStringWithArticle(msg string) (string, error) {
+//
+// The storefront is detected from the link domain (www.next.co.uk -> uk) and is empty for raw article numbers.
+func ParseStringWithArticle(msg string) (string, string, error) {
 	if strings.HasPrefix(msg, "http") {
 		return parseArticleByLink(msg)
 	}
@@ -46,8 +49,11 @@ func ParseStringWithArticle(msg string) (string, error) {
 	article := shop.NormalizeArticle(msg)
 	// TODO: move parsing+validation logic to separate data type Article
 	if len(article) == 6 {
-		return article, nil
+		return article, "", nil
 	}
 
-	return "", fmt.Errorf("unknown article format <%s>. Use article number '111-222', '111222' or link to item page", msg)
+	return "", "", fmt.Errorf(
+		"unknown article format <%s>. Use article number '111-222', '111222' or link to item page",
+		msg,
+	)
 }
@@ -8,9 +8,10 @@ import (
 
 func TestParseArticle(t *testing.T) {
 	tests := []struct {
-		name     string
-		input    string
-		expected string
+		name               string
+		input              string
+		expected           string
+		expectedStorefront string
 	}{
 		{
 			name:     "Raw article",
@@ -23,28 +24,44 @@ func TestParseArticle(t *testing.T) {
 			expected: "091291",
 		},
 		{
-			name:     "Link to item page",
-			input:    "https://www.domain.com/uk/collections/spring/134857",
-			expected: "134857",
+			name:               "Link to item page",
+			input:              "https://www.domain.com/uk/collections/spring/134857",
+			expected:           "134857",
+			expectedStorefront: "com",
 		},
 		{
-			name:     "Link to item page with fragment",
-			input:    "https://www.domain.com/uk/collections/spring/234857#anchor",
-			expected: "234857",
+			name:               "Link to item page with fragment",
+			input:              "https://www.domain.com/uk/collections/spring/234857#anchor",
+			expected:           "234857",
+			expectedStorefront: "com",
 		},
 		{
-			name:     "Link to item page with fragment and query",
-			input:    "https://www.domain.com/uk/collections/spring/934813?sort=asc#anchor",
-			expected: "934813",
+			name:               "Link to item page with fragment and query",
+			input:              "https://www.domain.com/uk/collections/spring/934813?sort=asc#anchor",
+			expected:           "934813",
+			expectedStorefront: "com",
+		},
+		{
+			name:               "Link to UK storefront",
+			input:              "https://www.next.co.uk/style/st123456/934813",
+			expected:           "934813",
+			expectedStorefront: "uk",
+		},
+		{
+			name:               "Link to UA storefront",
+			input:              "https://www.next.ua/ru/style/st123456/934813",
+			expected:           "934813",
+			expectedStorefront: "ua",
 		},
 	}
 
 	for _, test := range tests {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
-			article, err := ParseStringWithArticle(test.input)
+			article, storefront, err := ParseStringWithArticle(test.input)
 			assert.NoError(t, err)
 			assert.Equal(t, test.expected, article)
+			assert.Equal(t, test.expectedStorefront, storefront)
 		})
 	}
 }
@@ -79,9 +96,10 @@ func TestParseArticle_returnErrorOnUnsupportedFormats(t *testing.T) {
 	for _, test := range tests {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
-			article, err := ParseStringWithArticle(test.input)
+			article, storefront, err := ParseStringWithArticle(test.input)
 			assert.Error(t, err)
 			assert.Equal(t, "", article)
+			assert.Equal(t, "", storefront)
 		})
 	}
 }
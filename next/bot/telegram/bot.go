@@ -3,6 +3,7 @@ package telegram
 import (
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"log"
 	"strconv"
 	"strings"
@@ -16,16 +17,21 @@ import (
 
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next"
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next/mediator"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/outbox"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/schedule"
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next/subscription"
 )
 
 // Bot works as a frontend to the systems
 type Bot struct {
-	httpClient *next.Client
-	mediator   *mediator.SubscriptionMediator
-	config     *Config
-	tb         *telebot.Bot
-	stopCh     chan struct{}
+	storefronts *next.Storefronts
+	mediator    *mediator.SubscriptionMediator
+	config      *Config
+	tb          *telebot.Bot
+	searches    *searchSessions
+	sizes       *sizeSelections
+	quietHours  *quietHours
+	location    *time.Location
 }
 
 // Start begins the message loop
@@ -34,29 +40,118 @@ func (b *Bot) Start() {
 	b.tb.Handle("/help", func(msg *telebot.Message) {
 		b.updateBotCommands()
 	})
+	b.tb.Handle("/list", b.cmdList)
+	b.tb.Handle("/target", b.cmdTarget)
+	b.tb.Handle("/search", b.cmdSearch)
+	b.tb.Handle("/undelivered", b.cmdUndelivered)
+	b.tb.Handle("/history", b.cmdHistory)
 	b.tb.Handle(telebot.OnCallback, b.callbackDispatcher)
 	b.tb.Handle(telebot.OnText, b.cmdNewArticle)
 
-	go func() {
-		for {
-			select {
-			case item := <-b.mediator.InStockItemCh():
-				b.handleInStockItem(item)
-			case <-b.stopCh:
-				return
-			}
-		}
-	}()
-
 	b.tb.Start()
 }
 
 func (b *Bot) Stop() {
 	log.Println("[INFO] Stopping Telegram bot")
-	close(b.stopCh)
 	b.tb.Stop()
 }
 
+// itemCallbackData is a payload of inline buttons related to particular shop item,
+// item with chosen sizes is referred to by digest of the sizes
+type itemCallbackData struct {
+	ID         string
+	Article    string
+	Size       int
+	Storefront string
+}
+
+func (d itemCallbackData) shopItem() shop.Item {
+	item := shop.NewItem(d.Article, d.Size)
+	item.Storefront = d.Storefront
+
+	return item
+}
+
+func encodeItemCallbackData(item shop.Item) (string, error) {
+	callbackData := NewCallbackData()
+	callbackData.AddItem("article", item.Article)
+	callbackData.AddItem("size", item.SizeID)
+	callbackData.AddItem("storefront", item.Storefront)
+
+	return callbackData.Encode()
+}
+
+// sizesDigestModulo keeps digest of chosen sizes within 6 characters
+const sizesDigestModulo = 36 * 36 * 36 * 36 * 36 * 36
+
+// sizesDigest identifies chosen sizes among user's subscriptions of the article
+func sizesDigest(sizes []int) string {
+	hash := fnv.New32a()
+	for _, size := range sizes {
+		_, _ = fmt.Fprintf(hash, "%d,", size)
+	}
+
+	return strconv.FormatUint(uint64(hash.Sum32())%sizesDigestModulo, 36)
+}
+
+// itemButtonData encodes the item for inline buttons, chosen sizes do not fit into callback data,
+// so item with chosen sizes is referred to by their digest and found among subscriptions of the user
+func itemButtonData(item shop.Item) (string, error) {
+	if len(item.SizeIDs) == 0 {
+		return encodeItemCallbackData(item)
+	}
+
+	callbackData := NewCallbackData()
+	callbackData.AddItem("article", item.Article)
+	callbackData.AddItem("id", sizesDigest(item.SizeIDs))
+	callbackData.AddItem("storefront", item.Storefront)
+
+	return callbackData.Encode()
+}
+
+// callbackShopItem decodes the item the button refers to, false is returned if the item is not found
+func (b *Bot) callbackShopItem(c *telebot.Callback, decodedData map[string]interface{}) (shop.Item, bool) {
+	var inlineCallbackData itemCallbackData
+
+	if err := mapstructure.Decode(decodedData, &inlineCallbackData); err != nil {
+		log.Println("[ERROR] Could not map the callback data to structure: " + err.Error())
+		return shop.Item{}, false
+	}
+
+	if inlineCallbackData.ID == "" {
+		return inlineCallbackData.shopItem(), true
+	}
+
+	item, err := b.findSizesItem(subscription.User{ID: strconv.FormatInt(c.Sender.ID, 10)}, inlineCallbackData)
+	if err != nil {
+		log.Println("[ERROR] Could not find the item of the button: " + err.Error())
+		if err := b.tb.Respond(c, &telebot.CallbackResponse{Text: "The subscription no longer exists"}); err != nil {
+			log.Println("[ERROR] Could not respond to callback: " + err.Error())
+		}
+
+		return shop.Item{}, false
+	}
+
+	return item, true
+}
+
+// findSizesItem finds the item with chosen sizes among subscriptions of the user
+func (b *Bot) findSizesItem(user subscription.User, data itemCallbackData) (shop.Item, error) {
+	subscriptions, err := b.mediator.ReadUserAllSubscriptions(user)
+	if err != nil {
+		return shop.Item{}, err
+	}
+
+	for _, item := range subscriptions {
+		if item.ShopItem.Article == data.Article && item.ShopItem.Storefront == data.Storefront &&
+			len(item.ShopItem.SizeIDs) > 0 && sizesDigest(item.ShopItem.SizeIDs) == data.ID {
+			return item.ShopItem, nil
+		}
+	}
+
+	return shop.Item{}, fmt.Errorf("no subscription to %s with sizes %s found", data.Article, data.ID)
+}
+
 func (b *Bot) callbackDispatcher(c *telebot.Callback) {
 	dataItems := strings.Split(c.Data, "|")
 	if len(dataItems) != 2 {
@@ -71,28 +166,62 @@ func (b *Bot) callbackDispatcher(c *telebot.Callback) {
 		return
 	}
 
-	var inlineCallbackData struct {
-		Article string
-		Size    int
+	handlers := map[string]func(*telebot.Callback, map[string]interface{}){
+		callbackSubscribe:  b.onSubscribeCallback,
+		callbackPriceDrop:  b.onPriceDropCallback,
+		callbackSearch:     b.onSearchPageCallback,
+		callbackPick:       b.onPickProductCallback,
+		callbackComingSoon: b.onComingSoonCallback,
+		callbackKeepWatching: func(c *telebot.Callback, data map[string]interface{}) {
+			b.onKeepWatchingCallback(c, data, true)
+		},
+		callbackStopWatching: func(c *telebot.Callback, data map[string]interface{}) {
+			b.onKeepWatchingCallback(c, data, false)
+		},
+		callbackWatchDiscontinued: b.onWatchDiscontinuedCallback,
+		callbackSelectSizes:       b.onSelectSizesCallback,
+		callbackToggleSize:        b.onToggleSizeCallback,
+		callbackSizesChosen:       b.onSizesChosenCallback,
 	}
 
-	if err := mapstructure.Decode(decodedData, &inlineCallbackData); err != nil {
-		log.Println("[ERROR] Could not map the callback data to structure: " + err.Error())
+	unique := strings.TrimPrefix(dataItems[0], "\f")
+	handler, ok := handlers[unique]
+	if !ok {
+		log.Printf("[ERROR] unknown callback <%s>\n", unique)
+		return
 	}
 
+	handler(c, decodedData)
+}
+
+func (b *Bot) onSubscribeCallback(c *telebot.Callback, decodedData map[string]interface{}) {
+	shopItem, ok := b.callbackShopItem(c, decodedData)
+	if !ok {
+		return
+	}
+
+	b.subscribe(c, shopItem)
+}
+
+// subscribe creates subscription of the user to the item and offers extra notifications
+func (b *Bot) subscribe(c *telebot.Callback, shopItem shop.Item) {
 	created, err := b.mediator.CreateSubscription(
 		subscription.Item{
 			Active: true,
 			User: subscription.User{
 				ID: strconv.FormatInt(c.Sender.ID, 10),
 			},
-			ShopItem: shop.NewItem(inlineCallbackData.Article, inlineCallbackData.Size),
+			ShopItem: shopItem,
 		},
 	)
 
 	if err != nil {
 		log.Println("[ERROR] subscription creation failed: " + err.Error())
-		if _, err = b.tb.Edit(c.Message, "Subscription creation failed"); err != nil {
+		messageText := "Subscription creation failed"
+		if errors.Is(err, next.ErrItemNotFound) {
+			messageText = fetchErrorMessage(shopItem.Article, err)
+		}
+		if _, err = b.tb.Edit(c.Message, messageText); err != nil {
 			log.Println("[ERROR] Could not update message: " + err.Error())
 		}
 
@@ -101,13 +230,152 @@ func (b *Bot) callbackDispatcher(c *telebot.Callback) {
 
 	var messageText string
 	if created {
-		messageText = fmt.Sprintf("Subscription for %s with sizeID %d created",
-			inlineCallbackData.Article,
-			inlineCallbackData.Size)
+		messageText = fmt.Sprintf("Subscription for %s with %s created", shopItem.Article, sizesDescription(shopItem))
+	} else {
+		messageText = fmt.Sprintf("Subscription for %s with %s creation skipped: exists",
+			shopItem.Article,
+			sizesDescription(shopItem))
+	}
+
+	priceDropSelector := &telebot.ReplyMarkup{}
+	encodedData, err := itemButtonData(shopItem)
+	if err != nil {
+		log.Println("[ERROR] Could not encode button data: " + err.Error())
 	} else {
-		messageText = fmt.Sprintf("Subscription for %s with sizeID %d creation skipped: exists",
-			inlineCallbackData.Article,
-			inlineCallbackData.Size)
+		priceDropSelector.Inline(
+			priceDropSelector.Row(
+				priceDropSelector.Data("Notify me on price drops", callbackPriceDrop, encodedData),
+			),
+			priceDropSelector.Row(
+				priceDropSelector.Data("Notify me when restock is announced", callbackComingSoon, encodedData),
+			),
+		)
+	}
+
+	if _, err = b.tb.Edit(c.Message, messageText, priceDropSelector); err != nil {
+		log.Println("[ERROR] Could not update message: " + err.Error())
+	}
+}
+
+// sizesDescription tells which sizes of the article the item watches
+func sizesDescription(item shop.Item) string {
+	if item.SizeID != shop.SizeAny {
+		return "sizeID " + strconv.Itoa(item.SizeID)
+	}
+
+	if len(item.SizeIDs) == 0 {
+		return "any size"
+	}
+
+	sizes := make([]string, 0, len(item.SizeIDs))
+	for _, size := range item.SizeIDs {
+		sizes = append(sizes, strconv.Itoa(size))
+	}
+
+	return "sizeIDs " + strings.Join(sizes, ", ")
+}
+
+func (b *Bot) onPriceDropCallback(c *telebot.Callback, decodedData map[string]interface{}) {
+	shopItem, ok := b.callbackShopItem(c, decodedData)
+	if !ok {
+		return
+	}
+
+	err := b.mediator.SetPriceDropAlert(
+		subscription.Item{
+			User:     subscription.User{ID: strconv.FormatInt(c.Sender.ID, 10)},
+			ShopItem: shopItem,
+		},
+		true,
+	)
+
+	messageText := c.Message.Text + "\nPrice drop notifications enabled"
+	if err != nil {
+		log.Println("[ERROR] Could not enable price drop notifications: " + err.Error())
+		messageText = c.Message.Text + "\nCould not enable price drop notifications"
+	}
+
+	if _, err = b.tb.Edit(c.Message, messageText, c.Message.ReplyMarkup); err != nil {
+		log.Println("[ERROR] Could not update message: " + err.Error())
+	}
+}
+
+func (b *Bot) onComingSoonCallback(c *telebot.Callback, decodedData map[string]interface{}) {
+	shopItem, ok := b.callbackShopItem(c, decodedData)
+	if !ok {
+		return
+	}
+
+	err := b.mediator.SetStatusAlert(
+		subscription.Item{
+			User:     subscription.User{ID: strconv.FormatInt(c.Sender.ID, 10)},
+			ShopItem: shopItem,
+		},
+		shop.ItemStatusComingSoon,
+		true,
+	)
+
+	messageText := c.Message.Text + "\nRestock announcement notifications enabled"
+	if err != nil {
+		log.Println("[ERROR] Could not enable restock announcement notifications: " + err.Error())
+		messageText = c.Message.Text + "\nCould not enable restock announcement notifications"
+	}
+
+	if _, err = b.tb.Edit(c.Message, messageText, c.Message.ReplyMarkup); err != nil {
+		log.Println("[ERROR] Could not update message: " + err.Error())
+	}
+}
+
+func (b *Bot) onKeepWatchingCallback(c *telebot.Callback, decodedData map[string]interface{}, enabled bool) {
+	shopItem, ok := b.callbackShopItem(c, decodedData)
+	if !ok {
+		return
+	}
+
+	item, err := b.mediator.SetKeepWatching(
+		subscription.Item{
+			User:     subscription.User{ID: strconv.FormatInt(c.Sender.ID, 10)},
+			ShopItem: shopItem,
+		},
+		enabled,
+	)
+	if err != nil {
+		log.Println("[ERROR] Could not change keep watching mode: " + err.Error())
+		messageText := c.Message.Text + "\nCould not change watching mode"
+		if _, err = b.tb.Edit(c.Message, messageText, c.Message.ReplyMarkup); err != nil {
+			log.Println("[ERROR] Could not update message: " + err.Error())
+		}
+
+		return
+	}
+
+	messageText := "Item in stock\nI'll stop watching it"
+	if item.KeepWatching {
+		messageText = "Item in stock\nI'll keep watching it and notify you on the next restock"
+	} else if item.Active {
+		messageText = "Item in stock\nI'll notify you on the next restock and stop watching it then"
+	}
+
+	if _, err = b.tb.Edit(c.Message, messageText, b.inStockMarkup(item)); err != nil {
+		log.Println("[ERROR] Could not update message: " + err.Error())
+	}
+}
+
+func (b *Bot) onWatchDiscontinuedCallback(c *telebot.Callback, decodedData map[string]interface{}) {
+	shopItem, ok := b.callbackShopItem(c, decodedData)
+	if !ok {
+		return
+	}
+
+	err := b.mediator.WatchDiscontinued(subscription.Item{
+		User:     subscription.User{ID: strconv.FormatInt(c.Sender.ID, 10)},
+		ShopItem: shopItem,
+	})
+
+	messageText := c.Message.Text + "\nOK, I'll keep watching it anyway"
+	if err != nil {
+		log.Println("[ERROR] Could not resume subscription: " + err.Error())
+		messageText = c.Message.Text + "\nCould not resume the subscription"
 	}
 
 	if _, err = b.tb.Edit(c.Message, messageText); err != nil {
@@ -115,8 +383,36 @@ func (b *Bot) callbackDispatcher(c *telebot.Callback) {
 	}
 }
 
-func (b *Bot) handleInStockItem(item subscription.Item) {
-	log.Println("[DEBUG] Bot: new item in stock: ", item)
+// Deliver sends the notification to its recipient, it is postponed during recipient's quiet hours
+func (b *Bot) Deliver(notification subscription.Notification) error {
+	if until, ok := b.quietHours.postponedUntil(notification.Item.User.ID, time.Now()); ok {
+		return &outbox.PostponedError{Until: until}
+	}
+
+	return b.handleNotification(notification)
+}
+
+func (b *Bot) handleNotification(notification subscription.Notification) error {
+	switch notification.Kind {
+	case subscription.NotificationInStock:
+		return b.handleInStockItem(notification)
+	case subscription.NotificationPriceDrop, subscription.NotificationPriceBelowTarget:
+		return b.handlePriceNotification(notification)
+	case subscription.NotificationRestockDateChanged:
+		return b.handleRestockDateNotification(notification)
+	case subscription.NotificationStatusChanged:
+		return b.handleStatusNotification(notification)
+	case subscription.NotificationDiscontinued:
+		return b.handleDiscontinuedNotification(notification)
+	}
+
+	log.Printf("[WARN] Bot: unknown notification kind <%s> is dropped\n", notification.Kind)
+
+	return nil
+}
+
+// itemURLMarkup builds an inline button leading to the item page, if the URL is known
+func itemURLMarkup(item subscription.Item) *telebot.ReplyMarkup {
 	inlineURL := &telebot.ReplyMarkup{}
 
 	if item.ShopItem.URL != "" {
@@ -126,14 +422,168 @@ func (b *Bot) handleInStockItem(item subscription.Item) {
 			),
 		)
 	}
+
+	return inlineURL
+}
+
+// inStockMarkup builds buttons of in-stock notification: item page and "keep watching" toggle
+func (b *Bot) inStockMarkup(item subscription.Item) *telebot.ReplyMarkup {
+	markup := &telebot.ReplyMarkup{}
+	rows := make([]telebot.Row, 0, 2)
+
+	if item.ShopItem.URL != "" {
+		rows = append(rows, markup.Row(
+			markup.URL(item.ShopItem.Description+", "+item.ShopItem.SizeString, item.ShopItem.URL),
+		))
+	}
+
+	encodedData, err := itemButtonData(item.ShopItem)
+	if err != nil {
+		log.Println("[ERROR] Could not encode button data: " + err.Error())
+	} else if item.KeepWatching {
+		rows = append(rows, markup.Row(markup.Data("Stop watching", callbackStopWatching, encodedData)))
+	} else {
+		rows = append(rows, markup.Row(markup.Data("Keep watching for restocks", callbackKeepWatching, encodedData)))
+	}
+
+	markup.Inline(rows...)
+
+	return markup
+}
+
+func (b *Bot) handleInStockItem(notification subscription.Notification) error {
+	item := notification.Item
+	log.Println("[DEBUG] Bot: new item in stock: ", item)
+
+	messageText := "Item in stock"
+	if notification.Size != "" {
+		messageText += ", size " + notification.Size
+	}
+
 	_, err := b.tb.Send(
 		ChatID(item.User.ID),
-		"Item in stock",
-		inlineURL,
+		messageText,
+		b.inStockMarkup(item),
+	)
+	if err != nil {
+		return fmt.Errorf("could not notify user about in-stock item: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Bot) handlePriceNotification(notification subscription.Notification) error {
+	log.Println("[DEBUG] Bot: price changed: ", notification)
+
+	var messageText string
+	if notification.Kind == subscription.NotificationPriceBelowTarget {
+		messageText = fmt.Sprintf("Price of %s is %s, your target is %s",
+			notificationTitle(notification),
+			notification.Price,
+			notification.Item.TargetPrice,
+		)
+	} else {
+		messageText = fmt.Sprintf("Price of %s dropped from %s to %s",
+			notificationTitle(notification),
+			notification.PreviousPrice,
+			notification.Price,
+		)
+	}
+
+	_, err := b.tb.Send(
+		ChatID(notification.Item.User.ID),
+		messageText,
+		itemURLMarkup(notification.Item),
 	)
 	if err != nil {
-		log.Println("[ERROR] Could not notify user about in-stock item: " + err.Error())
+		return fmt.Errorf("could not notify user about price change: %w", err)
 	}
+
+	return nil
+}
+
+// notificationTitle names the article of the notification along with the size of multi-size subscription
+func notificationTitle(notification subscription.Notification) string {
+	title := notification.Item.ShopItem.Article
+	if notification.Size != "" {
+		title += ", size " + notification.Size
+	}
+
+	return title
+}
+
+func (b *Bot) handleRestockDateNotification(notification subscription.Notification) error {
+	log.Println("[DEBUG] Bot: restock date changed: ", notification)
+
+	messageText := fmt.Sprintf("Expected restock of %s has changed from \"%s\" to \"%s\"",
+		notificationTitle(notification),
+		notification.PreviousStockMessage,
+		notification.StockMessage,
+	)
+
+	_, err := b.tb.Send(
+		ChatID(notification.Item.User.ID),
+		messageText,
+		itemURLMarkup(notification.Item),
+	)
+	if err != nil {
+		return fmt.Errorf("could not notify user about restock date change: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Bot) handleStatusNotification(notification subscription.Notification) error {
+	log.Println("[DEBUG] Bot: stock status changed: ", notification)
+
+	messageText := fmt.Sprintf("Status of %s has changed from %s to %s",
+		notificationTitle(notification),
+		notification.PreviousStatus,
+		notification.Status,
+	)
+	if notification.StockMessage != "" {
+		messageText += ", expected: " + notification.StockMessage
+	}
+
+	_, err := b.tb.Send(
+		ChatID(notification.Item.User.ID),
+		messageText,
+		itemURLMarkup(notification.Item),
+	)
+	if err != nil {
+		return fmt.Errorf("could not notify user about status change: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Bot) handleDiscontinuedNotification(notification subscription.Notification) error {
+	log.Println("[DEBUG] Bot: item appears discontinued: ", notification)
+
+	item := notification.Item
+	title := item.ShopItem.Article
+	if item.ShopItem.Description != "" {
+		title = item.ShopItem.Description + " (" + item.ShopItem.Article + ")"
+	}
+
+	markup := &telebot.ReplyMarkup{}
+	encodedData, err := itemButtonData(item.ShopItem)
+	if err != nil {
+		log.Println("[ERROR] Could not encode button data: " + err.Error())
+	} else {
+		markup.Inline(markup.Row(markup.Data("Keep watching anyway", callbackWatchDiscontinued, encodedData)))
+	}
+
+	_, err = b.tb.Send(
+		ChatID(item.User.ID),
+		title+" appears to be discontinued, I've stopped watching it",
+		markup,
+	)
+	if err != nil {
+		return fmt.Errorf("could not notify user about discontinued item: %w", err)
+	}
+
+	return nil
 }
 
 func (b *Bot) cmdStart(m *telebot.Message) {
@@ -153,7 +603,7 @@ func (b *Bot) cmdStart(m *telebot.Message) {
 }
 
 func (b *Bot) cmdNewArticle(m *telebot.Message) {
-	article, err := ParseStringWithArticle(m.Text)
+	article, storefront, err := ParseStringWithArticle(m.Text)
 
 	if err != nil {
 		log.Println("[ERROR] Could not parse article: " + err.Error())
@@ -164,23 +614,42 @@ func (b *Bot) cmdNewArticle(m *telebot.Message) {
 		return
 	}
 
-	items, err := b.mediator.FetchSizeIDs(article)
+	storefront = b.storefronts.Resolve(storefront)
+	if !b.storefronts.Has(storefront) {
+		messageText := fmt.Sprintf("Storefront '%s' is not supported. Supported storefronts: %s",
+			storefront,
+			strings.Join(b.storefronts.IDs(), ", "),
+		)
+		if _, err := b.tb.Reply(m, messageText); err != nil {
+			log.Println("[ERROR] Could send message: " + err.Error())
+		}
+
+		return
+	}
+
+	b.sendSizeSelector(m.Sender, storefront, article)
+}
+
+// sendSizeSelector sends inline size picker of the article
+func (b *Bot) sendSizeSelector(to telebot.Recipient, storefront, article string) {
+	items, err := b.mediator.FetchSizeIDs(storefront, article)
 	if err != nil {
 		log.Println("[ERROR] Could fetch sized: " + err.Error())
-		if _, err := b.tb.Send(m.Sender, "Could not fetch sizes fo "+article); err != nil {
+		if _, err := b.tb.Send(to, fetchErrorMessage(article, err)); err != nil {
 			log.Println("[ERROR] Could send message: " + err.Error())
 		}
+
+		return
 	}
 
 	inlineSizeSelector := &telebot.ReplyMarkup{}
 	rows := make([]telebot.Row, 0, len(items))
 
 	for _, item := range items {
-		callbackData := NewCallbackData()
-		callbackData.AddItem("article", article)
-		callbackData.AddItem("size", item.Number)
+		shopItem := shop.NewItem(article, item.Number)
+		shopItem.Storefront = storefront
 
-		encodedData, err := callbackData.Encode()
+		encodedData, err := encodeItemCallbackData(shopItem)
 		if err != nil {
 			log.Println("[ERROR] Could not encode button data: " + err.Error())
 		}
@@ -188,24 +657,211 @@ func (b *Bot) cmdNewArticle(m *telebot.Message) {
 		rows = append(
 			rows,
 			inlineSizeSelector.Row(
-				inlineSizeSelector.Data(item.Name, "article="+article+"_size="+strconv.Itoa(item.Number), encodedData),
+				inlineSizeSelector.Data(sizeButtonTitle(item), callbackSubscribe, encodedData),
 			))
 	}
 
+	rows = append(rows, anySizeRows(inlineSizeSelector, storefront, article)...)
 	inlineSizeSelector.Inline(rows...)
 
-	if _, err := b.tb.Send(m.Sender, "Select size for article "+article, inlineSizeSelector); err != nil {
+	if _, err := b.tb.Send(to, "Select size for article "+article+" ("+storefront+")", inlineSizeSelector); err != nil {
 		log.Println("[ERROR] Could send message: " + err.Error())
 		return
 	}
 }
 
+// anySizeRows builds buttons to watch any size of the article or to choose several sizes
+func anySizeRows(markup *telebot.ReplyMarkup, storefront, article string) []telebot.Row {
+	rows := make([]telebot.Row, 0, 2)
+
+	anySize := shop.NewMultiSizeItem(article)
+	anySize.Storefront = storefront
+	if encodedData, err := encodeItemCallbackData(anySize); err != nil {
+		log.Println("[ERROR] Could not encode button data: " + err.Error())
+	} else {
+		rows = append(rows, markup.Row(markup.Data("Any size", callbackSubscribe, encodedData)))
+	}
+
+	callbackData := NewCallbackData()
+	callbackData.AddItem("article", article)
+	callbackData.AddItem("storefront", storefront)
+	if encodedData, err := callbackData.Encode(); err != nil {
+		log.Println("[ERROR] Could not encode button data: " + err.Error())
+	} else {
+		rows = append(rows, markup.Row(markup.Data("Select several sizes", callbackSelectSizes, encodedData)))
+	}
+
+	return rows
+}
+
+// sizeButtonTitle shows the size name along with the restock estimate of unavailable sizes
+func sizeButtonTitle(option shop.ItemOption) string {
+	if option.StockStatus.Purchasable() || option.StockMessage == "" {
+		return option.Name
+	}
+
+	return option.Name + " (" + option.StockMessage + ")"
+}
+
+// fetchErrorMessage explains to user why item information is not available
+func fetchErrorMessage(article string, err error) string {
+	switch {
+	case errors.Is(err, next.ErrItemNotFound):
+		return "Article " + article + " is not found"
+	case errors.Is(err, next.ErrRateLimited), errors.Is(err, next.ErrCircuitOpen):
+		return "Next is not responding at the moment, please try again later"
+	}
+
+	return "Could not fetch sizes for " + article
+}
+
+// cmdList lists user's active subscriptions
+func (b *Bot) cmdList(m *telebot.Message) {
+	subscriptions, err := b.mediator.ReadUserSubscriptions(subscription.User{ID: strconv.FormatInt(m.Sender.ID, 10)})
+	messageText := subscriptionsListMessage(subscriptions, b.mediator.PollInterval)
+	if err != nil {
+		log.Println("[ERROR] Could not read user subscriptions: " + err.Error())
+		messageText = "Could not read your subscriptions"
+	}
+
+	if _, err := b.tb.Reply(m, messageText); err != nil {
+		log.Println("[ERROR] Could send message: " + err.Error())
+	}
+}
+
+func subscriptionsListMessage(
+	subscriptions []subscription.Item,
+	pollInterval func(shop.Item) (time.Duration, bool),
+) string {
+	if len(subscriptions) == 0 {
+		return "You have no active subscriptions"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Your active subscriptions:")
+	for index, item := range subscriptions {
+		title := item.ShopItem.Description
+		if title == "" {
+			title = item.ShopItem.Article
+		}
+		size := item.ShopItem.SizeString
+		if size == "" && item.ShopItem.SizeID == shop.SizeAny {
+			size = sizesDescription(item.ShopItem)
+		} else if size == "" {
+			size = "size " + strconv.Itoa(item.ShopItem.SizeID)
+		}
+
+		sb.WriteString(fmt.Sprintf("\n%d. %s, %s [%s, %s]",
+			index+1,
+			title,
+			size,
+			item.ShopItem.Article,
+			item.ShopItem.Storefront,
+		))
+		if !item.LastPrice.IsZero() {
+			sb.WriteString("\n    price: " + item.LastPrice.String())
+		}
+		if item.StockMessage != "" {
+			sb.WriteString("\n    expected: " + item.StockMessage)
+		}
+		if interval, ok := pollInterval(item.ShopItem); ok {
+			sb.WriteString("\n    checked every " + interval.String())
+		}
+	}
+
+	return sb.String()
+}
+
+// cmdTarget sets target price for user's subscriptions of the article, e.g. "/target 111-222 500 грн"
+func (b *Bot) cmdTarget(m *telebot.Message) {
+	reply := func(text string) {
+		if _, err := b.tb.Reply(m, text); err != nil {
+			log.Println("[ERROR] Could send message: " + err.Error())
+		}
+	}
+
+	args := strings.Fields(m.Payload)
+	if len(args) < 2 {
+		reply("Usage: /target <article> <price>, e.g. /target 111-222 500")
+		return
+	}
+
+	article, _, err := ParseStringWithArticle(args[0])
+	if err != nil {
+		reply(err.Error())
+		return
+	}
+
+	target, err := shop.ParsePrice(strings.Join(args[1:], " "))
+	if err != nil {
+		reply(err.Error())
+		return
+	}
+
+	updated, err := b.mediator.SetTargetPrice(
+		subscription.User{ID: strconv.FormatInt(m.Sender.ID, 10)},
+		article,
+		target,
+	)
+	if err != nil {
+		log.Println("[ERROR] Could not set target price: " + err.Error())
+		reply("Could not set target price")
+		return
+	}
+
+	if updated == 0 {
+		reply("You have no active subscriptions for " + article)
+		return
+	}
+
+	reply(fmt.Sprintf("Target price %s is set for %d subscription(s) of %s", target, updated, article))
+}
+
+// cmdUndelivered lists user's notifications which ran out of delivery attempts
+func (b *Bot) cmdUndelivered(m *telebot.Message) {
+	entries, err := b.mediator.UndeliveredNotifications(subscription.User{ID: strconv.FormatInt(m.Sender.ID, 10)})
+	messageText := undeliveredMessage(entries)
+	if err != nil {
+		log.Println("[ERROR] Could not read undelivered notifications: " + err.Error())
+		messageText = "Could not read undelivered notifications"
+	}
+
+	if _, err := b.tb.Reply(m, messageText); err != nil {
+		log.Println("[ERROR] Could send message: " + err.Error())
+	}
+}
+
+func undeliveredMessage(entries []outbox.Entry) string {
+	if len(entries) == 0 {
+		return "All notifications have been delivered"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Notifications which could not be delivered:")
+	for index, entry := range entries {
+		sb.WriteString(fmt.Sprintf("\n%d. %s of %s at %s, %d attempt(s): %s",
+			index+1,
+			entry.Notification.Kind,
+			entry.Notification.Item.ShopItem.Article,
+			entry.CreatedAt.Format("2006-01-02 15:04"),
+			entry.Attempts,
+			entry.LastError,
+		))
+	}
+
+	return sb.String()
+}
+
 func (b *Bot) updateBotCommands() {
 	log.Println("[INFO] Updating bot commands")
 	err := b.tb.SetCommands(
 		[]telebot.Command{
 			{Text: "/new", Description: "Create new subscription"},
 			{Text: "/list", Description: "List all my active subscriptions"},
+			{Text: "/target", Description: "Notify when the price falls to the target"},
+			{Text: "/search", Description: "Search products by keywords"},
+			{Text: "/undelivered", Description: "Show notifications which could not be delivered"},
+			{Text: "/history", Description: "Show stock status changes of the article"},
 			{Text: "/help", Description: "Show help"},
 		},
 	)
@@ -216,11 +872,21 @@ func (b *Bot) updateBotCommands() {
 }
 
 // New instantiates new Bot object
-func New(httpClient *next.Client, mediator *mediator.SubscriptionMediator, config *Config) (*Bot, error) {
+func New(storefronts *next.Storefronts, mediator *mediator.SubscriptionMediator, config *Config) (*Bot, error) {
 	if config.Token == "" {
 		return nil, errors.New("telegram Bot token must be set")
 	}
 
+	location, err := schedule.LoadLocation(config.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+
+	quietHours, err := newQuietHours(config.QuietHours, location)
+	if err != nil {
+		return nil, err
+	}
+
 	longPoller := &telebot.LongPoller{
 		Timeout: 5 * time.Second,
 	}
@@ -235,11 +901,14 @@ func New(httpClient *next.Client, mediator *mediator.SubscriptionMediator, confi
 	}
 
 	bot := &Bot{
-		httpClient: httpClient,
-		mediator:   mediator,
-		config:     config,
-		tb:         tb,
-		stopCh:     make(chan struct{}),
+		storefronts: storefronts,
+		mediator:    mediator,
+		config:      config,
+		tb:          tb,
+		searches:    newSearchSessions(maxSearchSessions),
+		sizes:       newSizeSelections(maxSizeSelections),
+		quietHours:  quietHours,
+		location:    location,
 	}
 
 	return bot, nil
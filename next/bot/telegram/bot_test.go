@@ -0,0 +1,81 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/outbox"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/subscription"
+)
+
+func TestSizeButtonTitle(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("EU S", sizeButtonTitle(shop.ItemOption{
+		Name:         "EU S",
+		StockStatus:  shop.ItemStatusInStock,
+		StockMessage: "середина января",
+	}))
+	assert.Equal("EU S (середина января)", sizeButtonTitle(shop.ItemOption{
+		Name:         "EU S",
+		StockStatus:  shop.ItemStatusComingSoon,
+		StockMessage: "середина января",
+	}))
+}
+
+func TestSubscriptionsListMessage(t *testing.T) {
+	assert := assert.New(t)
+
+	pollInterval := func(item shop.Item) (time.Duration, bool) {
+		return 5 * time.Minute, item.Article == "821585"
+	}
+
+	assert.Equal("You have no active subscriptions", subscriptionsListMessage(nil, pollInterval))
+	assert.Equal(
+		"Your active subscriptions:\n"+
+			"1. Теплая пижама, EU S [821585, ua]\n"+
+			"    price: 635.00 UAH\n"+
+			"    expected: середина января\n"+
+			"    checked every 5m0s\n"+
+			"2. 111222, size 10 [111222, uk]",
+		subscriptionsListMessage([]subscription.Item{
+			{
+				ShopItem: shop.Item{
+					Article:     "821585",
+					SizeID:      11,
+					Storefront:  "ua",
+					Description: "Теплая пижама",
+					SizeString:  "EU S",
+				},
+				LastPrice:    shop.Money{Amount: 63500, Currency: "UAH"},
+				StockMessage: "середина января",
+			},
+			{
+				ShopItem: shop.Item{Article: "111222", SizeID: 10, Storefront: "uk"},
+			},
+		}, pollInterval),
+	)
+}
+
+func TestUndeliveredMessage(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("All notifications have been delivered", undeliveredMessage(nil))
+	assert.Equal(
+		"Notifications which could not be delivered:\n"+
+			"1. in_stock of 111222 at 2026-01-15 10:00, 10 attempt(s): telegram: Forbidden (403)",
+		undeliveredMessage([]outbox.Entry{{
+			Notification: subscription.Notification{
+				Kind: subscription.NotificationInStock,
+				Item: subscription.Item{ShopItem: shop.Item{Article: "111222", SizeID: 10}},
+			},
+			Attempts:  10,
+			LastError: "telegram: Forbidden (403)",
+			CreatedAt: time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC),
+			Status:    outbox.StatusDead,
+		}}),
+	)
+}
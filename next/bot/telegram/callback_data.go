@@ -4,6 +4,25 @@ import (
 	"encoding/json"
 )
 
+// Telegram limits callback data to 64 bytes, so button identifiers are kept short
+const (
+	callbackSubscribe = "subscribe"
+	callbackPriceDrop = "pricedrop"
+	callbackSearch    = "search"
+	callbackPick      = "pick"
+	// callbackComingSoon enables notification when the item moves into ComingSoon status
+	callbackComingSoon = "soon"
+	// callbackKeepWatching and callbackStopWatching toggle "keep watching" mode of the subscription
+	callbackKeepWatching = "keep"
+	callbackStopWatching = "nokeep"
+	// callbackWatchDiscontinued keeps watching the item which appears discontinued
+	callbackWatchDiscontinued = "revive"
+	// callbackSelectSizes, callbackToggleSize and callbackSizesChosen drive the multi-size picker
+	callbackSelectSizes = "multi"
+	callbackToggleSize  = "size"
+	callbackSizesChosen = "sized"
+)
+
 type CallbackData struct {
 	items map[string]interface{}
 }
@@ -8,4 +8,8 @@ import (
 type Config struct {
 	AllowedUsers []subscription.User
 	Token        string
+	// TimeZone is IANA name of the time zone of quiet hours, local one by default
+	TimeZone string
+	// QuietHours postpone users' notifications till the end of their quiet period
+	QuietHours []QuietHoursConfig
 }
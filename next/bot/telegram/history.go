@@ -0,0 +1,103 @@
+package telegram
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/tucnak/telebot.v2"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/history"
+)
+
+// maxHistoryChanges limits number of status changes shown per size, so the message fits Telegram limits
+const maxHistoryChanges = 10
+
+// cmdHistory shows timeline of stock status changes of the article, e.g. "/history 111-222"
+func (b *Bot) cmdHistory(m *telebot.Message) {
+	reply := func(text string) {
+		if _, err := b.tb.Reply(m, text); err != nil {
+			log.Println("[ERROR] Could send message: " + err.Error())
+		}
+	}
+
+	if strings.TrimSpace(m.Payload) == "" {
+		reply("Usage: /history <article>, e.g. /history 111-222")
+		return
+	}
+
+	article, _, err := ParseStringWithArticle(strings.TrimSpace(m.Payload))
+	if err != nil {
+		reply(err.Error())
+		return
+	}
+
+	changes, err := b.mediator.StockHistory(article)
+	if err != nil {
+		log.Println("[ERROR] Could not read stock history: " + err.Error())
+		reply("Could not read stock history")
+		return
+	}
+
+	reply(historyMessage(article, changes, b.location))
+}
+
+// historyMessage lists status changes of every size of the article, the latest ones of each size are shown
+func historyMessage(article string, changes []history.Record, location *time.Location) string {
+	if len(changes) == 0 {
+		return "No stock history of " + article + " yet, it is recorded while somebody watches the article"
+	}
+
+	type sizeKey struct {
+		Storefront string
+		SizeID     int
+	}
+
+	order := make([]sizeKey, 0)
+	bySize := make(map[sizeKey][]history.Record)
+	for _, record := range changes {
+		key := sizeKey{Storefront: record.Storefront, SizeID: record.SizeID}
+		if _, ok := bySize[key]; !ok {
+			order = append(order, key)
+		}
+		bySize[key] = append(bySize[key], record)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Stock history of " + article + ":")
+	for _, key := range order {
+		records := bySize[key]
+		if len(records) > maxHistoryChanges {
+			records = records[len(records)-maxHistoryChanges:]
+		}
+
+		sb.WriteString("\n\n" + historySizeTitle(records[len(records)-1]) + ":")
+		for _, record := range records {
+			sb.WriteString(fmt.Sprintf("\n%s %s", record.Time.In(location).Format("2006-01-02 15:04"), record.Status))
+			if !record.Price.IsZero() {
+				sb.WriteString(", " + record.Price.String())
+			}
+			if record.StockMessage != "" {
+				sb.WriteString(" (" + record.StockMessage + ")")
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// historySizeTitle names the size, storefront is mentioned if it is known
+func historySizeTitle(record history.Record) string {
+	title := "size " + record.SizeName
+	if record.SizeName == "" {
+		title = "size " + strconv.Itoa(record.SizeID)
+	}
+
+	if record.Storefront != "" {
+		title = record.Storefront + ", " + title
+	}
+
+	return title
+}
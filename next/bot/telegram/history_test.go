@@ -0,0 +1,50 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/history"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
+)
+
+func TestHistoryMessage(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	assert.Equal(
+		"No stock history of 111222 yet, it is recorded while somebody watches the article",
+		historyMessage("111222", nil, time.UTC),
+	)
+	assert.Equal(
+		"Stock history of 111222:\n\n"+
+			"uk, size 3-4 years:\n"+
+			"2026-01-15 10:00 ComingSoon (end of January)\n"+
+			"2026-01-16 10:00 InStock, 12.00 GBP\n\n"+
+			"size 11:\n"+
+			"2026-01-15 10:00 SoldOut",
+		historyMessage("111222", []history.Record{
+			{
+				Storefront:   "uk",
+				Article:      "111222",
+				SizeID:       10,
+				SizeName:     "3-4 years",
+				Status:       shop.ItemStatusComingSoon,
+				StockMessage: "end of January",
+				Time:         now,
+			},
+			{Article: "111222", SizeID: 11, Status: shop.ItemStatusSoldOut, Time: now},
+			{
+				Storefront: "uk",
+				Article:    "111222",
+				SizeID:     10,
+				SizeName:   "3-4 years",
+				Status:     shop.ItemStatusInStock,
+				Price:      shop.Money{Amount: 1200, Currency: "GBP"},
+				Time:       now.Add(24 * time.Hour),
+			},
+		}, time.UTC),
+	)
+}
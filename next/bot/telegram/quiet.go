@@ -0,0 +1,46 @@
+package telegram
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/schedule"
+)
+
+// QuietHoursConfig holds user's quiet hours, e.g. from "23:00" till "07:00"
+type QuietHoursConfig struct {
+	UserID string
+	Start  string
+	End    string
+}
+
+// quietHours tells whether notifications of the user should be postponed
+type quietHours struct {
+	windows map[string]*schedule.Window
+}
+
+// postponedUntil returns the end of user's quiet hours if they are on at the moment
+func (q *quietHours) postponedUntil(userID string, now time.Time) (time.Time, bool) {
+	window, ok := q.windows[userID]
+	if !ok || !window.Contains(now) {
+		return time.Time{}, false
+	}
+
+	return window.NextEnd(now), true
+}
+
+func newQuietHours(configs []QuietHoursConfig, location *time.Location) (*quietHours, error) {
+	windows := make(map[string]*schedule.Window, len(configs))
+	for _, config := range configs {
+		window, err := schedule.NewWindow(schedule.WindowConfig{Start: config.Start, End: config.End}, location)
+		if err != nil {
+			return nil, fmt.Errorf("quiet hours of user <%s>: %w", config.UserID, err)
+		}
+
+		if window != nil {
+			windows[config.UserID] = window
+		}
+	}
+
+	return &quietHours{windows: windows}, nil
+}
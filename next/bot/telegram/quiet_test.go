@@ -0,0 +1,31 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuietHours_postponesNotificationsTillTheEnd(t *testing.T) {
+	assert := assert.New(t)
+	quiet, err := newQuietHours([]QuietHoursConfig{{UserID: "1", Start: "23:00", End: "07:00"}}, time.UTC)
+	assert.NoError(err)
+
+	night := time.Date(2026, 1, 15, 23, 30, 0, 0, time.UTC)
+
+	until, ok := quiet.postponedUntil("1", night)
+	assert.True(ok)
+	assert.Equal(time.Date(2026, 1, 16, 7, 0, 0, 0, time.UTC), until)
+
+	_, ok = quiet.postponedUntil("2", night)
+	assert.False(ok, "user without quiet hours is notified immediately")
+
+	_, ok = quiet.postponedUntil("1", night.Add(12*time.Hour))
+	assert.False(ok)
+}
+
+func TestNewQuietHours_validatesConfig(t *testing.T) {
+	_, err := newQuietHours([]QuietHoursConfig{{UserID: "1", Start: "23:00"}}, time.UTC)
+	assert.Error(t, err)
+}
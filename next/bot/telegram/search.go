@@ -0,0 +1,188 @@
+package telegram
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"gopkg.in/tucnak/telebot.v2"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
+)
+
+// maxSearchSessions limits number of searches available for pagination
+const maxSearchSessions = 100
+
+// maxButtonTitleLength limits length of product title on the inline button
+const maxButtonTitleLength = 40
+
+type searchSession struct {
+	Storefront string
+	Query      string
+}
+
+// searchSessions keeps recent search queries for pagination buttons
+type searchSessions struct {
+	ids *shortIDs
+}
+
+func (s *searchSessions) add(session searchSession) string {
+	return s.ids.add(session)
+}
+
+func (s *searchSessions) get(id string) (searchSession, bool) {
+	value, ok := s.ids.get(id)
+	if !ok {
+		return searchSession{}, false
+	}
+	session, ok := value.(searchSession)
+
+	return session, ok
+}
+
+func newSearchSessions(limit int) *searchSessions {
+	return &searchSessions{ids: newShortIDs(limit)}
+}
+
+// cmdSearch searches products by keywords, e.g. "/search pyjamas" or "/search uk pyjamas"
+func (b *Bot) cmdSearch(m *telebot.Message) {
+	args := strings.Fields(m.Payload)
+	if len(args) > 1 && b.storefronts.Has(args[0]) {
+		args = args[1:]
+	}
+
+	if len(args) == 0 {
+		if _, err := b.tb.Reply(m, "Usage: /search [storefront] <keywords>, e.g. /search pyjamas"); err != nil {
+			log.Println("[ERROR] Could send message: " + err.Error())
+		}
+		return
+	}
+
+	storefront := ""
+	if fields := strings.Fields(m.Payload); len(fields) > len(args) {
+		storefront = fields[0]
+	}
+
+	session := searchSession{
+		Storefront: b.storefronts.Resolve(storefront),
+		Query:      strings.Join(args, " "),
+	}
+	id := b.searches.add(session)
+
+	messageText, markup := b.searchPage(id, session, 1)
+	if _, err := b.tb.Send(m.Sender, messageText, markup); err != nil {
+		log.Println("[ERROR] Could send message: " + err.Error())
+	}
+}
+
+func (b *Bot) onSearchPageCallback(c *telebot.Callback, decodedData map[string]interface{}) {
+	var pageData struct {
+		Session string
+		Page    int
+	}
+
+	if err := mapstructure.Decode(decodedData, &pageData); err != nil {
+		log.Println("[ERROR] Could not map the callback data to structure: " + err.Error())
+		return
+	}
+
+	messageText := "Search has expired, please repeat /search"
+	markup := &telebot.ReplyMarkup{}
+	if session, ok := b.searches.get(pageData.Session); ok {
+		messageText, markup = b.searchPage(pageData.Session, session, pageData.Page)
+	}
+
+	if _, err := b.tb.Edit(c.Message, messageText, markup); err != nil {
+		log.Println("[ERROR] Could not update message: " + err.Error())
+	}
+}
+
+func (b *Bot) onPickProductCallback(c *telebot.Callback, decodedData map[string]interface{}) {
+	var productData struct {
+		Article    string
+		Storefront string
+	}
+
+	if err := mapstructure.Decode(decodedData, &productData); err != nil {
+		log.Println("[ERROR] Could not map the callback data to structure: " + err.Error())
+		return
+	}
+
+	b.sendSizeSelector(c.Sender, productData.Storefront, productData.Article)
+}
+
+// searchPage builds a message with a page of search results and navigation buttons
+func (b *Bot) searchPage(id string, session searchSession, page int) (string, *telebot.ReplyMarkup) {
+	markup := &telebot.ReplyMarkup{}
+
+	result, err := b.mediator.SearchProducts(session.Storefront, session.Query, page)
+	if err != nil {
+		log.Println("[ERROR] Could not search products: " + err.Error())
+		return fetchErrorMessage(session.Query, err), markup
+	}
+
+	if len(result.Products) == 0 {
+		return fmt.Sprintf("Nothing found for \"%s\"", session.Query), markup
+	}
+
+	pages := (result.Total + result.PageSize - 1) / result.PageSize
+	rows := make([]telebot.Row, 0, len(result.Products)+1)
+	for _, product := range result.Products {
+		callbackData := NewCallbackData()
+		callbackData.AddItem("article", product.Article)
+		callbackData.AddItem("storefront", session.Storefront)
+		encodedData, err := callbackData.Encode()
+		if err != nil {
+			log.Println("[ERROR] Could not encode button data: " + err.Error())
+			continue
+		}
+
+		buttons := []telebot.Btn{markup.Data(productButtonTitle(product), callbackPick, encodedData)}
+		if product.ImageURL != "" {
+			buttons = append(buttons, markup.URL("Photo", product.ImageURL))
+		}
+		rows = append(rows, markup.Row(buttons...))
+	}
+
+	var navigation []telebot.Btn
+	if page > 1 {
+		if btn, ok := searchPageButton(markup, "« Prev", id, page-1); ok {
+			navigation = append(navigation, btn)
+		}
+	}
+	if result.HasNextPage() {
+		if btn, ok := searchPageButton(markup, "Next »", id, page+1); ok {
+			navigation = append(navigation, btn)
+		}
+	}
+	if len(navigation) > 0 {
+		rows = append(rows, markup.Row(navigation...))
+	}
+
+	markup.Inline(rows...)
+
+	return fmt.Sprintf("Results for \"%s\" (%s), page %d of %d:", session.Query, session.Storefront, page, pages), markup
+}
+
+func searchPageButton(markup *telebot.ReplyMarkup, text, id string, page int) (telebot.Btn, bool) {
+	callbackData := NewCallbackData()
+	callbackData.AddItem("session", id)
+	callbackData.AddItem("page", page)
+	encodedData, err := callbackData.Encode()
+	if err != nil {
+		log.Println("[ERROR] Could not encode button data: " + err.Error())
+		return telebot.Btn{}, false
+	}
+
+	return markup.Data(text, callbackSearch, encodedData), true
+}
+
+func productButtonTitle(product shop.Product) string {
+	title := []rune(product.Title)
+	if len(title) > maxButtonTitleLength {
+		title = append(title[:maxButtonTitleLength-1], '…')
+	}
+
+	return string(title) + ", " + product.Price
+}
@@ -0,0 +1,38 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
+)
+
+func TestSearchSessions_evictsOldestSessions(t *testing.T) {
+	sessions := newSearchSessions(2)
+	assert := assert.New(t)
+
+	first := sessions.add(searchSession{Storefront: "uk", Query: "pyjamas"})
+	second := sessions.add(searchSession{Storefront: "uk", Query: "dress"})
+	third := sessions.add(searchSession{Storefront: "ua", Query: "coat"})
+
+	_, ok := sessions.get(first)
+	assert.False(ok)
+
+	session, ok := sessions.get(second)
+	assert.True(ok)
+	assert.Equal("dress", session.Query)
+
+	session, ok = sessions.get(third)
+	assert.True(ok)
+	assert.Equal(searchSession{Storefront: "ua", Query: "coat"}, session)
+}
+
+func TestProductButtonTitle_truncatesLongTitles(t *testing.T) {
+	title := productButtonTitle(shop.Product{
+		Title: "Розовая в цветочек - Теплая пижама с длинным рукавом и брюками",
+		Price: "635 грн",
+	})
+
+	assert.Equal(t, "Розовая в цветочек - Теплая пижама с дл…, 635 грн", title)
+}
@@ -0,0 +1,79 @@
+package telegram
+
+import (
+	"strconv"
+	"sync"
+)
+
+// shortIDs keeps recent states behind short ids, so inline buttons refer to them
+// instead of carrying the whole state in size-limited callback data.
+// The oldest states are forgotten once there are more of them than the limit.
+type shortIDs struct {
+	values map[string]interface{}
+	order  []string
+	limit  int
+	nextID int
+	lock   sync.Mutex
+}
+
+func (s *shortIDs) add(value interface{}) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.nextID++
+	id := strconv.Itoa(s.nextID)
+	s.values[id] = value
+	s.order = append(s.order, id)
+
+	if len(s.order) > s.limit {
+		delete(s.values, s.order[0])
+		s.order = s.order[1:]
+	}
+
+	return id
+}
+
+func (s *shortIDs) get(id string) (interface{}, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	value, ok := s.values[id]
+
+	return value, ok
+}
+
+// update replaces the state with the result of the function, updated state is returned
+func (s *shortIDs) update(id string, update func(interface{}) interface{}) (interface{}, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	value, ok := s.values[id]
+	if !ok {
+		return nil, false
+	}
+
+	value = update(value)
+	s.values[id] = value
+
+	return value, true
+}
+
+func (s *shortIDs) remove(id string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.values, id)
+	for index, existing := range s.order {
+		if existing == id {
+			s.order = append(s.order[:index], s.order[index+1:]...)
+			break
+		}
+	}
+}
+
+func newShortIDs(limit int) *shortIDs {
+	return &shortIDs{
+		values: make(map[string]interface{}),
+		limit:  limit,
+	}
+}
@@ -0,0 +1,216 @@
+package telegram
+
+import (
+	"log"
+	"sort"
+
+	"github.com/mitchellh/mapstructure"
+	"gopkg.in/tucnak/telebot.v2"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
+)
+
+// maxSizeSelections limits number of multi-size pickers user may be working with
+const maxSizeSelections = 100
+
+// sizeSelection is a state of multi-size picker: available options and sizes chosen so far
+type sizeSelection struct {
+	Storefront string
+	Article    string
+	Options    []shop.ItemOption
+	Selected   []int
+}
+
+func (s sizeSelection) selected(size int) bool {
+	for _, id := range s.Selected {
+		if id == size {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sizeSelections keeps state of multi-size pickers for their buttons
+type sizeSelections struct {
+	ids *shortIDs
+}
+
+func (s *sizeSelections) add(selection sizeSelection) string {
+	return s.ids.add(selection)
+}
+
+func (s *sizeSelections) get(id string) (sizeSelection, bool) {
+	value, ok := s.ids.get(id)
+	if !ok {
+		return sizeSelection{}, false
+	}
+	selection, ok := value.(sizeSelection)
+
+	return selection, ok
+}
+
+// toggle chooses the size or cancels the choice, updated selection is returned
+func (s *sizeSelections) toggle(id string, size int) (sizeSelection, bool) {
+	value, ok := s.ids.update(id, func(value interface{}) interface{} {
+		selection, ok := value.(sizeSelection)
+		if !ok {
+			return value
+		}
+
+		selected := make([]int, 0, len(selection.Selected)+1)
+		for _, id := range selection.Selected {
+			if id != size {
+				selected = append(selected, id)
+			}
+		}
+		if !selection.selected(size) {
+			selected = append(selected, size)
+		}
+		sort.Ints(selected)
+		selection.Selected = selected
+
+		return selection
+	})
+	if !ok {
+		return sizeSelection{}, false
+	}
+	selection, ok := value.(sizeSelection)
+
+	return selection, ok
+}
+
+func (s *sizeSelections) remove(id string) {
+	s.ids.remove(id)
+}
+
+func newSizeSelections(limit int) *sizeSelections {
+	return &sizeSelections{ids: newShortIDs(limit)}
+}
+
+// onSelectSizesCallback turns the size picker into multi-size one
+func (b *Bot) onSelectSizesCallback(c *telebot.Callback, decodedData map[string]interface{}) {
+	var articleData struct {
+		Article    string
+		Storefront string
+	}
+
+	if err := mapstructure.Decode(decodedData, &articleData); err != nil {
+		log.Println("[ERROR] Could not map the callback data to structure: " + err.Error())
+		return
+	}
+
+	options, err := b.mediator.FetchSizeIDs(articleData.Storefront, articleData.Article)
+	if err != nil {
+		log.Println("[ERROR] Could fetch sized: " + err.Error())
+		if _, err := b.tb.Edit(c.Message, fetchErrorMessage(articleData.Article, err)); err != nil {
+			log.Println("[ERROR] Could not update message: " + err.Error())
+		}
+
+		return
+	}
+
+	selection := sizeSelection{Storefront: articleData.Storefront, Article: articleData.Article, Options: options}
+	b.editSizeSelection(c, b.sizes.add(selection), selection)
+}
+
+// onToggleSizeCallback chooses the size in multi-size picker or cancels the choice
+func (b *Bot) onToggleSizeCallback(c *telebot.Callback, decodedData map[string]interface{}) {
+	var sizeData struct {
+		ID   string
+		Size int
+	}
+
+	if err := mapstructure.Decode(decodedData, &sizeData); err != nil {
+		log.Println("[ERROR] Could not map the callback data to structure: " + err.Error())
+		return
+	}
+
+	selection, ok := b.sizes.toggle(sizeData.ID, sizeData.Size)
+	if !ok {
+		b.editExpiredSizeSelection(c)
+		return
+	}
+
+	b.editSizeSelection(c, sizeData.ID, selection)
+}
+
+// onSizesChosenCallback subscribes to the sizes chosen in multi-size picker
+func (b *Bot) onSizesChosenCallback(c *telebot.Callback, decodedData map[string]interface{}) {
+	var selectionData struct {
+		ID string
+	}
+
+	if err := mapstructure.Decode(decodedData, &selectionData); err != nil {
+		log.Println("[ERROR] Could not map the callback data to structure: " + err.Error())
+		return
+	}
+
+	selection, ok := b.sizes.get(selectionData.ID)
+	if !ok {
+		b.editExpiredSizeSelection(c)
+		return
+	}
+
+	if len(selection.Selected) == 0 {
+		if err := b.tb.Respond(c, &telebot.CallbackResponse{Text: "Select at least one size"}); err != nil {
+			log.Println("[ERROR] Could not respond to callback: " + err.Error())
+		}
+
+		return
+	}
+
+	b.sizes.remove(selectionData.ID)
+
+	shopItem := shop.NewMultiSizeItem(selection.Article, selection.Selected...)
+	shopItem.Storefront = selection.Storefront
+	b.subscribe(c, shopItem)
+}
+
+func (b *Bot) editExpiredSizeSelection(c *telebot.Callback) {
+	if _, err := b.tb.Edit(c.Message, "Size selection has expired, please send the article again"); err != nil {
+		log.Println("[ERROR] Could not update message: " + err.Error())
+	}
+}
+
+func (b *Bot) editSizeSelection(c *telebot.Callback, id string, selection sizeSelection) {
+	messageText := "Select sizes for article " + selection.Article + " (" + selection.Storefront + ") and press Done"
+	if _, err := b.tb.Edit(c.Message, messageText, sizeSelectionMarkup(id, selection)); err != nil {
+		log.Println("[ERROR] Could not update message: " + err.Error())
+	}
+}
+
+// sizeSelectionMarkup builds multi-size picker where chosen sizes are ticked
+func sizeSelectionMarkup(id string, selection sizeSelection) *telebot.ReplyMarkup {
+	markup := &telebot.ReplyMarkup{}
+	rows := make([]telebot.Row, 0, len(selection.Options)+1)
+
+	for _, option := range selection.Options {
+		callbackData := NewCallbackData()
+		callbackData.AddItem("id", id)
+		callbackData.AddItem("size", option.Number)
+		encodedData, err := callbackData.Encode()
+		if err != nil {
+			log.Println("[ERROR] Could not encode button data: " + err.Error())
+			continue
+		}
+
+		title := sizeButtonTitle(option)
+		if selection.selected(option.Number) {
+			title = "✓ " + title
+		}
+		rows = append(rows, markup.Row(markup.Data(title, callbackToggleSize, encodedData)))
+	}
+
+	callbackData := NewCallbackData()
+	callbackData.AddItem("id", id)
+	if encodedData, err := callbackData.Encode(); err != nil {
+		log.Println("[ERROR] Could not encode button data: " + err.Error())
+	} else {
+		rows = append(rows, markup.Row(markup.Data("Done", callbackSizesChosen, encodedData)))
+	}
+
+	markup.Inline(rows...)
+
+	return markup
+}
@@ -0,0 +1,97 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/tucnak/telebot.v2"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/events"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/mediator"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/storage"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/subscription"
+)
+
+func TestSizeSelections_togglesChosenSizes(t *testing.T) {
+	selections := newSizeSelections(2)
+	assert := assert.New(t)
+
+	id := selections.add(sizeSelection{Storefront: "uk", Article: "821585"})
+
+	selection, ok := selections.toggle(id, 12)
+	assert.True(ok)
+	selection, _ = selections.toggle(id, 10)
+	assert.Equal([]int{10, 12}, selection.Selected)
+
+	selection, _ = selections.toggle(id, 12)
+	assert.Equal([]int{10}, selection.Selected)
+
+	selections.remove(id)
+	_, ok = selections.toggle(id, 12)
+	assert.False(ok)
+}
+
+func TestSizeSelectionMarkup_ticksChosenSizes(t *testing.T) {
+	assert := assert.New(t)
+
+	markup := sizeSelectionMarkup("100", sizeSelection{
+		Storefront: "uk",
+		Article:    "821585",
+		Options: []shop.ItemOption{
+			{Number: 10, Name: "EU XS", StockStatus: shop.ItemStatusInStock},
+			{Number: 11, Name: "EU S", StockStatus: shop.ItemStatusInStock},
+		},
+		Selected: []int{11},
+	})
+
+	assert.Len(markup.InlineKeyboard, 3)
+	assert.Equal("EU XS", markup.InlineKeyboard[0][0].Text)
+	assert.Equal("✓ EU S", markup.InlineKeyboard[1][0].Text)
+	assert.Equal("Done", markup.InlineKeyboard[2][0].Text)
+	for _, row := range markup.InlineKeyboard {
+		assert.LessOrEqual(len(row[0].Data), 64)
+	}
+}
+
+func TestSizesDescription(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("sizeID 10", sizesDescription(shop.NewItem("821585", 10)))
+	assert.Equal("any size", sizesDescription(shop.NewMultiSizeItem("821585")))
+	assert.Equal("sizeIDs 10, 12", sizesDescription(shop.NewMultiSizeItem("821585", 12, 10)))
+}
+
+func TestItemButtonData_refersToChosenSizesBySubscription(t *testing.T) {
+	strg := storage.NewMemoryStorage()
+	b := &Bot{mediator: mediator.New(strg, strg, strg, events.NewBus(), nil, nil)}
+	assert := assert.New(t)
+
+	item := shop.NewMultiSizeItem("821585", 10, 11, 12, 13, 14, 15, 16, 17)
+	item.Storefront = "uk"
+	other := shop.NewMultiSizeItem("821585", 10, 11)
+	other.Storefront = "uk"
+	for _, shopItem := range []shop.Item{other, item} {
+		_, err := strg.CreateSubscription(subscription.Item{User: subscription.User{ID: "1"}, ShopItem: shopItem})
+		assert.NoError(err)
+	}
+
+	encodedData, err := itemButtonData(item)
+	assert.NoError(err)
+	assert.LessOrEqual(len("\f"+callbackPriceDrop+"|"+encodedData), 64)
+
+	decodedData, err := NewCallbackData().Decode(encodedData)
+	assert.NoError(err)
+	decoded, ok := b.callbackShopItem(&telebot.Callback{Sender: &telebot.User{ID: 1}}, decodedData)
+	assert.True(ok)
+	assert.Equal(item, decoded)
+
+	single := shop.NewItem("821585", 10)
+	encodedData, err = itemButtonData(single)
+	assert.NoError(err)
+	decodedData, err = NewCallbackData().Decode(encodedData)
+	assert.NoError(err)
+	decoded, ok = b.callbackShopItem(&telebot.Callback{Sender: &telebot.User{ID: 1}}, decodedData)
+	assert.True(ok)
+	assert.Equal(single, decoded)
+}
@@ -1,12 +1,14 @@
 package next
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,25 +21,40 @@ const (
 
 	// EndpointSearch is an endpoint to search items
 	EndpointSearch = "/search"
+
+	// SearchPageSize is a number of products requested per search page
+	SearchPageSize = 5
 )
 
 // Config holds necessary configuration for HTTPClient
 type Config struct {
 	BaseURL string
 	Lang    string
+	// RequestTimeout limits duration of every single attempt of a request to Next API, zero means no limit.
+	// Waiting for the rate limiter and backoff between retries are not included.
+	RequestTimeout time.Duration
+	Retry          RetryConfig
+	CircuitBreaker CircuitBreakerConfig
+	RateLimit      RateLimitConfig
 }
 
 // HTTPClient interface to be implemented by different clients
 type HTTPClient interface {
-	Get(url string) (resp *http.Response, err error)
+	Do(req *http.Request) (*http.Response, error)
 }
 
 type NextClient interface {
 	GetOptionsByArticle(article string) ([]shop.ItemOption, error)
+	GetOptionsByArticleContext(ctx context.Context, article string) ([]shop.ItemOption, error)
 	GetItemOption(article string, size int) (shop.ItemOption, error)
+	GetItemOptionContext(ctx context.Context, article string, size int) (shop.ItemOption, error)
 	GetItemExtendedOption(article string) (shop.ItemExtendedOption, error)
+	GetItemExtendedOptionContext(ctx context.Context, article string) (shop.ItemExtendedOption, error)
 	FindOptionBySize(options []shop.ItemOption, size int) (shop.ItemOption, bool)
 	GetItemURLByArticle(article string) (string, error)
+	GetItemURLByArticleContext(ctx context.Context, article string) (string, error)
+	Search(query string, page int) (shop.SearchResult, error)
+	SearchContext(ctx context.Context, query string, page int) (shop.SearchResult, error)
 }
 
 // Client is a wrapper for some Next APIs
@@ -48,7 +65,11 @@ type Client struct {
 }
 
 func (c *Client) buildEndpointURL(ep string, pathVars ...string) string {
-	endpoint := c.BaseURL + "/" + c.Language + ep
+	endpoint := c.BaseURL + ep
+	if c.Language != "" {
+		endpoint = c.BaseURL + "/" + c.Language + ep
+	}
+
 	if len(pathVars) > 0 {
 		return endpoint + "/" + strings.Join(pathVars, "/")
 	}
@@ -56,9 +77,23 @@ func (c *Client) buildEndpointURL(ep string, pathVars ...string) string {
 	return endpoint
 }
 
+func (c *Client) get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.HTTPClient.Do(req)
+}
+
 // GetOptionsByArticle fetched item options by article
 func (c *Client) GetOptionsByArticle(article string) ([]shop.ItemOption, error) {
-	extendedOptions, err := c.GetItemExtendedOption(article)
+	return c.GetOptionsByArticleContext(context.Background(), article)
+}
+
+// GetOptionsByArticleContext fetches item options by article within given context
+func (c *Client) GetOptionsByArticleContext(ctx context.Context, article string) ([]shop.ItemOption, error) {
+	extendedOptions, err := c.GetItemExtendedOptionContext(ctx, article)
 
 	if err != nil {
 		return nil, err
@@ -69,16 +104,21 @@ func (c *Client) GetOptionsByArticle(article string) ([]shop.ItemOption, error)
 
 // GetItemOption fetches a single option object for article and size combination
 func (c *Client) GetItemOption(article string, size int) (shop.ItemOption, error) {
+	return c.GetItemOptionContext(context.Background(), article, size)
+}
+
+// GetItemOptionContext fetches a single option object for article and size combination within given context
+func (c *Client) GetItemOptionContext(ctx context.Context, article string, size int) (shop.ItemOption, error) {
 	var option shop.ItemOption
 
-	items, err := c.GetOptionsByArticle(article)
+	items, err := c.GetOptionsByArticleContext(ctx, article)
 	if err != nil {
 		return shop.ItemOption{}, err
 	}
 
 	item, found := c.FindOptionBySize(items, size)
 	if !found {
-		return option, errors.New("item not found")
+		return option, &APIError{Op: "get item option", Article: article, Err: ErrSizeNotFound}
 	}
 
 	item.Article = article
@@ -88,25 +128,41 @@ func (c *Client) GetItemOption(article string, size int) (shop.ItemOption, error
 
 // GetItemExtendedOption fetches available options information for particular article
 func (c *Client) GetItemExtendedOption(article string) (shop.ItemExtendedOption, error) {
+	return c.GetItemExtendedOptionContext(context.Background(), article)
+}
+
+// GetItemExtendedOptionContext fetches available options information for particular article within given context
+func (c *Client) GetItemExtendedOptionContext(ctx context.Context, article string) (shop.ItemExtendedOption, error) {
+	const op = "get extended options"
+
 	url := fmt.Sprintf("%s?_=%d", c.buildEndpointURL(EndpointGetExtendedOptions, article), time.Now().Unix())
 	var resp *http.Response
 	var err error
-	if resp, err = c.HTTPClient.Get(url); err != nil {
-		return shop.ItemExtendedOption{},
-			fmt.Errorf("couldn't get extended options for <%s> article: %s", article, err.Error())
+	if resp, err = c.get(ctx, url); err != nil {
+		return shop.ItemExtendedOption{}, &APIError{Op: op, Article: article, Err: &TransportError{Err: err}}
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		drainBody(resp.Body)
+		return shop.ItemExtendedOption{}, &APIError{Op: op, Article: article, Err: newStatusError(resp)}
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
 	if err != nil {
-		return shop.ItemExtendedOption{}, errors.New("can't read the response body")
+		return shop.ItemExtendedOption{}, &APIError{Op: op, Article: article, Err: &TransportError{Err: err}}
 	}
-	resp.Body.Close()
 
 	bodyString := string(body)
 
 	var optionResponse shop.ItemExtendedOption
 	if err := json.NewDecoder(strings.NewReader(bodyString)).Decode(&optionResponse); err != nil {
-		return shop.ItemExtendedOption{}, err
+		return shop.ItemExtendedOption{}, &APIError{Op: op, Article: article, Err: &DecodeError{Err: err}}
+	}
+
+	// unknown and discontinued articles come back without any option
+	if len(optionResponse.Options) == 0 {
+		return shop.ItemExtendedOption{}, &APIError{Op: op, Article: article, Err: ErrItemNotFound}
 	}
 
 	return optionResponse, nil
@@ -123,26 +179,130 @@ func (c *Client) FindOptionBySize(options []shop.ItemOption, size int) (shop.Ite
 }
 
 func (c *Client) GetItemURLByArticle(article string) (string, error) {
+	return c.GetItemURLByArticleContext(context.Background(), article)
+}
+
+// GetItemURLByArticleContext resolves item page URL by its article within given context
+func (c *Client) GetItemURLByArticleContext(ctx context.Context, article string) (string, error) {
+	const op = "get item URL"
+
 	url := fmt.Sprintf("%s?w=%s", c.buildEndpointURL(EndpointSearch), url.QueryEscape(article))
-	response, err := c.HTTPClient.Get(url)
+	response, err := c.get(ctx, url)
 
 	if err != nil {
-		return "", err
+		return "", &APIError{Op: op, Article: article, Err: &TransportError{Err: err}}
 	}
 	defer response.Body.Close()
 
-	if response.StatusCode == 200 && response.Request != nil && response.Request.URL.Fragment == article {
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return "", &APIError{Op: op, Article: article, Err: newStatusError(response)}
+	}
+
+	if response.Request != nil && response.Request.URL.Fragment == article {
 		return response.Request.URL.String(), nil
 	}
 
-	return "", errors.New("invalid URL format in response")
+	return "", &APIError{Op: op, Article: article, Err: errors.New("invalid URL format in response")}
 }
 
-// NewClient creates a Next client
+// Search searches products by free-text query, pages are numbered from 1
+func (c *Client) Search(query string, page int) (shop.SearchResult, error) {
+	return c.SearchContext(context.Background(), query, page)
+}
+
+// SearchContext searches products by free-text query within given context, pages are numbered from 1.
+// Search endpoint responds with JSON instead of HTML page when it is explicitly requested.
+func (c *Client) SearchContext(ctx context.Context, query string, page int) (shop.SearchResult, error) {
+	const op = "search"
+
+	if page < 1 {
+		page = 1
+	}
+
+	params := url.Values{}
+	params.Set("w", query)
+	params.Set("srt", strconv.Itoa((page-1)*SearchPageSize))
+	params.Set("pagesize", strconv.Itoa(SearchPageSize))
+
+	searchURL := c.buildEndpointURL(EndpointSearch) + "?" + params.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, searchURL, nil)
+	if err != nil {
+		return shop.SearchResult{}, &APIError{Op: op, Article: query, Err: err}
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return shop.SearchResult{}, &APIError{Op: op, Article: query, Err: &TransportError{Err: err}}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return shop.SearchResult{}, &APIError{Op: op, Article: query, Err: newStatusError(resp)}
+	}
+
+	var searchResponse struct {
+		TotalResults int
+		Items        []struct {
+			ItemNumber string
+			Title      string
+			Price      string
+			URL        string `json:"Url"`
+			ImageURL   string `json:"ImageUrl"`
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&searchResponse); err != nil {
+		return shop.SearchResult{}, &APIError{Op: op, Article: query, Err: &DecodeError{Err: err}}
+	}
+
+	result := shop.SearchResult{
+		Products: make([]shop.Product, 0, len(searchResponse.Items)),
+		Total:    searchResponse.TotalResults,
+		Page:     page,
+		PageSize: SearchPageSize,
+	}
+	for _, item := range searchResponse.Items {
+		result.Products = append(result.Products, shop.Product{
+			Article:  shop.NormalizeArticle(item.ItemNumber),
+			Title:    item.Title,
+			Price:    item.Price,
+			URL:      item.URL,
+			ImageURL: item.ImageURL,
+		})
+	}
+
+	return result, nil
+}
+
+// NewClient creates a Next client with its own rate limiter
 func NewClient(httpClient HTTPClient, c Config) *Client {
+	return NewClientWithLimiter(httpClient, c, NewLimiter(c.RateLimit))
+}
+
+// NewClientWithLimiter creates a Next client sharing the limiter with other clients,
+// nil limiter disables rate limiting
+func NewClientWithLimiter(httpClient HTTPClient, c Config, limiter *Limiter) *Client {
 	if httpClient == nil {
 		httpClient = http.DefaultClient
 	}
 
-	return &Client{HTTPClient: httpClient, BaseURL: c.BaseURL, Language: c.Lang}
+	// every attempt gets its own timeout once the limiter lets it through,
+	// so neither waiting for the limiter nor retries eat into it
+	if c.RequestTimeout > 0 {
+		httpClient = NewTimeoutHTTPClient(httpClient, c.RequestTimeout)
+	}
+
+	if limiter != nil {
+		httpClient = NewLimitedHTTPClient(httpClient, limiter)
+	}
+
+	if c.Retry.MaxAttempts > 1 || c.CircuitBreaker.FailureThreshold > 0 {
+		httpClient = NewResilientHTTPClient(httpClient, c.Retry, c.CircuitBreaker)
+	}
+
+	return &Client{
+		HTTPClient: httpClient,
+		BaseURL:    c.BaseURL,
+		Language:   c.Lang,
+	}
 }
@@ -1,8 +1,12 @@
 package next
 
 import (
+	"context"
+	"io/ioutil"
 	"net/http"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next/testutils"
@@ -144,11 +148,12 @@ func TestGetItemInfo(t *testing.T) {
 	assert.NotNil(option)
 	assert.EqualValues(
 		shop.ItemOption{
-			Article:           "821-585",
-			Name:              "EU S стандартный",
-			Number:            11,
-			Price:             "635 грн",
-			StockStatusString: "ComingSoon",
+			Article:      "821-585",
+			Name:         "EU S стандартный",
+			Number:       11,
+			Price:        "635 грн",
+			StockStatus:  shop.ItemStatusComingSoon,
+			StockMessage: "середина января",
 		},
 		option,
 	)
@@ -224,3 +229,148 @@ func TestNewClient_useProvidedClient(t *testing.T) {
 	)
 	assert.IsType(t, mockedHTTPClient, client.HTTPClient)
 }
+
+func TestGetItemExtendedOptionContext_respectsRequestTimeout(t *testing.T) {
+	client := NewClient(
+		testutils.NewClientWithHandler(func(req *http.Request) (*http.Response, error) {
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		}),
+		Config{
+			BaseURL:        "https://www.next.ua",
+			Lang:           "ru",
+			RequestTimeout: 10 * time.Millisecond,
+		},
+	)
+
+	_, err := client.GetItemExtendedOptionContext(context.Background(), "821585")
+	assert.Error(t, err)
+}
+
+func TestGetItemExtendedOptionContext_limitsEveryAttemptWithRequestTimeout(t *testing.T) {
+	attempts := 0
+	client := NewClient(
+		testutils.NewClientWithHandler(func(req *http.Request) (*http.Response, error) {
+			attempts++
+			if attempts == 1 {
+				<-req.Context().Done()
+				return nil, req.Context().Err()
+			}
+
+			// the retry has got its own timeout, not what is left after the first attempt
+			time.Sleep(20 * time.Millisecond)
+			return testutils.NewResponse(http.StatusOK, `{"Options": [{"OptionNumber": "10"}]}`), nil
+		}),
+		Config{
+			BaseURL:        "https://www.next.ua",
+			Lang:           "ru",
+			RequestTimeout: 30 * time.Millisecond,
+			Retry:          RetryConfig{MaxAttempts: 2, InitialBackoff: 20 * time.Millisecond},
+		},
+	)
+
+	_, err := client.GetItemExtendedOptionContext(context.Background(), "821585")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+}
+
+func TestGetItemExtendedOptionContext_stopsOnCancellation(t *testing.T) {
+	client := NewClient(
+		testutils.NewClientWithHandler(func(req *http.Request) (*http.Response, error) {
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		}),
+		Config{
+			BaseURL: "https://www.next.ua",
+			Lang:    "ru",
+		},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go cancel()
+
+	_, err := client.GetItemExtendedOptionContext(ctx, "821585")
+	assert.Error(t, err)
+}
+
+func TestSearch(t *testing.T) {
+	payload := `
+	{
+		"TotalResults": 7,
+		"Items": [
+			{
+				"ItemNumber": "821-585",
+				"Title": "Розовая в цветочек - Теплая пижама",
+				"Price": "635 грн",
+				"Url": "https://www.next.ua/ru/style/st123456/821585",
+				"ImageUrl": "https://xcdn.next.co.uk/common/items/default/default/itemimages/search/224/821585.jpg"
+			}
+		]
+	}`
+
+	var requestedURL *url.URL
+	client := NewClient(
+		testutils.NewClientWithHandler(func(req *http.Request) (*http.Response, error) {
+			requestedURL = req.URL
+			return testutils.NewResponse(http.StatusOK, payload), nil
+		}),
+		Config{
+			BaseURL: "https://www.next.ua",
+			Lang:    "ru",
+		},
+	)
+
+	result, err := client.Search("pyjamas", 2)
+
+	assert := assert.New(t)
+	assert.NoError(err)
+	assert.Equal("/ru/search", requestedURL.Path)
+	assert.Equal("pyjamas", requestedURL.Query().Get("w"))
+	assert.Equal("5", requestedURL.Query().Get("srt"))
+	assert.Equal(7, result.Total)
+	assert.Equal(2, result.Page)
+	assert.False(result.HasNextPage())
+	assert.Equal(
+		[]shop.Product{
+			{
+				Article:  "821585",
+				Title:    "Розовая в цветочек - Теплая пижама",
+				Price:    "635 грн",
+				URL:      "https://www.next.ua/ru/style/st123456/821585",
+				ImageURL: "https://xcdn.next.co.uk/common/items/default/default/itemimages/search/224/821585.jpg",
+			},
+		},
+		result.Products,
+	)
+}
+
+func TestSearch_decodesSearchResponse(t *testing.T) {
+	payload, err := ioutil.ReadFile("testdata/search.json")
+	assert := assert.New(t)
+	assert.NoError(err)
+
+	client := NewClient(testutils.NewClientWithPayload(string(payload)), Config{BaseURL: "https://www.next.ua"})
+	result, err := client.Search("пижама", 1)
+
+	assert.NoError(err)
+	assert.Equal(137, result.Total)
+	assert.True(result.HasNextPage())
+	assert.Equal(
+		[]shop.Product{
+			{
+				Article:  "821585",
+				Title:    "Розовая в цветочек - Теплая пижама (9 мес. - 12 лет)",
+				Price:    "635 - 905 грн",
+				URL:      "https://www.next.ua/ru/style/st123456/821585",
+				ImageURL: "https://xcdn.next.co.uk/common/items/default/default/itemimages/search/224/821585.jpg",
+			},
+			{
+				Article: "c12345",
+				Title:   "Серый - Пижама с шортами из трикотажа",
+				Price:   "545 грн",
+				URL:     "https://www.next.ua/ru/style/st654321/c12345",
+			},
+		},
+		result.Products,
+	)
+}
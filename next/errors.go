@@ -1,4 +1,124 @@
 package next
 
-// APIError represents various Next API errors which may occur
-type APIError error
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrItemNotFound is returned when Next does not know the article, e.g. it was discontinued
+	ErrItemNotFound = errors.New("item not found")
+
+	// ErrSizeNotFound is returned when the article exists, but has no requested size
+	ErrSizeNotFound = errors.New("size not found")
+
+	// ErrRateLimited is returned when Next asks to slow down
+	ErrRateLimited = errors.New("rate limited")
+)
+
+// APIError represents various Next API errors which may occur.
+// The underlying reason is available with errors.Is and errors.As.
+type APIError struct {
+	Op string
+	// Article holds the article or the search query the request was made for
+	Article string
+	Err     error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("couldn't %s for <%s>: %s", e.Op, e.Article, e.Err.Error())
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// StatusError is returned on unexpected HTTP status code
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d", e.StatusCode)
+}
+
+// Is reports 404 responses as ErrItemNotFound and 429 ones as ErrRateLimited
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrItemNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+
+	return false
+}
+
+// RateLimitError is returned when Next responds with 429 status code
+type RateLimitError struct {
+	// RetryAfter holds a delay requested by Next, zero if it was not provided
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("%s, retry after %s", ErrRateLimited.Error(), e.RetryAfter)
+	}
+
+	return ErrRateLimited.Error()
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return &StatusError{StatusCode: http.StatusTooManyRequests}
+}
+
+// DecodeError is returned when response does not match expected schema
+type DecodeError struct {
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return "can't decode the response: " + e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// TransportError is returned when request could not be performed or response could not be read
+type TransportError struct {
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return "transport error: " + e.Err.Error()
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// LimiterError is returned when the request was given up while waiting for the rate limiter, it was not sent
+type LimiterError struct {
+	Err error
+}
+
+func (e *LimiterError) Error() string {
+	return "gave up waiting for rate limiter: " + e.Err.Error()
+}
+
+func (e *LimiterError) Unwrap() error {
+	return e.Err
+}
+
+// newStatusError builds an error for unsuccessful response
+func newStatusError(resp *http.Response) error {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		retryAfter, _ := parseRetryAfter(resp.Header.Get("Retry-After"))
+		return &RateLimitError{RetryAfter: retryAfter}
+	}
+
+	return &StatusError{StatusCode: resp.StatusCode}
+}
@@ -0,0 +1,99 @@
+package next
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/testutils"
+)
+
+func newClientWithResponse(resp *http.Response, err error) *Client {
+	return NewClient(
+		testutils.NewClientWithHandler(func(req *http.Request) (*http.Response, error) {
+			return resp, err
+		}),
+		Config{
+			BaseURL: "https://www.next.ua",
+			Lang:    "ru",
+		},
+	)
+}
+
+func TestGetItemExtendedOption_notFoundStatus(t *testing.T) {
+	client := newClientWithResponse(testutils.NewResponse(http.StatusNotFound, ""), nil)
+
+	_, err := client.GetItemExtendedOption("821585")
+
+	var statusErr *StatusError
+	assert := assert.New(t)
+	assert.True(errors.Is(err, ErrItemNotFound))
+	assert.True(errors.As(err, &statusErr))
+	assert.Equal(http.StatusNotFound, statusErr.StatusCode)
+}
+
+func TestGetItemExtendedOption_emptyOptionsMeansNotFound(t *testing.T) {
+	client := newClientWithResponse(testutils.NewResponse(http.StatusOK, `{"Description": "", "Options": []}`), nil)
+
+	_, err := client.GetItemExtendedOption("821585")
+	assert.True(t, errors.Is(err, ErrItemNotFound))
+}
+
+func TestGetItemExtendedOption_rateLimited(t *testing.T) {
+	resp := testutils.NewResponse(http.StatusTooManyRequests, "")
+	resp.Header.Set("Retry-After", "30")
+	client := newClientWithResponse(resp, nil)
+
+	_, err := client.GetItemExtendedOption("821585")
+
+	var rateLimitErr *RateLimitError
+	var statusErr *StatusError
+	assert := assert.New(t)
+	assert.True(errors.Is(err, ErrRateLimited))
+	assert.True(errors.As(err, &rateLimitErr))
+	assert.Equal(30*time.Second, rateLimitErr.RetryAfter)
+	assert.True(errors.As(err, &statusErr))
+	assert.False(errors.Is(err, ErrItemNotFound))
+}
+
+func TestGetItemExtendedOption_decodeError(t *testing.T) {
+	client := newClientWithResponse(testutils.NewResponse(http.StatusOK, `{"Options": "unexpected"}`), nil)
+
+	_, err := client.GetItemExtendedOption("821585")
+
+	var decodeErr *DecodeError
+	assert.True(t, errors.As(err, &decodeErr))
+}
+
+func TestGetItemExtendedOption_transportError(t *testing.T) {
+	networkErr := errors.New("connection reset by peer")
+	client := newClientWithResponse(nil, networkErr)
+
+	_, err := client.GetItemExtendedOption("821585")
+
+	var transportErr *TransportError
+	var apiErr *APIError
+	assert := assert.New(t)
+	assert.True(errors.As(err, &transportErr))
+	assert.True(errors.Is(err, networkErr))
+	assert.True(errors.As(err, &apiErr))
+	assert.Equal("821585", apiErr.Article)
+}
+
+func TestGetItemOption_sizeNotFound(t *testing.T) {
+	client := newClientWithResponse(
+		testutils.NewResponse(
+			http.StatusOK,
+			`{"Options": [{"OptionNumber": "10", "StockStatus": "InStock", "OptionName": "EU XS", "Price": "635 грн"}]}`,
+		),
+		nil,
+	)
+
+	_, err := client.GetItemOption("821585", 11)
+
+	assert.True(t, errors.Is(err, ErrSizeNotFound))
+	assert.False(t, errors.Is(err, ErrItemNotFound))
+}
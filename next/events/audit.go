@@ -0,0 +1,66 @@
+package events
+
+import (
+	"expvar"
+	"fmt"
+	"log"
+)
+
+var publishedEvents = expvar.NewMap("next_events")
+
+// Audit logs events which change subscriptions or reach users
+func Audit(event Event) {
+	if _, ok := event.(ItemObserved); ok {
+		// every check is observed, the log would be flooded with them
+		return
+	}
+
+	log.Printf("[INFO] audit: %s\n", Describe(event))
+}
+
+// Count counts events by their names, counters are exposed through expvar map "next_events"
+func Count(event Event) {
+	publishedEvents.Add(event.EventName(), 1)
+}
+
+// Describe builds human readable description of the event
+func Describe(event Event) string {
+	switch e := event.(type) {
+	case SubscriptionCreated:
+		return fmt.Sprintf("user <%s> subscribed to %v", e.Subscription.User.ID, e.Subscription.ShopItem)
+	case SubscriptionRemoved:
+		return fmt.Sprintf("user <%s> unsubscribed from %v", e.Subscription.User.ID, e.Subscription.ShopItem)
+	case SubscriptionDisabled:
+		return fmt.Sprintf("subscription of user <%s> to %v is disabled: %s",
+			e.Subscription.User.ID,
+			e.Subscription.ShopItem,
+			e.Reason,
+		)
+	case SubscriptionEnabled:
+		return fmt.Sprintf("subscription of user <%s> to %v is enabled", e.Subscription.User.ID, e.Subscription.ShopItem)
+	case StatusChanged:
+		return fmt.Sprintf("status of %v changed from %s to %s", e.Item, e.From, e.To)
+	case ItemObserved:
+		return fmt.Sprintf("%v is observed in status %s", e.Item, e.Option.StockStatus)
+	case NotificationSent:
+		return fmt.Sprintf("%s notification delivered to user <%s> after %d failed attempt(s)",
+			e.Notification.Kind,
+			e.Notification.Item.User.ID,
+			e.Attempts,
+		)
+	case NotificationFailed:
+		description := fmt.Sprintf("%s notification to user <%s> failed %d time(s): %s",
+			e.Notification.Kind,
+			e.Notification.Item.User.ID,
+			e.Attempts,
+			e.Error,
+		)
+		if e.GaveUp {
+			description += ", giving up"
+		}
+
+		return description
+	}
+
+	return event.EventName()
+}
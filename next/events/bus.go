@@ -0,0 +1,116 @@
+package events
+
+import (
+	"log"
+	"sync"
+)
+
+// subscriberBuffer is how many events may wait for a slow subscriber before new ones are dropped
+const subscriberBuffer = 100
+
+// Handler processes events delivered to the subscriber
+type Handler func(Event)
+
+type subscriber struct {
+	name   string
+	events chan Event
+	// blocking subscriber makes the publisher wait instead of dropping events
+	blocking bool
+}
+
+// Bus delivers published events to every subscriber. Subscribers are independent:
+// each one processes events in its own goroutine, so a slow or failing one does not affect others.
+type Bus struct {
+	subscribers []*subscriber
+	lock        sync.RWMutex
+	running     sync.WaitGroup
+	closed      bool
+}
+
+// Subscribe attaches the handler to the bus, it receives events published from now on in publishing order
+func (b *Bus) Subscribe(name string, handler Handler) {
+	b.subscribe(name, handler, false)
+}
+
+// SubscribeBlocking attaches the handler which must receive every event,
+// the publisher waits for it to catch up instead of dropping events
+func (b *Bus) SubscribeBlocking(name string, handler Handler) {
+	b.subscribe(name, handler, true)
+}
+
+func (b *Bus) subscribe(name string, handler Handler, blocking bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.closed {
+		log.Printf("[WARN] events: bus is closed, subscriber '%s' is ignored\n", name)
+		return
+	}
+
+	s := &subscriber{name: name, events: make(chan Event, subscriberBuffer), blocking: blocking}
+	b.subscribers = append(b.subscribers, s)
+
+	b.running.Add(1)
+	go func() {
+		defer b.running.Done()
+		for event := range s.events {
+			handle(s.name, handler, event)
+		}
+	}()
+}
+
+// handle runs the handler, its panic is logged and does not stop the subscriber
+func handle(name string, handler Handler, event Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[ERROR] events: subscriber '%s' failed on %s: %v\n", name, event.EventName(), r)
+		}
+	}()
+
+	handler(event)
+}
+
+// Publish hands the event over to subscribers without waiting for them,
+// the event is dropped for subscribers which are too far behind unless they are blocking ones
+func (b *Bus) Publish(event Event) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	if b.closed {
+		return
+	}
+
+	for _, s := range b.subscribers {
+		if s.blocking {
+			s.events <- event
+			continue
+		}
+
+		select {
+		case s.events <- event:
+		default:
+			log.Printf("[WARN] events: subscriber '%s' is too slow, %s is dropped\n", s.name, event.EventName())
+		}
+	}
+}
+
+// Close stops accepting events and waits until subscribers process the published ones
+func (b *Bus) Close() {
+	b.lock.Lock()
+	if b.closed {
+		b.lock.Unlock()
+		return
+	}
+	b.closed = true
+	for _, s := range b.subscribers {
+		close(s.events)
+	}
+	b.lock.Unlock()
+
+	b.running.Wait()
+}
+
+// NewBus instantiates Bus without subscribers
+func NewBus() *Bus {
+	return &Bus{}
+}
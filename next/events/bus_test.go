@@ -0,0 +1,86 @@
+package events
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/subscription"
+)
+
+func TestBus_deliversEventsToEverySubscriber(t *testing.T) {
+	assert := assert.New(t)
+	bus := NewBus()
+
+	var lock sync.Mutex
+	received := make(map[string][]string)
+	record := func(name string) Handler {
+		return func(event Event) {
+			lock.Lock()
+			defer lock.Unlock()
+			received[name] = append(received[name], event.EventName())
+		}
+	}
+	bus.Subscribe("first", record("first"))
+	bus.Subscribe("failing", func(Event) {
+		panic("subscriber is broken")
+	})
+	bus.Subscribe("second", record("second"))
+
+	bus.Publish(SubscriptionCreated{Subscription: subscription.Item{User: subscription.User{ID: "user-1"}}})
+	bus.Publish(StatusChanged{Item: shop.NewItem("111222", 10), From: shop.ItemStatusSoldOut, To: shop.ItemStatusInStock})
+	bus.Close()
+
+	expected := []string{"subscription_created", "status_changed"}
+	assert.Equal(map[string][]string{"first": expected, "second": expected}, received)
+
+	bus.Publish(SubscriptionRemoved{Time: time.Now()})
+	assert.Len(received["first"], 2, "closed bus does not deliver events")
+}
+
+func TestBus_blockingSubscriberGetsEveryEvent(t *testing.T) {
+	assert := assert.New(t)
+	bus := NewBus()
+
+	release := make(chan struct{})
+	var received int
+	bus.SubscribeBlocking("history", func(Event) {
+		<-release
+		received++
+	})
+
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		for i := 0; i < 2*subscriberBuffer; i++ {
+			bus.Publish(SubscriptionRemoved{Time: time.Now()})
+		}
+	}()
+	// the subscriber is stuck while its buffer overflows
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	<-published
+	bus.Close()
+
+	assert.Equal(2*subscriberBuffer, received)
+}
+
+func TestDescribe(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(
+		"in_stock notification to user <user-1> failed 10 time(s): Forbidden, giving up",
+		Describe(NotificationFailed{
+			Notification: subscription.Notification{
+				Kind: subscription.NotificationInStock,
+				Item: subscription.Item{User: subscription.User{ID: "user-1"}},
+			},
+			Attempts: 10,
+			Error:    "Forbidden",
+			GaveUp:   true,
+		}),
+	)
+}
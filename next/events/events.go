@@ -0,0 +1,92 @@
+package events
+
+import (
+	"time"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/subscription"
+)
+
+// Event is something which has happened in the system, its type tells what exactly
+type Event interface {
+	EventName() string
+}
+
+// Publisher publishes events to whoever is interested in them
+type Publisher interface {
+	Publish(Event)
+}
+
+// SubscriptionCreated is published when user subscribes to an item
+type SubscriptionCreated struct {
+	Subscription subscription.Item
+	Time         time.Time
+}
+
+func (SubscriptionCreated) EventName() string { return "subscription_created" }
+
+// SubscriptionRemoved is published when user removes the subscription
+type SubscriptionRemoved struct {
+	Subscription subscription.Item
+	Time         time.Time
+}
+
+func (SubscriptionRemoved) EventName() string { return "subscription_removed" }
+
+// SubscriptionDisabled is published when the subscription stops being watched, e.g. once the item is in stock
+type SubscriptionDisabled struct {
+	Subscription subscription.Item
+	Reason       string
+	Time         time.Time
+}
+
+func (SubscriptionDisabled) EventName() string { return "subscription_disabled" }
+
+// SubscriptionEnabled is published when disabled subscription is watched again
+type SubscriptionEnabled struct {
+	Subscription subscription.Item
+	Time         time.Time
+}
+
+func (SubscriptionEnabled) EventName() string { return "subscription_enabled" }
+
+// StatusChanged is published when stock status of watched item changes
+type StatusChanged struct {
+	Item  shop.Item
+	From  shop.StockStatus
+	To    shop.StockStatus
+	Price shop.Money
+	Time  time.Time
+}
+
+func (StatusChanged) EventName() string { return "status_changed" }
+
+// ItemObserved is published on every check of watched item, whether its status changed or not
+type ItemObserved struct {
+	Item   shop.Item
+	Option shop.ItemOption
+	Price  shop.Money
+	Time   time.Time
+}
+
+func (ItemObserved) EventName() string { return "item_observed" }
+
+// NotificationSent is published when the notification is delivered to its recipient
+type NotificationSent struct {
+	Notification subscription.Notification
+	Attempts     int
+	Time         time.Time
+}
+
+func (NotificationSent) EventName() string { return "notification_sent" }
+
+// NotificationFailed is published when delivery attempt fails, GaveUp tells whether it was the last attempt
+type NotificationFailed struct {
+	Notification subscription.Notification
+	Attempts     int
+	Error        string
+	GaveUp       bool
+	Time         time.Time
+}
+
+func (NotificationFailed) EventName() string { return "notification_failed" }
@@ -0,0 +1,16 @@
+package history
+
+import "time"
+
+const (
+	defaultRetention       = 30 * 24 * time.Hour
+	defaultCleanupInterval = time.Hour
+)
+
+// Config holds configuration of stock history recording
+type Config struct {
+	// Retention is how long records are kept
+	Retention time.Duration
+	// CleanupInterval is how often records older than Retention are removed
+	CleanupInterval time.Duration
+}
@@ -0,0 +1,54 @@
+package history
+
+import (
+	"time"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
+)
+
+// Record is what the watcher saw during one check of a particular size of the article
+type Record struct {
+	Storefront   string
+	Article      string
+	SizeID       int
+	SizeName     string
+	Status       shop.StockStatus
+	Price        shop.Money
+	StockMessage string
+	Time         time.Time
+}
+
+// ReadLimit is how many latest records of the article are read at most
+const ReadLimit = 1000
+
+// Store persists stock history
+type Store interface {
+	AddHistoryRecord(Record) error
+	// ReadHistory reads up to ReadLimit latest records of the article at every storefront, the oldest go first
+	ReadHistory(article string) ([]Record, error)
+	// RemoveHistoryBefore removes records older than given time, number of removed records is returned
+	RemoveHistoryBefore(time.Time) (int64, error)
+}
+
+// Changes reduces the timeline to records where stock status of the size changed,
+// the first record of every size is kept as a starting point
+func Changes(records []Record) []Record {
+	type sizeKey struct {
+		Storefront string
+		SizeID     int
+	}
+
+	statuses := make(map[sizeKey]shop.StockStatus)
+	changes := make([]Record, 0)
+	for _, record := range records {
+		key := sizeKey{Storefront: record.Storefront, SizeID: record.SizeID}
+		if status, ok := statuses[key]; ok && status == record.Status {
+			continue
+		}
+
+		statuses[key] = record.Status
+		changes = append(changes, record)
+	}
+
+	return changes
+}
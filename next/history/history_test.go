@@ -0,0 +1,29 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
+)
+
+func TestChanges_keepsRecordsWhereStatusOfSizeChanged(t *testing.T) {
+	now := time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC)
+	records := []Record{
+		{Storefront: "uk", Article: "111222", SizeID: 10, Status: shop.ItemStatusSoldOut, Time: now},
+		{Storefront: "uk", Article: "111222", SizeID: 11, Status: shop.ItemStatusInStock, Time: now},
+		{Storefront: "uk", Article: "111222", SizeID: 10, Status: shop.ItemStatusSoldOut, Time: now.Add(time.Hour)},
+		{Storefront: "uk", Article: "111222", SizeID: 10, Status: shop.ItemStatusInStock, Time: now.Add(2 * time.Hour)},
+		{Storefront: "uk", Article: "111222", SizeID: 11, Status: shop.ItemStatusInStock, Time: now.Add(2 * time.Hour)},
+		{Storefront: "ua", Article: "111222", SizeID: 10, Status: shop.ItemStatusInStock, Time: now.Add(2 * time.Hour)},
+		{Storefront: "uk", Article: "111222", SizeID: 10, Status: shop.ItemStatusSoldOut, Time: now.Add(3 * time.Hour)},
+	}
+
+	assert.Equal(
+		t,
+		[]Record{records[0], records[1], records[3], records[5], records[6]},
+		Changes(records),
+	)
+}
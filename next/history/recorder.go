@@ -0,0 +1,133 @@
+package history
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/events"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
+)
+
+// Recorder persists observations published on the event bus and removes records past the retention
+type Recorder struct {
+	store           Store
+	retention       time.Duration
+	cleanupInterval time.Duration
+	stopCh          chan struct{}
+	stopOnce        sync.Once
+	// last holds the latest recorded observation of every size, it is used by the bus goroutine only
+	last map[sizeKey]Record
+}
+
+// sizeKey identifies particular size of the article at the storefront
+type sizeKey struct {
+	Storefront string
+	Article    string
+	SizeID     int
+}
+
+// Handle is an event bus handler, observation is recorded when stock status, price or restock estimate
+// of the size differ from the previously recorded ones. Unchanged observation is recorded again once
+// the previous record gets half the retention old, so the current state of watched size is never cleaned up.
+func (r *Recorder) Handle(event events.Event) {
+	switch e := event.(type) {
+	case events.ItemObserved:
+		r.record(e)
+	case events.SubscriptionRemoved:
+		r.forget(e.Subscription.ShopItem)
+	case events.SubscriptionDisabled:
+		r.forget(e.Subscription.ShopItem)
+	}
+}
+
+func (r *Recorder) record(observed events.ItemObserved) {
+	record := Record{
+		Storefront:   observed.Item.Storefront,
+		Article:      observed.Item.Article,
+		SizeID:       observed.Item.SizeID,
+		SizeName:     observed.Option.Name,
+		Status:       observed.Option.StockStatus,
+		Price:        observed.Price,
+		StockMessage: observed.Option.StockMessage,
+		Time:         observed.Time,
+	}
+
+	key := sizeKey{Storefront: record.Storefront, Article: record.Article, SizeID: record.SizeID}
+	if last, ok := r.last[key]; ok && record.Time.Sub(last.Time) < r.retention/2 &&
+		last.Status == record.Status && last.Price == record.Price && last.StockMessage == record.StockMessage {
+		return
+	}
+
+	if err := r.store.AddHistoryRecord(record); err != nil {
+		log.Printf("[ERROR] history: could not record observation of %v: %s\n", observed.Item, err.Error())
+		return
+	}
+	r.last[key] = record
+}
+
+// forget drops the latest recorded observations of the article, so they do not pile up once it is not watched.
+// Sizes still watched by other subscriptions just get their next observation recorded.
+func (r *Recorder) forget(item shop.Item) {
+	for key := range r.last {
+		if key.Storefront == item.Storefront && key.Article == item.Article {
+			delete(r.last, key)
+		}
+	}
+}
+
+// Start runs the cleanup loop until Stop is called
+func (r *Recorder) Start() {
+	ticker := time.NewTicker(r.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			r.cleanup(now)
+		case <-r.stopCh:
+			return
+		}
+	}
+}
+
+// Stop stops the cleanup loop, observations are still recorded while the bus is open
+func (r *Recorder) Stop() {
+	r.stopOnce.Do(func() {
+		log.Println("[INFO] Stopping stock history cleanup")
+		close(r.stopCh)
+	})
+}
+
+// cleanup removes records which are older than the retention
+func (r *Recorder) cleanup(now time.Time) {
+	removed, err := r.store.RemoveHistoryBefore(now.Add(-r.retention))
+	if err != nil {
+		log.Printf("[ERROR] history: could not remove outdated records: %s\n", err.Error())
+		return
+	}
+
+	if removed > 0 {
+		log.Printf("[INFO] history: %d outdated record(s) removed\n", removed)
+	}
+}
+
+// NewRecorder instantiates Recorder keeping stock history in the store
+func NewRecorder(store Store, config *Config) *Recorder {
+	r := &Recorder{
+		store:           store,
+		retention:       config.Retention,
+		cleanupInterval: config.CleanupInterval,
+		stopCh:          make(chan struct{}),
+		last:            make(map[sizeKey]Record),
+	}
+
+	if r.retention <= 0 {
+		r.retention = defaultRetention
+	}
+	if r.cleanupInterval <= 0 {
+		r.cleanupInterval = defaultCleanupInterval
+	}
+
+	return r
+}
@@ -0,0 +1,175 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/events"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/subscription"
+)
+
+type fakeStore struct {
+	records []Record
+}
+
+func (s *fakeStore) AddHistoryRecord(record Record) error {
+	s.records = append(s.records, record)
+
+	return nil
+}
+
+func (s *fakeStore) ReadHistory(article string) ([]Record, error) {
+	var ret []Record
+	for _, record := range s.records {
+		if record.Article == article {
+			ret = append(ret, record)
+		}
+	}
+
+	return ret, nil
+}
+
+func (s *fakeStore) RemoveHistoryBefore(before time.Time) (int64, error) {
+	var kept []Record
+	for _, record := range s.records {
+		if !record.Time.Before(before) {
+			kept = append(kept, record)
+		}
+	}
+	removed := int64(len(s.records) - len(kept))
+	s.records = kept
+
+	return removed, nil
+}
+
+func TestRecorder_recordsObservations(t *testing.T) {
+	store := &fakeStore{}
+	recorder := NewRecorder(store, &Config{})
+	now := time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	recorder.Handle(events.StatusChanged{Item: shop.Item{Article: "111222", SizeID: 10}, Time: now})
+	recorder.Handle(events.ItemObserved{
+		Item: shop.Item{Article: "111222", SizeID: 10, Storefront: "uk"},
+		Option: shop.ItemOption{
+			Name:         "3-4 years",
+			Number:       10,
+			StockStatus:  shop.ItemStatusComingSoon,
+			StockMessage: "end of January",
+		},
+		Price: shop.Money{Amount: 1200, Currency: "GBP"},
+		Time:  now,
+	})
+
+	assert.Equal(t, []Record{{
+		Storefront:   "uk",
+		Article:      "111222",
+		SizeID:       10,
+		SizeName:     "3-4 years",
+		Status:       shop.ItemStatusComingSoon,
+		Price:        shop.Money{Amount: 1200, Currency: "GBP"},
+		StockMessage: "end of January",
+		Time:         now,
+	}}, store.records, "only observations are recorded")
+}
+
+func TestRecorder_skipsRepeatedObservations(t *testing.T) {
+	store := &fakeStore{}
+	recorder := NewRecorder(store, &Config{})
+	now := time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC)
+	item := shop.Item{Article: "111222", SizeID: 10, Storefront: "uk"}
+
+	for index, observed := range []struct {
+		status shop.StockStatus
+		price  int64
+	}{
+		{status: shop.ItemStatusInStock, price: 1200},
+		{status: shop.ItemStatusInStock, price: 1200},
+		{status: shop.ItemStatusInStock, price: 1000},
+		{status: shop.ItemStatusSoldOut, price: 1000},
+		{status: shop.ItemStatusSoldOut, price: 1000},
+	} {
+		recorder.Handle(events.ItemObserved{
+			Item:   item,
+			Option: shop.ItemOption{Number: 10, StockStatus: observed.status},
+			Price:  shop.Money{Amount: observed.price, Currency: "GBP"},
+			Time:   now.Add(time.Duration(index) * time.Hour),
+		})
+	}
+	recorder.Handle(events.ItemObserved{
+		Item:   item.WithSize(11),
+		Option: shop.ItemOption{Number: 11, StockStatus: shop.ItemStatusSoldOut},
+		Price:  shop.Money{Amount: 1000, Currency: "GBP"},
+		Time:   now,
+	})
+
+	times := make([]time.Time, 0, len(store.records))
+	for _, record := range store.records {
+		times = append(times, record.Time)
+	}
+	assert.Equal(t, []time.Time{now, now.Add(2 * time.Hour), now.Add(3 * time.Hour), now}, times)
+}
+
+func TestRecorder_keepsCurrentStateWithinRetention(t *testing.T) {
+	store := &fakeStore{}
+	recorder := NewRecorder(store, &Config{Retention: 24 * time.Hour})
+	now := time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC)
+	item := shop.Item{Article: "111222", SizeID: 10, Storefront: "uk"}
+
+	for hours := 0; hours <= 48; hours += 6 {
+		recorder.Handle(events.ItemObserved{
+			Item:   item,
+			Option: shop.ItemOption{Number: 10, StockStatus: shop.ItemStatusSoldOut},
+			Time:   now.Add(time.Duration(hours) * time.Hour),
+		})
+	}
+	recorder.cleanup(now.Add(48 * time.Hour))
+
+	times := make([]time.Time, 0, len(store.records))
+	for _, record := range store.records {
+		times = append(times, record.Time)
+	}
+	assert.Equal(t, []time.Time{now.Add(24 * time.Hour), now.Add(36 * time.Hour), now.Add(48 * time.Hour)}, times)
+}
+
+func TestRecorder_forgetsArticlesOfRemovedSubscriptions(t *testing.T) {
+	recorder := NewRecorder(&fakeStore{}, &Config{})
+	now := time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	for _, item := range []shop.Item{
+		{Article: "111222", SizeID: 10, Storefront: "uk"},
+		{Article: "111222", SizeID: 11, Storefront: "uk"},
+		{Article: "111222", SizeID: 10, Storefront: "ua"},
+		{Article: "333444", SizeID: 10, Storefront: "uk"},
+	} {
+		recorder.Handle(events.ItemObserved{Item: item, Option: shop.ItemOption{Number: item.SizeID}, Time: now})
+	}
+
+	recorder.Handle(events.SubscriptionRemoved{Subscription: subscription.Item{
+		ShopItem: shop.Item{Article: "111222", SizeID: shop.SizeAny, Storefront: "uk"},
+	}})
+	recorder.Handle(events.SubscriptionDisabled{Subscription: subscription.Item{
+		ShopItem: shop.Item{Article: "333444", SizeID: 10, Storefront: "uk"},
+	}})
+
+	assert.Equal(t, map[sizeKey]Record{
+		{Storefront: "ua", Article: "111222", SizeID: 10}: {
+			Storefront: "ua", Article: "111222", SizeID: 10, Time: now,
+		},
+	}, recorder.last)
+}
+
+func TestRecorder_removesRecordsPastRetention(t *testing.T) {
+	now := time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC)
+	store := &fakeStore{records: []Record{
+		{Article: "111222", Time: now.Add(-48 * time.Hour)},
+		{Article: "111222", Time: now.Add(-12 * time.Hour)},
+	}}
+	recorder := NewRecorder(store, &Config{Retention: 24 * time.Hour})
+
+	recorder.cleanup(now)
+
+	assert.Equal(t, []Record{{Article: "111222", Time: now.Add(-12 * time.Hour)}}, store.records)
+}
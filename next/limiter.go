@@ -0,0 +1,233 @@
+package next
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Priority defines an order in which pending requests are granted by Limiter
+type Priority int
+
+const (
+	// PriorityBackground is used for periodic checks and other non-urgent requests
+	PriorityBackground Priority = iota
+	// PriorityInteractive is used for requests a user waits for, e.g. bot lookups
+	PriorityInteractive
+)
+
+const priorityLevels = 2
+
+type priorityContextKey struct{}
+
+// WithPriority returns a context carrying the priority for requests made with it
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityContextKey{}, priority)
+}
+
+// PriorityFromContext extracts request priority from the context, PriorityBackground is a default
+func PriorityFromContext(ctx context.Context) Priority {
+	if priority, ok := ctx.Value(priorityContextKey{}).(Priority); ok {
+		return priority
+	}
+
+	return PriorityBackground
+}
+
+// RateLimitConfig holds configuration of outbound requests limiting
+type RateLimitConfig struct {
+	// RequestsPerSecond is a rate of tokens refill, zero disables rate limiting
+	RequestsPerSecond float64
+	// Burst is a maximum number of tokens available at once
+	Burst int
+	// MaxConcurrency limits number of requests in flight, zero means no limit
+	MaxConcurrency int
+}
+
+type limiterWaiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+// Limiter is a token bucket with concurrency limit and prioritized waiters queue
+type Limiter struct {
+	config   RateLimitConfig
+	tokens   float64
+	last     time.Time
+	inFlight int
+	queues   [priorityLevels][]*limiterWaiter
+	timer    *time.Timer
+	lock     sync.Mutex
+}
+
+// Acquire blocks until request may be performed or context is done.
+// Returned release function must be called once the request is completed.
+func (l *Limiter) Acquire(ctx context.Context) (func(), error) {
+	priority := PriorityFromContext(ctx)
+	if priority < 0 || priority >= priorityLevels {
+		priority = PriorityBackground
+	}
+
+	waiter := &limiterWaiter{ready: make(chan struct{})}
+
+	l.lock.Lock()
+	l.queues[priority] = append(l.queues[priority], waiter)
+	l.dispatch()
+	l.lock.Unlock()
+
+	select {
+	case <-waiter.ready:
+		return l.releaseFunc(), nil
+	case <-ctx.Done():
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		if waiter.granted {
+			// granted concurrently with cancellation, give the slot back
+			l.inFlight--
+			l.dispatch()
+		} else {
+			l.removeWaiter(priority, waiter)
+		}
+
+		return nil, ctx.Err()
+	}
+}
+
+func (l *Limiter) releaseFunc() func() {
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			l.lock.Lock()
+			defer l.lock.Unlock()
+			l.inFlight--
+			l.dispatch()
+		})
+	}
+}
+
+// dispatch grants pending waiters in priority order, must be called under lock
+func (l *Limiter) dispatch() {
+	l.refill()
+
+	for priority := priorityLevels - 1; priority >= 0; priority-- {
+		for len(l.queues[priority]) > 0 {
+			if l.config.MaxConcurrency > 0 && l.inFlight >= l.config.MaxConcurrency {
+				return
+			}
+
+			if l.config.RequestsPerSecond > 0 && l.tokens < 1 {
+				l.scheduleDispatch()
+				return
+			}
+
+			waiter := l.queues[priority][0]
+			l.queues[priority] = l.queues[priority][1:]
+			if l.config.RequestsPerSecond > 0 {
+				l.tokens--
+			}
+			l.inFlight++
+			waiter.granted = true
+			close(waiter.ready)
+		}
+	}
+}
+
+// refill adds tokens accumulated since the last refill, must be called under lock
+func (l *Limiter) refill() {
+	if l.config.RequestsPerSecond <= 0 {
+		return
+	}
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.config.RequestsPerSecond
+	if l.tokens > float64(l.config.Burst) {
+		l.tokens = float64(l.config.Burst)
+	}
+	l.last = now
+}
+
+// scheduleDispatch wakes the limiter up when the next token is available, must be called under lock
+func (l *Limiter) scheduleDispatch() {
+	if l.timer != nil {
+		return
+	}
+
+	wait := time.Duration((1 - l.tokens) / l.config.RequestsPerSecond * float64(time.Second))
+	l.timer = time.AfterFunc(wait, func() {
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		l.timer = nil
+		l.dispatch()
+	})
+}
+
+func (l *Limiter) removeWaiter(priority Priority, waiter *limiterWaiter) {
+	queue := l.queues[priority]
+	for index, w := range queue {
+		if w == waiter {
+			l.queues[priority] = append(queue[:index], queue[index+1:]...)
+			return
+		}
+	}
+}
+
+// NewLimiter constructs new Limiter, nil is returned if limiting is disabled by configuration
+func NewLimiter(config RateLimitConfig) *Limiter {
+	if config.RequestsPerSecond <= 0 && config.MaxConcurrency <= 0 {
+		return nil
+	}
+
+	if config.Burst < 1 {
+		config.Burst = 1
+	}
+
+	return &Limiter{
+		config: config,
+		tokens: float64(config.Burst),
+		last:   time.Now(),
+	}
+}
+
+// LimitedHTTPClient performs requests within the budget of shared Limiter
+type LimitedHTTPClient struct {
+	client  HTTPClient
+	limiter *Limiter
+}
+
+// Do waits for the limiter and performs the request. Concurrency slot is held until response body is closed.
+func (c *LimitedHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	release, err := c.limiter.Acquire(req.Context())
+	if err != nil {
+		return nil, &LimiterError{Err: err}
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil || resp == nil || resp.Body == nil {
+		release()
+		return resp, err
+	}
+
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+
+	return resp, nil
+}
+
+// releasingBody calls release once the body is closed, e.g. to return concurrency slot to the limiter
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	defer b.release()
+
+	return b.ReadCloser.Close()
+}
+
+// NewLimitedHTTPClient wraps client with the limiter
+func NewLimitedHTTPClient(client HTTPClient, limiter *Limiter) *LimitedHTTPClient {
+	return &LimitedHTTPClient{client: client, limiter: limiter}
+}
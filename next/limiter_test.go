@@ -0,0 +1,128 @@
+package next
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/testutils"
+)
+
+func queuedWaiters(l *Limiter, priority Priority) int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return len(l.queues[priority])
+}
+
+func waitForQueued(t *testing.T, l *Limiter, priority Priority) {
+	deadline := time.Now().Add(2 * time.Second)
+	for queuedWaiters(l, priority) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("waiter was not queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLimiter_limitsConcurrency(t *testing.T) {
+	limiter := NewLimiter(RateLimitConfig{MaxConcurrency: 1})
+	assert := assert.New(t)
+
+	release, err := limiter.Acquire(context.Background())
+	assert.NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = limiter.Acquire(ctx)
+	assert.Error(err)
+
+	release()
+	release, err = limiter.Acquire(context.Background())
+	assert.NoError(err)
+	release()
+}
+
+func TestLimiter_limitsRate(t *testing.T) {
+	limiter := NewLimiter(RateLimitConfig{RequestsPerSecond: 20, Burst: 1})
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		release, err := limiter.Acquire(context.Background())
+		assert.NoError(t, err)
+		release()
+	}
+
+	assert.True(t, time.Since(start) >= 90*time.Millisecond)
+}
+
+func TestLimiter_grantsInteractiveRequestsFirst(t *testing.T) {
+	limiter := NewLimiter(RateLimitConfig{MaxConcurrency: 1})
+	release, err := limiter.Acquire(context.Background())
+	assert.NoError(t, err)
+
+	order := make(chan Priority, 2)
+	acquire := func(priority Priority) {
+		release, err := limiter.Acquire(WithPriority(context.Background(), priority))
+		if err != nil {
+			return
+		}
+		order <- priority
+		release()
+	}
+
+	go acquire(PriorityBackground)
+	waitForQueued(t, limiter, PriorityBackground)
+	go acquire(PriorityInteractive)
+	waitForQueued(t, limiter, PriorityInteractive)
+
+	release()
+
+	assert.Equal(t, PriorityInteractive, <-order)
+	assert.Equal(t, PriorityBackground, <-order)
+}
+
+func TestPriorityFromContext_defaultsToBackground(t *testing.T) {
+	assert.Equal(t, PriorityBackground, PriorityFromContext(context.Background()))
+	assert.Equal(t, PriorityInteractive, PriorityFromContext(WithPriority(context.Background(), PriorityInteractive)))
+}
+
+func TestLimitedHTTPClient_waitingForLimiterDoesNotCountAgainstRequestTimeout(t *testing.T) {
+	limiter := NewLimiter(RateLimitConfig{MaxConcurrency: 1})
+	client := NewClientWithLimiter(
+		testutils.NewClientWithHandler(func(req *http.Request) (*http.Response, error) {
+			return testutils.NewResponse(http.StatusOK, `{"Options": [{"OptionNumber": "10"}]}`), nil
+		}),
+		Config{BaseURL: "https://www.next.ua", RequestTimeout: 10 * time.Millisecond},
+		limiter,
+	)
+	assert := assert.New(t)
+
+	release, err := limiter.Acquire(context.Background())
+	assert.NoError(err)
+	time.AfterFunc(30*time.Millisecond, release)
+
+	_, err = client.GetItemExtendedOptionContext(context.Background(), "821585")
+	assert.NoError(err)
+}
+
+func TestLimitedHTTPClient_reportsGivingUpOnLimiter(t *testing.T) {
+	limiter := NewLimiter(RateLimitConfig{MaxConcurrency: 1})
+	client := NewLimitedHTTPClient(testutils.NewClientWithPayload(""), limiter)
+
+	release, err := limiter.Acquire(context.Background())
+	assert.NoError(t, err)
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://www.next.ua/ru/search", nil)
+	_, err = client.Do(req) //nolint:bodyclose
+
+	var limiterErr *LimiterError
+	assert.True(t, errors.As(err, &limiterErr))
+}
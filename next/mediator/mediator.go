@@ -1,11 +1,20 @@
 package mediator
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next"
 
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/events"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/history"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/outbox"
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next/subscription"
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next/watch"
@@ -18,19 +27,34 @@ type SubscriptionStorage interface {
 	EnableSubscription(subscription.Item) error
 	ReadAllSubscriptions() ([]subscription.Item, error)
 	ReadSubscriptions() ([]subscription.Item, error)
+	ReadSubscriptionsByArticle(shop.Item) ([]subscription.Item, error)
 	ReadSubscriptionsByShopItem(shop.Item) ([]subscription.Item, error)
 	ReadUserAllSubscriptions(subscription.User) ([]subscription.Item, error)
 	ReadUserSubscriptions(subscription.User) ([]subscription.Item, error)
 	RemoveSubscription(subscription.Item) (bool, error)
+	UpdateSubscription(subscription.Item) error
+	UpdateSubscriptionMetadata(shop.Item, time.Time) error
 }
 
+// reconcileInterval is how often the watch list is checked against active subscriptions as a safety net
+const reconcileInterval = 10 * time.Minute
+
 // SubscriptionMediator de-couples different components of the system
 type SubscriptionMediator struct {
 	StorageBackend SubscriptionStorage
 
-	watcher       watch.Watcher
-	inStockItemCh chan subscription.Item
-	httpClient    *next.Client
+	watcher watch.Watcher
+	outbox  outbox.Store
+	history history.Store
+	events  events.Publisher
+	// subscriptionsLock serializes changes of stored subscriptions, subscriptions are read and written back
+	// under it so a stale copy does not overwrite a concurrent change. It is taken before membershipLock.
+	subscriptionsLock sync.Mutex
+	// membershipLock serializes changes of the watch list, the mediator is its only owner
+	membershipLock sync.Mutex
+	storefronts    *next.Storefronts
+	ctx            context.Context
+	cancel         context.CancelFunc
 }
 
 // ReadSubscriptions reads all subscriptions
@@ -40,26 +64,49 @@ func (m *SubscriptionMediator) ReadSubscriptions() ([]subscription.Item, error)
 
 // CreateSubscription creates new subscription in system
 func (m *SubscriptionMediator) CreateSubscription(item subscription.Item) (bool, error) {
-	extendedOptions, err := m.httpClient.GetItemExtendedOption(item.ShopItem.Article)
+	item.ShopItem.Storefront = m.storefronts.Resolve(item.ShopItem.Storefront)
+	client, err := m.storefronts.Client(item.ShopItem.Storefront)
+	if err != nil {
+		return false, err
+	}
+
+	ctx := next.WithPriority(m.ctx, next.PriorityInteractive)
+	extendedOptions, err := client.GetItemExtendedOptionContext(ctx, item.ShopItem.Article)
 
+	if errors.Is(err, next.ErrItemNotFound) {
+		return false, err
+	}
+
+	enriched := err == nil
 	if err != nil {
+		// subscription is still created, the item may be temporarily unavailable or Next may be rate limiting,
+		// missing data is fetched later by the metadata refresh
 		log.Println("[ERROR] Could not enrich subscription item with extra data: " + err.Error())
 	}
 
 	item.ShopItem.Description = extendedOptions.Description
-	option, ok := m.httpClient.FindOptionBySize(extendedOptions.Options, item.ShopItem.SizeID)
-	if ok {
+	if item.ShopItem.SizeID == shop.SizeAny {
+		item.ShopItem.SizeString = sizeString(client, extendedOptions.Options, item.ShopItem.SizeIDs)
+	} else if option, ok := client.FindOptionBySize(extendedOptions.Options, item.ShopItem.SizeID); ok {
 		item.ShopItem.SizeString = option.Name
+		item.StockMessage = option.StockMessage
 	}
 
-	url, err := m.httpClient.GetItemURLByArticle(item.ShopItem.Article)
+	url, err := client.GetItemURLByArticleContext(ctx, item.ShopItem.Article)
 	if err != nil {
+		enriched = false
 		log.Println("[ERROR] Could not fetch item URL: " + err.Error())
 	}
 
 	item.ShopItem.URL = url
+	if enriched {
+		item.MetadataRefreshedAt = time.Now()
+	}
 
-	ok, err = m.StorageBackend.CreateSubscription(item)
+	m.subscriptionsLock.Lock()
+	defer m.subscriptionsLock.Unlock()
+
+	ok, err := m.StorageBackend.CreateSubscription(item)
 	if err != nil {
 		return false, err
 	}
@@ -68,20 +115,197 @@ func (m *SubscriptionMediator) CreateSubscription(item subscription.Item) (bool,
 		return false, nil
 	}
 
-	if err = m.watcher.AddItem(&item.ShopItem); err != nil {
+	if err = m.reconcileItem(item.ShopItem); err != nil {
 		return false, err
 	}
 
+	m.events.Publish(events.SubscriptionCreated{Subscription: item, Time: time.Now()})
+
 	return true, nil
 }
 
+// sizeString describes sizes watched by the multi-size item, empty list means any size
+func sizeString(client *next.Client, options []shop.ItemOption, sizes []int) string {
+	if len(sizes) == 0 {
+		return "any size"
+	}
+
+	names := make([]string, 0, len(sizes))
+	for _, size := range sizes {
+		if option, ok := client.FindOptionBySize(options, size); ok {
+			names = append(names, option.Name)
+		} else {
+			names = append(names, strconv.Itoa(size))
+		}
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// ReadUserSubscriptions reads active subscriptions of the user
+func (m *SubscriptionMediator) ReadUserSubscriptions(user subscription.User) ([]subscription.Item, error) {
+	return m.StorageBackend.ReadUserSubscriptions(user)
+}
+
+// ReadUserAllSubscriptions reads both active and disabled subscriptions of the user
+func (m *SubscriptionMediator) ReadUserAllSubscriptions(user subscription.User) ([]subscription.Item, error) {
+	return m.StorageBackend.ReadUserAllSubscriptions(user)
+}
+
+// PollInterval returns how often the item is checked at the moment, false is returned if it is not watched
+func (m *SubscriptionMediator) PollInterval(item shop.Item) (time.Duration, bool) {
+	return m.watcher.PollInterval(item)
+}
+
+// WatchedItems lists items being watched with number of their subscribers
+func (m *SubscriptionMediator) WatchedItems() []watch.WatchedItem {
+	return m.watcher.WatchedItems()
+}
+
 // RemoveSubscription removes subscription from system
 func (m *SubscriptionMediator) RemoveSubscription(item subscription.Item) (bool, error) {
-	return m.StorageBackend.RemoveSubscription(item)
+	m.subscriptionsLock.Lock()
+	defer m.subscriptionsLock.Unlock()
+
+	removed, err := m.StorageBackend.RemoveSubscription(item)
+	if err != nil || !removed {
+		return removed, err
+	}
+
+	if err := m.reconcileItem(item.ShopItem); err != nil {
+		return true, err
+	}
+	m.events.Publish(events.SubscriptionRemoved{Subscription: item, Time: time.Now()})
+
+	return true, nil
+}
+
+// EnableSubscription makes the subscription active again, its item is watched again
+func (m *SubscriptionMediator) EnableSubscription(item subscription.Item) error {
+	m.subscriptionsLock.Lock()
+	defer m.subscriptionsLock.Unlock()
+
+	if err := m.StorageBackend.EnableSubscription(item); err != nil {
+		return err
+	}
+
+	if err := m.reconcileItem(item.ShopItem); err != nil {
+		return err
+	}
+	m.events.Publish(events.SubscriptionEnabled{Subscription: item, Time: time.Now()})
+
+	return nil
+}
+
+// DisableSubscription makes the subscription inactive, its item is not watched for the subscriber anymore
+func (m *SubscriptionMediator) DisableSubscription(item subscription.Item) error {
+	m.subscriptionsLock.Lock()
+	defer m.subscriptionsLock.Unlock()
+
+	return m.disableSubscription(item, "disabled by user")
+}
+
+// disableSubscription disables the subscription for the reason, subscriptionsLock must be held
+func (m *SubscriptionMediator) disableSubscription(item subscription.Item, reason string) error {
+	if err := m.StorageBackend.DisableSubscription(item); err != nil {
+		return err
+	}
+
+	if err := m.reconcileItem(item.ShopItem); err != nil {
+		return err
+	}
+	m.events.Publish(events.SubscriptionDisabled{Subscription: item, Reason: reason, Time: time.Now()})
+
+	return nil
+}
+
+// reconcile brings subscribers of watched items in line with active subscriptions.
+// Only items accepted by the filter are reconciled, read must return at least their active subscriptions.
+func (m *SubscriptionMediator) reconcile(
+	read func() ([]subscription.Item, error),
+	filter func(shop.Item) bool,
+) error {
+	m.membershipLock.Lock()
+	defer m.membershipLock.Unlock()
+
+	subscriptions, err := read()
+	if err != nil {
+		return err
+	}
+
+	type member struct {
+		item         string
+		subscriberID string
+	}
+
+	active := make(map[member]shop.Item, len(subscriptions))
+	for _, item := range subscriptions {
+		if filter(item.ShopItem) {
+			active[member{item: memberItemKey(item.ShopItem), subscriberID: item.User.ID}] = item.ShopItem
+		}
+	}
+
+	var removed, added int
+	for _, watched := range m.watcher.WatchedItems() {
+		if !filter(watched.Item) {
+			continue
+		}
+
+		for _, subscriberID := range watched.Subscribers {
+			key := member{item: memberItemKey(watched.Item), subscriberID: subscriberID}
+			if _, ok := active[key]; ok {
+				delete(active, key)
+				continue
+			}
+
+			m.watcher.RemoveItem(watched.Item, subscriberID)
+			removed++
+		}
+	}
+
+	var addErr error
+	for key, item := range active {
+		if err := m.watcher.AddItem(item, key.subscriberID); err != nil {
+			log.Printf("[ERROR] mediator: could not watch %v for <%s>: %s\n", item, key.subscriberID, err.Error())
+			addErr = err
+			continue
+		}
+		added++
+	}
+
+	if removed > 0 || added > 0 {
+		log.Printf("[INFO] mediator: watch list reconciled, %d subscriber(s) added, %d removed\n", added, removed)
+	}
+
+	return addErr
+}
+
+// reconcileAll reconciles the whole watch list
+func (m *SubscriptionMediator) reconcileAll() error {
+	return m.reconcile(m.StorageBackend.ReadSubscriptions, func(shop.Item) bool { return true })
+}
+
+// reconcileItem reconciles subscribers of the item only, just subscriptions of its article are read
+func (m *SubscriptionMediator) reconcileItem(item shop.Item) error {
+	return m.reconcile(
+		func() ([]subscription.Item, error) { return m.StorageBackend.ReadSubscriptionsByArticle(item) },
+		item.Equal,
+	)
 }
 
-func (m *SubscriptionMediator) FetchSizeIDs(article string) ([]shop.ItemOption, error) {
-	items, err := m.httpClient.GetOptionsByArticle(article)
+// memberItemKey identifies the item in the watch list
+func memberItemKey(item shop.Item) string {
+	return fmt.Sprintf("%s|%s|%d|%v", item.Storefront, item.Article, item.SizeID, item.SizeIDs)
+}
+
+// FetchSizeIDs fetches available options of the article at the storefront, empty storefront means default one
+func (m *SubscriptionMediator) FetchSizeIDs(storefront, article string) ([]shop.ItemOption, error) {
+	client, err := m.storefronts.Client(storefront)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := client.GetOptionsByArticleContext(next.WithPriority(m.ctx, next.PriorityInteractive), article)
 
 	if err != nil {
 		return nil, err
@@ -90,62 +314,612 @@ func (m *SubscriptionMediator) FetchSizeIDs(article string) ([]shop.ItemOption,
 	return items, nil
 }
 
-// Start begins the main loop
+// SearchProducts searches products at the storefront, empty storefront means default one
+func (m *SubscriptionMediator) SearchProducts(storefront, query string, page int) (shop.SearchResult, error) {
+	client, err := m.storefronts.Client(storefront)
+	if err != nil {
+		return shop.SearchResult{}, err
+	}
+
+	return client.SearchContext(next.WithPriority(m.ctx, next.PriorityInteractive), query, page)
+}
+
+// Start begins the main loop, the watcher is populated with active subscriptions
+// and is reconciled with them periodically, item metadata is refreshed in background
 func (m *SubscriptionMediator) Start() {
-	var item subscription.Item
-	var err error
+	if err := m.reconcileAll(); err != nil {
+		log.Println("[ERROR] could not populate watcher with subscription items: " + err.Error())
+	}
 
-	items, err := m.StorageBackend.ReadAllSubscriptions()
+	go m.refreshMetadataLoop()
+
+	ticker := time.NewTicker(reconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := m.reconcileAll(); err != nil {
+				log.Println("[ERROR] mediator: could not reconcile watch list: " + err.Error())
+			}
+		case transition, ok := <-m.watcher.TransitionsChan():
+			if !ok {
+				return
+			}
+			m.handleTransition(transition)
+		case observation, ok := <-m.watcher.ObservationsChan():
+			if !ok {
+				return
+			}
+			m.handleObservation(observation)
+		}
+	}
+}
+
+// handleTransition notifies subscribers about stock status changes they are interested in
+func (m *SubscriptionMediator) handleTransition(transition watch.Transition) {
+	m.events.Publish(events.StatusChanged{
+		Item:  transition.Item,
+		From:  transition.From,
+		To:    transition.To,
+		Price: transition.Price,
+		Time:  transition.Time,
+	})
+
+	if transition.To == shop.ItemStatusDiscontinued {
+		m.handleDiscontinuedItem(transition)
+		return
+	}
+
+	if transition.IntoPurchasable() {
+		m.handleInStockItem(transition)
+		return
+	}
+
+	if !transition.To.Purchasable() {
+		m.rearmSubscriptions(transition.Item)
+	}
+
+	// the first check only tells the current status, it is not a change yet
+	if transition.From == shop.ItemStatusUnknown {
+		return
+	}
+
+	subscriptions, err := m.StorageBackend.ReadSubscriptionsByShopItem(transition.Item)
 	if err != nil {
-		log.Println("[ERROR] could not read subscription items from storage: " + err.Error())
+		log.Printf("[ERROR] mediator: could not read subscriptions of %v: %s\n", transition.Item, err.Error())
+		return
 	}
 
-	if len(items) == 0 {
-		log.Println("[INFO] no items in persistent storage found. Skipping populating watcher.")
+	for _, item := range subscriptions {
+		if !item.Active || !item.WantsStatus(transition.To) {
+			continue
+		}
+
+		m.notify(subscription.Notification{
+			Kind:           subscription.NotificationStatusChanged,
+			Item:           item,
+			Size:           multiSizeName(item, transition.Option),
+			Price:          transition.Price,
+			StockMessage:   transition.Option.StockMessage,
+			Status:         transition.To,
+			PreviousStatus: transition.From,
+			Time:           transition.Time,
+		})
 	}
+}
 
-	for _, i := range items {
-		if err := m.watcher.AddItem(&i.ShopItem); err != nil {
-			log.Printf("[ERROR] initial item addition to watcher failed: article=%s, sizeID=%d\n",
-				i.ShopItem.Article,
-				i.ShopItem.SizeID,
-			)
+// handleDiscontinuedItem disables subscriptions of the item which disappeared from Next,
+// unless subscribers asked to keep watching it anyway
+func (m *SubscriptionMediator) handleDiscontinuedItem(transition watch.Transition) {
+	m.subscriptionsLock.Lock()
+	defer m.subscriptionsLock.Unlock()
+
+	shopItem := transition.Item
+	var subscriptions []subscription.Item
+	var err error
+	if shopItem.SizeID == shop.SizeAny {
+		// the watched item itself is reported, not one of its sizes
+		subscriptions, err = m.StorageBackend.ReadSubscriptionsByArticle(shopItem)
+	} else {
+		subscriptions, err = m.StorageBackend.ReadSubscriptionsByShopItem(shopItem)
+	}
+	if err != nil {
+		log.Printf("[ERROR] mediator: could not read subscriptions of %v: %s\n", shopItem, err.Error())
+		return
+	}
+
+	for _, item := range subscriptions {
+		if shopItem.SizeID == shop.SizeAny && !item.ShopItem.Equal(shopItem) {
+			continue
+		}
+		if !item.Active || item.WatchDiscontinued {
+			continue
+		}
+
+		log.Printf("[INFO] mediator: disabling subscription of discontinued item %v\n", item)
+		if err := m.disableSubscription(item, "discontinued"); err != nil {
+			log.Printf("[ERROR] mediator: could not disable subscription: %s\n", err.Error())
+			continue
 		}
+
+		m.notify(subscription.Notification{
+			Kind: subscription.NotificationDiscontinued,
+			Item: item,
+			Time: transition.Time,
+		})
 	}
+}
+
+// handleInStockItem notifies every active subscriber of the item which appeared in stock.
+// Each user is notified once even if several of their subscriptions cover the item.
+func (m *SubscriptionMediator) handleInStockItem(transition watch.Transition) {
+	inStockItem := transition.Item
+	log.Printf("[DEBUG] item appeared in stock: %v", inStockItem)
+	m.subscriptionsLock.Lock()
+	defer m.subscriptionsLock.Unlock()
 
-	for inStockItem := range m.watcher.InStockChan() {
-		log.Printf("[DEBUG] item appeared in stock: %v", inStockItem)
-		if item, err = m.findItemByShopItem(inStockItem); err != nil {
-			log.Printf("[ERROR] %s\n", err.Error())
+	subscriptions, err := m.StorageBackend.ReadSubscriptionsByShopItem(inStockItem)
+	if err != nil {
+		log.Printf("[ERROR] mediator: could not read subscriptions of %v: %s\n", inStockItem, err.Error())
+		return
+	}
+
+	notified := make(map[string]bool, len(subscriptions))
+	for _, item := range subscriptions {
+		if !item.Active {
 			continue
 		}
 
-		m.inStockItemCh <- item
+		if item.KeepWatching {
+			m.handleInStockKeptItem(item, transition, notified)
+			continue
+		}
+
+		m.notifyInStock(item, transition, notified)
+
+		// remembered for the case the subscriber decides to keep watching it
+		item = markInStockNotified(item, transition.Item.SizeID, true)
+		if err := m.StorageBackend.UpdateSubscription(item); err != nil {
+			log.Printf("[ERROR] mediator: could not update subscription %v: %s\n", item, err.Error())
+		}
 
-		m.watcher.RemoveItem(shop.Item{Article: item.ShopItem.Article, SizeID: item.ShopItem.SizeID})
 		log.Printf("[DEBUG] mediator: disabling subscription %v\n", item)
-		if err := m.StorageBackend.DisableSubscription(item); err != nil {
+		if err := m.disableSubscription(item, "in stock"); err != nil {
 			log.Printf("[ERROR] mediator: could not disable subscription: %s\n", err.Error())
 		}
 	}
+
+	if len(notified) > 0 {
+		log.Printf("[INFO] mediator: %d subscriber(s) notified about in-stock item %v\n", len(notified), inStockItem)
+	}
+}
+
+// handleInStockKeptItem notifies about in-stock item once per restock of every size, the item stays watched
+func (m *SubscriptionMediator) handleInStockKeptItem(
+	item subscription.Item,
+	transition watch.Transition,
+	notified map[string]bool,
+) {
+	if item.Observed(transition.Item.SizeID).InStockNotified {
+		return
+	}
+
+	item = markInStockNotified(item, transition.Item.SizeID, true)
+	if err := m.StorageBackend.UpdateSubscription(item); err != nil {
+		log.Printf("[ERROR] mediator: could not update subscription %v: %s\n", item, err.Error())
+	}
+
+	m.notifyInStock(item, transition, notified)
+}
+
+// notifyInStock sends in-stock notification unless the user has already got one about the item
+func (m *SubscriptionMediator) notifyInStock(
+	item subscription.Item,
+	transition watch.Transition,
+	notified map[string]bool,
+) {
+	if notified[item.User.ID] {
+		return
+	}
+	notified[item.User.ID] = true
+
+	m.notify(subscription.Notification{
+		Kind:  subscription.NotificationInStock,
+		Item:  item,
+		Size:  multiSizeName(item, transition.Option),
+		Price: transition.Price,
+		Time:  transition.Time,
+	})
+}
+
+// notify puts the notification into the outbox, it is delivered from there until acknowledged
+func (m *SubscriptionMediator) notify(notification subscription.Notification) {
+	now := time.Now()
+	if notification.Time.IsZero() {
+		notification.Time = now
+	}
+
+	entry := outbox.NewEntry(notification, now)
+	enqueued, err := m.outbox.EnqueueNotification(entry)
+	if err != nil {
+		log.Printf("[ERROR] mediator: could not enqueue %s notification for user <%s>: %s\n",
+			notification.Kind,
+			notification.Item.User.ID,
+			err.Error(),
+		)
+		return
+	}
+
+	if !enqueued {
+		log.Printf("[DEBUG] mediator: %s notification for user <%s> is already enqueued\n",
+			notification.Kind,
+			notification.Item.User.ID,
+		)
+	}
+}
+
+// multiSizeName tells which of the subscription's sizes the option is, it is empty for single size subscriptions
+func multiSizeName(item subscription.Item, option shop.ItemOption) string {
+	if item.ShopItem.SizeID != shop.SizeAny {
+		return ""
+	}
+
+	return option.Name
+}
+
+// markInStockNotified returns copy of the item remembering whether the subscriber knows about the size in stock
+func markInStockNotified(item subscription.Item, size int, notified bool) subscription.Item {
+	state := item.Observed(size)
+	state.InStockNotified = notified
+
+	return item.WithObserved(size, state)
+}
+
+// rearmSubscriptions lets kept subscriptions be notified again once the item is back in stock
+func (m *SubscriptionMediator) rearmSubscriptions(shopItem shop.Item) {
+	m.subscriptionsLock.Lock()
+	defer m.subscriptionsLock.Unlock()
+
+	subscriptions, err := m.StorageBackend.ReadSubscriptionsByShopItem(shopItem)
+	if err != nil {
+		log.Printf("[ERROR] mediator: could not read subscriptions of %v: %s\n", shopItem, err.Error())
+		return
+	}
+
+	for _, item := range subscriptions {
+		if !item.Observed(shopItem.SizeID).InStockNotified {
+			continue
+		}
+
+		item = markInStockNotified(item, shopItem.SizeID, false)
+		if err := m.StorageBackend.UpdateSubscription(item); err != nil {
+			log.Printf("[ERROR] mediator: could not update subscription %v: %s\n", item, err.Error())
+		}
+	}
+}
+
+// handleObservation remembers the price and restock estimate seen by the watcher
+// and notifies subscribers who asked for it, every observation is published for stock history
+func (m *SubscriptionMediator) handleObservation(observation watch.Observation) {
+	price, priceErr := observation.Option.ParsedPrice()
+	if priceErr != nil {
+		log.Printf("[DEBUG] mediator: could not parse price of %v: %s\n", observation.Item, priceErr.Error())
+	}
+
+	m.events.Publish(events.ItemObserved{
+		Item:   observation.Item,
+		Option: observation.Option,
+		Price:  price,
+		Time:   observation.Time,
+	})
+
+	m.subscriptionsLock.Lock()
+	defer m.subscriptionsLock.Unlock()
+
+	subscriptions, err := m.StorageBackend.ReadSubscriptionsByShopItem(observation.Item)
+	if err != nil {
+		log.Printf("[ERROR] mediator: could not read subscriptions of %v: %s\n", observation.Item, err.Error())
+		return
+	}
+
+	size := observation.Item.SizeID
+	for _, item := range subscriptions {
+		if !item.ShopItem.Covers(observation.Item) {
+			continue
+		}
+
+		previous := item.Observed(size)
+		current := previous
+		current.StockMessage = observation.Option.StockMessage
+		if priceErr == nil {
+			current.LastPrice = price
+		}
+
+		if current == previous {
+			continue
+		}
+
+		item = item.WithObserved(size, current)
+		if err := m.StorageBackend.UpdateSubscription(item); err != nil {
+			log.Printf("[ERROR] mediator: could not update subscription %v: %s\n", item, err.Error())
+			continue
+		}
+
+		if current.LastPrice != previous.LastPrice {
+			if kind, ok := priceNotificationKind(item, previous.LastPrice, current.LastPrice); ok {
+				m.notify(subscription.Notification{
+					Kind:          kind,
+					Item:          item,
+					Size:          multiSizeName(item, observation.Option),
+					Price:         current.LastPrice,
+					PreviousPrice: previous.LastPrice,
+					Time:          observation.Time,
+				})
+			}
+		}
+
+		if restockDateChanged(item, previous.StockMessage, observation.Option) {
+			m.notify(subscription.Notification{
+				Kind:                 subscription.NotificationRestockDateChanged,
+				Item:                 item,
+				Size:                 multiSizeName(item, observation.Option),
+				StockMessage:         current.StockMessage,
+				PreviousStockMessage: previous.StockMessage,
+				Time:                 observation.Time,
+			})
+		}
+	}
+}
+
+// restockDateChanged checks whether the restock estimate of coming soon item has moved.
+// The first seen estimate is only remembered, there is nothing to compare it with.
+func restockDateChanged(item subscription.Item, previous string, option shop.ItemOption) bool {
+	return item.Active &&
+		option.StockStatus == shop.ItemStatusComingSoon &&
+		previous != "" &&
+		option.StockMessage != "" &&
+		option.StockMessage != previous
+}
+
+// priceNotificationKind decides whether the subscriber wants to know about the price change
+func priceNotificationKind(
+	item subscription.Item,
+	previous, current shop.Money,
+) (subscription.NotificationKind, bool) {
+	if !item.Active {
+		return "", false
+	}
+
+	target := item.TargetPrice
+	if !target.IsZero() && target.Comparable(current) && !target.Less(current) &&
+		(previous.IsZero() || target.Less(previous)) {
+		return subscription.NotificationPriceBelowTarget, true
+	}
+
+	if item.NotifyOnPriceDrop && !previous.IsZero() && current.Less(previous) {
+		return subscription.NotificationPriceDrop, true
+	}
+
+	return "", false
+}
+
+// SetPriceDropAlert enables or disables price drop notifications for the subscription
+func (m *SubscriptionMediator) SetPriceDropAlert(item subscription.Item, enabled bool) error {
+	m.subscriptionsLock.Lock()
+	defer m.subscriptionsLock.Unlock()
+
+	stored, err := m.findUserItem(item)
+	if err != nil {
+		return err
+	}
+
+	stored.NotifyOnPriceDrop = enabled
+
+	return m.StorageBackend.UpdateSubscription(stored)
+}
+
+// SetStatusAlert enables or disables notifications when the subscription item moves into the stock status
+func (m *SubscriptionMediator) SetStatusAlert(item subscription.Item, status shop.StockStatus, enabled bool) error {
+	m.subscriptionsLock.Lock()
+	defer m.subscriptionsLock.Unlock()
+
+	stored, err := m.findUserItem(item)
+	if err != nil {
+		return err
+	}
+
+	statuses := make([]shop.StockStatus, 0, len(stored.NotifyOnStatuses)+1)
+	for _, s := range stored.NotifyOnStatuses {
+		if s != status {
+			statuses = append(statuses, s)
+		}
+	}
+	if enabled {
+		statuses = append(statuses, status)
+	}
+	stored.NotifyOnStatuses = statuses
+
+	return m.StorageBackend.UpdateSubscription(stored)
+}
+
+// SetKeepWatching toggles "keep watching" mode of the subscription and returns the updated subscription.
+// Subscription disabled after in-stock notification is re-armed: it is watched again,
+// but the next notification is sent only after the item leaves stock and comes back.
+// Turning the mode off while the item is in stock disables the subscription.
+func (m *SubscriptionMediator) SetKeepWatching(item subscription.Item, enabled bool) (subscription.Item, error) {
+	m.subscriptionsLock.Lock()
+	defer m.subscriptionsLock.Unlock()
+
+	stored, err := m.findUserItem(item)
+	if err != nil {
+		return subscription.Item{}, err
+	}
+
+	stored.KeepWatching = enabled
+	rearm := enabled && !stored.Active
+	if rearm {
+		stored.Active = true
+		stored.InStockNotified = true
+	}
+	// the subscriber has been notified about the item in stock, so it is disabled as a regular subscription would be
+	stop := !enabled && stored.Active && stored.InStockNotified
+	if stop {
+		stored = stored.WithoutInStockNotified()
+	}
+
+	if err := m.StorageBackend.UpdateSubscription(stored); err != nil {
+		return subscription.Item{}, err
+	}
+
+	if stop {
+		if err := m.disableSubscription(stored, "stopped watching by user"); err != nil {
+			return subscription.Item{}, err
+		}
+		stored.Active = false
+
+		return stored, nil
+	}
+
+	if rearm {
+		m.events.Publish(events.SubscriptionEnabled{Subscription: stored, Time: time.Now()})
+		return stored, m.reconcileItem(stored.ShopItem)
+	}
+
+	return stored, nil
+}
+
+// WatchDiscontinued re-enables subscription disabled because its article appeared discontinued,
+// the subscription is not disabled for that reason anymore
+func (m *SubscriptionMediator) WatchDiscontinued(item subscription.Item) error {
+	m.subscriptionsLock.Lock()
+	defer m.subscriptionsLock.Unlock()
+
+	stored, err := m.findUserItem(item)
+	if err != nil {
+		return err
+	}
+
+	stored.WatchDiscontinued = true
+	stored.Active = true
+	if err := m.StorageBackend.UpdateSubscription(stored); err != nil {
+		return err
+	}
+	m.events.Publish(events.SubscriptionEnabled{Subscription: stored, Time: time.Now()})
+
+	return m.reconcileItem(stored.ShopItem)
+}
+
+// SetTargetPrice sets the target price for all user's active subscriptions of the article.
+// If target has no currency, the currency of the last seen price is used.
+// The user is notified at once if the last seen price already meets the target.
+// Number of updated subscriptions is returned.
+func (m *SubscriptionMediator) SetTargetPrice(user subscription.User, article string, target shop.Money) (int, error) {
+	m.subscriptionsLock.Lock()
+	defer m.subscriptionsLock.Unlock()
+
+	subscriptions, err := m.StorageBackend.ReadUserSubscriptions(user)
+	if err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, item := range subscriptions {
+		if item.ShopItem.Article != shop.NormalizeArticle(article) {
+			continue
+		}
+
+		item.TargetPrice = target
+		if item.TargetPrice.Currency == "" {
+			item.TargetPrice.Currency = item.Currency()
+		}
+
+		if err := m.StorageBackend.UpdateSubscription(item); err != nil {
+			return updated, err
+		}
+		updated++
+
+		// price crossing the target is notified by observations, the price which is already there is not a change
+		if price, ok := targetPriceMet(item); ok {
+			m.notify(subscription.Notification{
+				Kind:  subscription.NotificationPriceBelowTarget,
+				Item:  item,
+				Price: price,
+			})
+		}
+	}
+
+	return updated, nil
+}
+
+// targetPriceMet returns the lowest price seen during the last check if it is at or below the target price
+func targetPriceMet(item subscription.Item) (shop.Money, bool) {
+	target := item.TargetPrice
+	if target.IsZero() {
+		return shop.Money{}, false
+	}
+
+	prices := []shop.Money{item.LastPrice}
+	for _, state := range item.Sizes {
+		prices = append(prices, state.LastPrice)
+	}
+
+	var lowest shop.Money
+	met := false
+	for _, price := range prices {
+		if price.IsZero() || !target.Comparable(price) || target.Less(price) {
+			continue
+		}
+		if !met || price.Less(lowest) {
+			lowest = price
+			met = true
+		}
+	}
+
+	return lowest, met
 }
 
 func (m *SubscriptionMediator) Stop() {
 	log.Println("[INFO] Stopping mediator")
+	m.cancel()
 }
 
-func (m *SubscriptionMediator) InStockItemCh() <-chan subscription.Item {
-	return m.inStockItemCh
+// UndeliveredNotifications lists user's notifications which could not be delivered
+func (m *SubscriptionMediator) UndeliveredNotifications(user subscription.User) ([]outbox.Entry, error) {
+	entries, err := m.outbox.ReadDeadNotifications()
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]outbox.Entry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Notification.Item.User.ID == user.ID {
+			ret = append(ret, entry)
+		}
+	}
+
+	return ret, nil
+}
+
+// StockHistory lists stock status changes of the article's sizes seen by the watcher, the oldest go first
+func (m *SubscriptionMediator) StockHistory(article string) ([]history.Record, error) {
+	records, err := m.history.ReadHistory(article)
+	if err != nil {
+		return nil, err
+	}
+
+	return history.Changes(records), nil
 }
 
-func (m *SubscriptionMediator) findItemByShopItem(item shop.Item) (subscription.Item, error) {
-	subscriptions, err := m.ReadSubscriptions()
+// findUserItem reads stored version of the user's subscription
+func (m *SubscriptionMediator) findUserItem(item subscription.Item) (subscription.Item, error) {
+	subscriptions, err := m.StorageBackend.ReadUserAllSubscriptions(item.User)
 	if err != nil {
 		return subscription.Item{}, err
 	}
 
 	for _, it := range subscriptions {
-		if it.ShopItem.Article == item.Article && it.ShopItem.SizeID == item.SizeID {
+		if it.ShopItem.Equal(item.ShopItem) {
 			return it, nil
 		}
 	}
@@ -153,12 +927,26 @@ func (m *SubscriptionMediator) findItemByShopItem(item shop.Item) (subscription.
 	return subscription.Item{}, fmt.Errorf("no such subscription item found: %v", item)
 }
 
-// New instantiates SubscriptionMediator object
-func New(storageBackend SubscriptionStorage, watcher watch.Watcher, httpClient *next.Client) *SubscriptionMediator {
+// New instantiates SubscriptionMediator object, notifications are delivered from the outbox,
+// changes of subscriptions and observations are published as events
+func New(
+	storageBackend SubscriptionStorage,
+	notifications outbox.Store,
+	stockHistory history.Store,
+	publisher events.Publisher,
+	watcher watch.Watcher,
+	storefronts *next.Storefronts,
+) *SubscriptionMediator {
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return &SubscriptionMediator{
 		StorageBackend: storageBackend,
-		inStockItemCh:  make(chan subscription.Item, 10),
+		outbox:         notifications,
+		history:        stockHistory,
+		events:         publisher,
 		watcher:        watcher,
-		httpClient:     httpClient,
+		storefronts:    storefronts,
+		ctx:            ctx,
+		cancel:         cancel,
 	}
 }
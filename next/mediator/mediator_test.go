@@ -1,6 +1,8 @@
 package mediator
 
 import (
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -10,6 +12,9 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/events"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/history"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/outbox"
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next/subscription"
 
@@ -22,14 +27,19 @@ func TestCreateSubscription(t *testing.T) {
 	watcher, _ := watch.New(nil, &watch.Config{UpdateInterval: 2 * time.Second})
 	mediator := New(
 		storage,
+		storage,
+		storage,
+		events.NewBus(),
 		watcher,
-		next.NewClient(
-			testutils.NewClientWithPayload(""),
-			next.Config{
-				BaseURL: "",
-				Lang:    "uk",
-			},
-		),
+		next.NewStorefronts("uk", map[string]*next.Client{
+			"uk": next.NewClient(
+				testutils.NewClientWithPayload(""),
+				next.Config{
+					BaseURL: "",
+					Lang:    "uk",
+				},
+			),
+		}),
 	)
 
 	assert := assert.New(t)
@@ -55,3 +65,786 @@ func TestCreateSubscription(t *testing.T) {
 	assert.NoError(err)
 	assert.Equal(1, len(storageSubscriptions))
 }
+
+func newTestMediator(storage *storage.MemoryStorage) *SubscriptionMediator {
+	watcher, _ := watch.New(nil, &watch.Config{UpdateInterval: 2 * time.Second})
+
+	return New(
+		storage,
+		storage,
+		storage,
+		events.NewBus(),
+		watcher,
+		next.NewStorefronts("uk", map[string]*next.Client{
+			"uk": next.NewClient(testutils.NewClientWithPayload(""), next.Config{}),
+		}),
+	)
+}
+
+// takeNotifications reads notifications enqueued by the mediator and marks them delivered
+func takeNotifications(t *testing.T, storage *storage.MemoryStorage) []subscription.Notification {
+	entries, err := storage.ReadDueNotifications(time.Now(), 100)
+	assert.NoError(t, err)
+
+	notifications := make([]subscription.Notification, 0, len(entries))
+	for _, entry := range entries {
+		entry.Status = outbox.StatusDelivered
+		assert.NoError(t, storage.UpdateNotification(entry))
+		notifications = append(notifications, entry.Notification)
+	}
+
+	return notifications
+}
+
+func TestHandleObservation_notifiesOnPriceDrop(t *testing.T) {
+	storage := storage.NewMemoryStorage()
+	mediator := newTestMediator(storage)
+	assert := assert.New(t)
+
+	item := subscription.Item{
+		Active:            true,
+		User:              subscription.User{ID: "user-1"},
+		ShopItem:          shop.Item{Article: "111222", SizeID: 10, Storefront: "uk"},
+		LastPrice:         shop.Money{Amount: 70000, Currency: "UAH"},
+		NotifyOnPriceDrop: true,
+	}
+	_, err := storage.CreateSubscription(item)
+	assert.NoError(err)
+
+	mediator.handleObservation(watch.Observation{
+		Item:   item.ShopItem,
+		Option: shop.ItemOption{Article: "111222", Number: 10, Price: "635 грн"},
+	})
+
+	notifications := takeNotifications(t, storage)
+	assert.Len(notifications, 1)
+	assert.Equal(subscription.NotificationPriceDrop, notifications[0].Kind)
+	assert.Equal(shop.Money{Amount: 70000, Currency: "UAH"}, notifications[0].PreviousPrice)
+	assert.Equal(shop.Money{Amount: 63500, Currency: "UAH"}, notifications[0].Price)
+
+	subscriptions, err := storage.ReadSubscriptions()
+	assert.NoError(err)
+	assert.Equal(shop.Money{Amount: 63500, Currency: "UAH"}, subscriptions[0].LastPrice)
+}
+
+func TestHandleObservation_notifiesOnceWhenPriceFallsBelowTarget(t *testing.T) {
+	storage := storage.NewMemoryStorage()
+	mediator := newTestMediator(storage)
+	assert := assert.New(t)
+
+	item := subscription.Item{
+		Active:      true,
+		User:        subscription.User{ID: "user-1"},
+		ShopItem:    shop.Item{Article: "111222", SizeID: 10, Storefront: "uk"},
+		LastPrice:   shop.Money{Amount: 2500, Currency: "GBP"},
+		TargetPrice: shop.Money{Amount: 2000, Currency: "GBP"},
+	}
+	_, err := storage.CreateSubscription(item)
+	assert.NoError(err)
+
+	for _, price := range []string{"£22", "£19.50", "£18"} {
+		mediator.handleObservation(watch.Observation{
+			Item:   item.ShopItem,
+			Option: shop.ItemOption{Article: "111222", Number: 10, Price: price},
+		})
+	}
+
+	notifications := takeNotifications(t, storage)
+	assert.Len(notifications, 1)
+	notification := notifications[0]
+	assert.Equal(subscription.NotificationPriceBelowTarget, notification.Kind)
+	assert.Equal(shop.Money{Amount: 1950, Currency: "GBP"}, notification.Price)
+}
+
+func TestSetTargetPrice_usesCurrencyOfLastPrice(t *testing.T) {
+	storage := storage.NewMemoryStorage()
+	mediator := newTestMediator(storage)
+	assert := assert.New(t)
+
+	user := subscription.User{ID: "user-1"}
+	_, err := storage.CreateSubscription(subscription.Item{
+		Active:    true,
+		User:      user,
+		ShopItem:  shop.Item{Article: "111222", SizeID: 10, Storefront: "uk"},
+		LastPrice: shop.Money{Amount: 2500, Currency: "GBP"},
+	})
+	assert.NoError(err)
+
+	updated, err := mediator.SetTargetPrice(user, "111-222", shop.Money{Amount: 2000})
+	assert.NoError(err)
+	assert.Equal(1, updated)
+
+	subscriptions, err := storage.ReadUserSubscriptions(user)
+	assert.NoError(err)
+	assert.Equal(shop.Money{Amount: 2000, Currency: "GBP"}, subscriptions[0].TargetPrice)
+}
+
+func TestSetTargetPrice_notifiesWhenTargetIsAlreadyMet(t *testing.T) {
+	storage := storage.NewMemoryStorage()
+	mediator := newTestMediator(storage)
+	assert := assert.New(t)
+
+	user := subscription.User{ID: "user-1"}
+	multiSize := shop.NewMultiSizeItem("111222", 10, 11)
+	multiSize.Storefront = "uk"
+	for _, item := range []subscription.Item{
+		{
+			Active:    true,
+			User:      user,
+			ShopItem:  shop.Item{Article: "111222", SizeID: 12, Storefront: "uk"},
+			LastPrice: shop.Money{Amount: 2500, Currency: "GBP"},
+		},
+		{
+			Active:   true,
+			User:     user,
+			ShopItem: multiSize,
+			Sizes: map[int]subscription.SizeState{
+				10: {LastPrice: shop.Money{Amount: 1900, Currency: "GBP"}},
+				11: {LastPrice: shop.Money{Amount: 1800, Currency: "GBP"}},
+			},
+		},
+	} {
+		_, err := storage.CreateSubscription(item)
+		assert.NoError(err)
+	}
+
+	updated, err := mediator.SetTargetPrice(user, "111-222", shop.Money{Amount: 2000})
+	assert.NoError(err)
+	assert.Equal(2, updated)
+
+	notifications := takeNotifications(t, storage)
+	assert.Len(notifications, 1)
+	assert.Equal(subscription.NotificationPriceBelowTarget, notifications[0].Kind)
+	assert.Equal(multiSize, notifications[0].Item.ShopItem)
+	assert.Equal(shop.Money{Amount: 1800, Currency: "GBP"}, notifications[0].Price)
+}
+
+func TestHandleObservation_notifiesWhenRestockDateChanges(t *testing.T) {
+	storage := storage.NewMemoryStorage()
+	mediator := newTestMediator(storage)
+	assert := assert.New(t)
+
+	item := subscription.Item{
+		Active:   true,
+		User:     subscription.User{ID: "user-1"},
+		ShopItem: shop.Item{Article: "111222", SizeID: 10, Storefront: "ua"},
+	}
+	_, err := storage.CreateSubscription(item)
+	assert.NoError(err)
+
+	for _, message := range []string{"середина января", "середина января", "конец января"} {
+		mediator.handleObservation(watch.Observation{
+			Item: item.ShopItem,
+			Option: shop.ItemOption{
+				Article:      "111222",
+				Number:       10,
+				Price:        "635 грн",
+				StockStatus:  shop.ItemStatusComingSoon,
+				StockMessage: message,
+			},
+		})
+	}
+
+	notifications := takeNotifications(t, storage)
+	assert.Len(notifications, 1)
+	notification := notifications[0]
+	assert.Equal(subscription.NotificationRestockDateChanged, notification.Kind)
+	assert.Equal("середина января", notification.PreviousStockMessage)
+	assert.Equal("конец января", notification.StockMessage)
+
+	subscriptions, err := storage.ReadSubscriptions()
+	assert.NoError(err)
+	assert.Equal("конец января", subscriptions[0].StockMessage)
+}
+
+func TestHandleTransition_notifiesSubscribersOfChosenStatuses(t *testing.T) {
+	storage := storage.NewMemoryStorage()
+	mediator := newTestMediator(storage)
+	assert := assert.New(t)
+
+	shopItem := shop.Item{Article: "111222", SizeID: 10, Storefront: "uk"}
+	interested := subscription.Item{
+		Active:   true,
+		User:     subscription.User{ID: "user-1"},
+		ShopItem: shopItem,
+	}
+	_, err := storage.CreateSubscription(interested)
+	assert.NoError(err)
+	_, err = storage.CreateSubscription(subscription.Item{
+		Active:   true,
+		User:     subscription.User{ID: "user-2"},
+		ShopItem: shopItem,
+	})
+	assert.NoError(err)
+	assert.NoError(mediator.SetStatusAlert(interested, shop.ItemStatusComingSoon, true))
+
+	mediator.handleTransition(watch.Transition{
+		Item: shopItem,
+		From: shop.ItemStatusUnknown,
+		To:   shop.ItemStatusComingSoon,
+	})
+	assert.Len(takeNotifications(t, storage), 0, "the first check is not a change")
+
+	mediator.handleTransition(watch.Transition{
+		Item:   shopItem,
+		Option: shop.ItemOption{StockMessage: "середина января"},
+		From:   shop.ItemStatusSoldOut,
+		To:     shop.ItemStatusComingSoon,
+	})
+
+	notifications := takeNotifications(t, storage)
+	assert.Len(notifications, 1)
+	notification := notifications[0]
+	assert.Equal(subscription.NotificationStatusChanged, notification.Kind)
+	assert.Equal("user-1", notification.Item.User.ID)
+	assert.Equal(shop.ItemStatusSoldOut, notification.PreviousStatus)
+	assert.Equal(shop.ItemStatusComingSoon, notification.Status)
+	assert.Equal("середина января", notification.StockMessage)
+}
+
+func TestHandleTransition_keepWatchingNotifiesOnEveryRestock(t *testing.T) {
+	storage := storage.NewMemoryStorage()
+	mediator := newTestMediator(storage)
+	assert := assert.New(t)
+
+	shopItem := shop.Item{Article: "111222", SizeID: 10, Storefront: "uk"}
+	_, err := storage.CreateSubscription(subscription.Item{
+		Active:       true,
+		User:         subscription.User{ID: "user-1"},
+		ShopItem:     shopItem,
+		KeepWatching: true,
+	})
+	assert.NoError(err)
+
+	for _, statuses := range [][2]shop.StockStatus{
+		{shop.ItemStatusUnknown, shop.ItemStatusInStock},
+		{shop.ItemStatusInStock, shop.ItemStatusLowStock},
+		{shop.ItemStatusLowStock, shop.ItemStatusSoldOut},
+		{shop.ItemStatusSoldOut, shop.ItemStatusInStock},
+	} {
+		mediator.handleTransition(watch.Transition{Item: shopItem, From: statuses[0], To: statuses[1]})
+	}
+
+	assert.Len(takeNotifications(t, storage), 2)
+	subscriptions, err := storage.ReadSubscriptions()
+	assert.NoError(err)
+	assert.Len(subscriptions, 1)
+	assert.True(subscriptions[0].InStockNotified)
+}
+
+func TestSetKeepWatching_rearmsDisabledSubscription(t *testing.T) {
+	storage := storage.NewMemoryStorage()
+	mediator := newTestMediator(storage)
+	assert := assert.New(t)
+
+	item := subscription.Item{
+		Active:   true,
+		User:     subscription.User{ID: "user-1"},
+		ShopItem: shop.Item{Article: "111222", SizeID: 10, Storefront: "uk"},
+	}
+	_, err := storage.CreateSubscription(item)
+	assert.NoError(err)
+
+	mediator.handleInStockItem(watch.Transition{Item: item.ShopItem})
+	assert.Len(takeNotifications(t, storage), 1)
+	subscriptions, err := storage.ReadSubscriptions()
+	assert.NoError(err)
+	assert.Len(subscriptions, 0)
+
+	updated, err := mediator.SetKeepWatching(item, true)
+	assert.NoError(err)
+	assert.True(updated.Active)
+	assert.True(updated.KeepWatching)
+
+	// the item is still in stock, the subscriber already knows about it
+	mediator.handleInStockItem(watch.Transition{Item: item.ShopItem})
+	assert.Len(takeNotifications(t, storage), 0)
+}
+
+func TestSetKeepWatching_stopsWatchingInStockItem(t *testing.T) {
+	storage := storage.NewMemoryStorage()
+	mediator := newTestMediator(storage)
+	assert := assert.New(t)
+
+	item := subscription.Item{
+		Active:       true,
+		User:         subscription.User{ID: "user-1"},
+		ShopItem:     shop.Item{Article: "111222", SizeID: 10, Storefront: "uk"},
+		KeepWatching: true,
+	}
+	_, err := storage.CreateSubscription(item)
+	assert.NoError(err)
+	assert.NoError(mediator.reconcileAll())
+
+	mediator.handleInStockItem(watch.Transition{Item: item.ShopItem})
+	assert.Len(takeNotifications(t, storage), 1)
+
+	updated, err := mediator.SetKeepWatching(item, false)
+	assert.NoError(err)
+	assert.False(updated.Active)
+	assert.False(updated.KeepWatching)
+
+	subscriptions, err := storage.ReadSubscriptions()
+	assert.NoError(err)
+	assert.Len(subscriptions, 0)
+	assert.Empty(mediator.WatchedItems())
+}
+
+func TestCreateSubscription_watchesSharedItemOnce(t *testing.T) {
+	mediator := newTestMediator(storage.NewMemoryStorage())
+	assert := assert.New(t)
+
+	for _, user := range []string{"user-1", "user-2"} {
+		ok, err := mediator.CreateSubscription(subscription.Item{
+			Active:   true,
+			User:     subscription.User{ID: user},
+			ShopItem: shop.NewItem("111-222", 10),
+		})
+		assert.NoError(err)
+		assert.True(ok)
+	}
+
+	watched := mediator.WatchedItems()
+	assert.Len(watched, 1)
+	assert.Equal([]string{"user-1", "user-2"}, watched[0].Subscribers)
+	assert.Equal("uk", watched[0].Item.Storefront)
+}
+
+func TestHandleTransition_disablesSubscriptionsOfDiscontinuedItem(t *testing.T) {
+	storage := storage.NewMemoryStorage()
+	mediator := newTestMediator(storage)
+	assert := assert.New(t)
+
+	shopItem := shop.Item{Article: "111222", SizeID: 10, Storefront: "uk"}
+	stubborn := subscription.Item{
+		Active:            true,
+		User:              subscription.User{ID: "user-1"},
+		ShopItem:          shopItem,
+		WatchDiscontinued: true,
+	}
+	regular := subscription.Item{
+		Active:   true,
+		User:     subscription.User{ID: "user-2"},
+		ShopItem: shopItem,
+	}
+	for _, item := range []subscription.Item{stubborn, regular} {
+		_, err := storage.CreateSubscription(item)
+		assert.NoError(err)
+		assert.NoError(mediator.watcher.AddItem(item.ShopItem, item.User.ID))
+	}
+
+	mediator.handleTransition(watch.Transition{
+		Item: shopItem,
+		From: shop.ItemStatusSoldOut,
+		To:   shop.ItemStatusDiscontinued,
+	})
+
+	notifications := takeNotifications(t, storage)
+	assert.Len(notifications, 1)
+	notification := notifications[0]
+	assert.Equal(subscription.NotificationDiscontinued, notification.Kind)
+	assert.Equal("user-2", notification.Item.User.ID)
+
+	subscriptions, err := storage.ReadSubscriptions()
+	assert.NoError(err)
+	assert.Len(subscriptions, 1)
+	assert.Equal("user-1", subscriptions[0].User.ID)
+	assert.Equal([]watch.WatchedItem{{Item: shopItem, Subscribers: []string{"user-1"}}}, mediator.WatchedItems())
+
+	assert.NoError(mediator.WatchDiscontinued(regular))
+	assert.Equal(
+		[]watch.WatchedItem{{Item: shopItem, Subscribers: []string{"user-1", "user-2"}}},
+		mediator.WatchedItems(),
+	)
+	subscriptions, err = storage.ReadSubscriptions()
+	assert.NoError(err)
+	assert.Len(subscriptions, 2)
+}
+
+func TestHandleTransition_notifiesMultiSizeSubscriptionWithTheSize(t *testing.T) {
+	storage := storage.NewMemoryStorage()
+	mediator := newTestMediator(storage)
+	assert := assert.New(t)
+
+	shopItem := shop.NewMultiSizeItem("111222", 10, 11)
+	shopItem.Storefront = "uk"
+	_, err := storage.CreateSubscription(subscription.Item{
+		Active:   true,
+		User:     subscription.User{ID: "user-1"},
+		ShopItem: shopItem,
+	})
+	assert.NoError(err)
+
+	// size which is not chosen does not matter
+	mediator.handleTransition(watch.Transition{
+		Item: shopItem.WithSize(12),
+		From: shop.ItemStatusUnknown,
+		To:   shop.ItemStatusInStock,
+	})
+	assert.Len(takeNotifications(t, storage), 0)
+
+	mediator.handleTransition(watch.Transition{
+		Item:   shopItem.WithSize(11),
+		Option: shop.ItemOption{Number: 11, Name: "EU S"},
+		From:   shop.ItemStatusSoldOut,
+		To:     shop.ItemStatusInStock,
+	})
+
+	notifications := takeNotifications(t, storage)
+	assert.Len(notifications, 1)
+	notification := notifications[0]
+	assert.Equal(subscription.NotificationInStock, notification.Kind)
+	assert.Equal("EU S", notification.Size)
+	assert.True(notification.Item.ShopItem.Equal(shopItem))
+
+	subscriptions, err := storage.ReadSubscriptions()
+	assert.NoError(err)
+	assert.Len(subscriptions, 0)
+}
+
+func TestHandleTransition_notifiesEveryActiveSubscriberOnce(t *testing.T) {
+	storage := storage.NewMemoryStorage()
+	mediator := newTestMediator(storage)
+	assert := assert.New(t)
+
+	shopItem := shop.Item{Article: "111222", SizeID: 10, Storefront: "uk"}
+	anySize := shop.NewMultiSizeItem("111222")
+	anySize.Storefront = "uk"
+	for _, item := range []subscription.Item{
+		{Active: true, User: subscription.User{ID: "user-1"}, ShopItem: shopItem},
+		{Active: true, User: subscription.User{ID: "user-1"}, ShopItem: anySize},
+		{Active: true, User: subscription.User{ID: "user-2"}, ShopItem: shopItem, KeepWatching: true},
+		{Active: false, User: subscription.User{ID: "user-3"}, ShopItem: shopItem},
+	} {
+		_, err := storage.CreateSubscription(item)
+		assert.NoError(err)
+	}
+
+	mediator.handleTransition(watch.Transition{Item: shopItem, From: shop.ItemStatusSoldOut, To: shop.ItemStatusInStock})
+
+	notifications := takeNotifications(t, storage)
+	assert.Len(notifications, 2)
+	users := make(map[string]bool)
+	for _, notification := range notifications {
+		users[notification.Item.User.ID] = true
+	}
+	assert.Equal(map[string]bool{"user-1": true, "user-2": true}, users)
+
+	subscriptions, err := storage.ReadSubscriptions()
+	assert.NoError(err)
+	assert.Len(subscriptions, 1)
+	assert.Equal("user-2", subscriptions[0].User.ID)
+	assert.True(subscriptions[0].InStockNotified)
+}
+
+func TestHandleTransition_enqueuesTheSameEventOnce(t *testing.T) {
+	storage := storage.NewMemoryStorage()
+	mediator := newTestMediator(storage)
+	assert := assert.New(t)
+
+	shopItem := shop.Item{Article: "111222", SizeID: 10, Storefront: "uk"}
+	_, err := storage.CreateSubscription(subscription.Item{
+		Active:       true,
+		User:         subscription.User{ID: "user-1"},
+		ShopItem:     shopItem,
+		KeepWatching: true,
+	})
+	assert.NoError(err)
+
+	transition := watch.Transition{
+		Item: shopItem,
+		From: shop.ItemStatusSoldOut,
+		To:   shop.ItemStatusInStock,
+		Time: time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC),
+	}
+	mediator.handleTransition(transition)
+	subscriptions, err := storage.ReadSubscriptions()
+	assert.NoError(err)
+	subscriptions[0].InStockNotified = false
+	assert.NoError(storage.UpdateSubscription(subscriptions[0]))
+	mediator.handleTransition(transition)
+
+	assert.Len(takeNotifications(t, storage), 1)
+}
+
+func TestHandleTransition_notifiesKeptMultiSizeSubscriptionPerSize(t *testing.T) {
+	storage := storage.NewMemoryStorage()
+	mediator := newTestMediator(storage)
+	assert := assert.New(t)
+
+	shopItem := shop.NewMultiSizeItem("111222", 10, 11)
+	shopItem.Storefront = "uk"
+	_, err := storage.CreateSubscription(subscription.Item{
+		Active:       true,
+		User:         subscription.User{ID: "user-1"},
+		ShopItem:     shopItem,
+		KeepWatching: true,
+	})
+	assert.NoError(err)
+
+	now := time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC)
+	for index, change := range []struct {
+		size int
+		to   shop.StockStatus
+	}{
+		{size: 10, to: shop.ItemStatusInStock},
+		{size: 11, to: shop.ItemStatusInStock},
+		{size: 10, to: shop.ItemStatusSoldOut},
+		{size: 11, to: shop.ItemStatusSoldOut},
+		{size: 11, to: shop.ItemStatusInStock},
+	} {
+		from := shop.ItemStatusSoldOut
+		if change.to == shop.ItemStatusSoldOut {
+			from = shop.ItemStatusInStock
+		}
+		mediator.handleTransition(watch.Transition{
+			Item: shop.Item{Article: "111222", SizeID: change.size, Storefront: "uk"},
+			From: from,
+			To:   change.to,
+			Time: now.Add(time.Duration(index) * time.Minute),
+		})
+	}
+
+	notifications := takeNotifications(t, storage)
+	assert.Len(notifications, 3, "every size is notified once per restock")
+
+	subscriptions, err := storage.ReadSubscriptions()
+	assert.NoError(err)
+	assert.Len(subscriptions, 1)
+	assert.True(subscriptions[0].InStockNotified)
+	assert.False(subscriptions[0].Observed(10).InStockNotified)
+	assert.True(subscriptions[0].Observed(11).InStockNotified)
+}
+
+type recordingPublisher struct {
+	events []events.Event
+}
+
+func (p *recordingPublisher) Publish(event events.Event) {
+	p.events = append(p.events, event)
+}
+
+func TestHandleTransition_publishesEvents(t *testing.T) {
+	storage := storage.NewMemoryStorage()
+	mediator := newTestMediator(storage)
+	publisher := &recordingPublisher{}
+	mediator.events = publisher
+	assert := assert.New(t)
+
+	shopItem := shop.Item{Article: "111222", SizeID: 10, Storefront: "uk"}
+	_, err := storage.CreateSubscription(subscription.Item{
+		Active:   true,
+		User:     subscription.User{ID: "user-1"},
+		ShopItem: shopItem,
+	})
+	assert.NoError(err)
+
+	mediator.handleTransition(watch.Transition{Item: shopItem, From: shop.ItemStatusSoldOut, To: shop.ItemStatusInStock})
+
+	assert.Len(publisher.events, 2)
+	assert.Equal(shop.ItemStatusInStock, publisher.events[0].(events.StatusChanged).To)
+	disabled := publisher.events[1].(events.SubscriptionDisabled)
+	assert.Equal("user-1", disabled.Subscription.User.ID)
+	assert.Equal("in stock", disabled.Reason)
+}
+
+func TestRemoveSubscription_stopsWatchingAndEnableSubscriptionResumes(t *testing.T) {
+	storage := storage.NewMemoryStorage()
+	mediator := newTestMediator(storage)
+	assert := assert.New(t)
+
+	item := subscription.Item{
+		Active:   true,
+		User:     subscription.User{ID: "user-1"},
+		ShopItem: shop.NewItem("111-222", 10),
+	}
+	_, err := mediator.CreateSubscription(item)
+	assert.NoError(err)
+	item.ShopItem.Storefront = "uk"
+	assert.Len(mediator.WatchedItems(), 1)
+
+	assert.NoError(mediator.DisableSubscription(item))
+	assert.Len(mediator.WatchedItems(), 0)
+
+	assert.NoError(mediator.EnableSubscription(item))
+	assert.Len(mediator.WatchedItems(), 1)
+
+	removed, err := mediator.RemoveSubscription(item)
+	assert.NoError(err)
+	assert.True(removed)
+	assert.Len(mediator.WatchedItems(), 0)
+}
+
+func TestReconcileAll_watchesOnlyActiveSubscriptions(t *testing.T) {
+	storage := storage.NewMemoryStorage()
+	mediator := newTestMediator(storage)
+	assert := assert.New(t)
+
+	active := shop.Item{Article: "111222", SizeID: 10, Storefront: "uk"}
+	inactive := shop.Item{Article: "111222", SizeID: 12, Storefront: "uk"}
+	for _, item := range []subscription.Item{
+		{Active: true, User: subscription.User{ID: "user-1"}, ShopItem: active},
+		{Active: false, User: subscription.User{ID: "user-1"}, ShopItem: inactive},
+	} {
+		_, err := storage.CreateSubscription(item)
+		assert.NoError(err)
+	}
+	// stray subscriber left after the storage has been changed behind the mediator's back
+	assert.NoError(mediator.watcher.AddItem(inactive, "user-1"))
+	assert.NoError(mediator.watcher.AddItem(active, "user-2"))
+
+	assert.NoError(mediator.reconcileAll())
+
+	assert.Equal([]watch.WatchedItem{{Item: active, Subscribers: []string{"user-1"}}}, mediator.WatchedItems())
+}
+
+func TestHandleObservation_isRecordedInStockHistory(t *testing.T) {
+	strg := storage.NewMemoryStorage()
+	mediator := newTestMediator(strg)
+	recorder := history.NewRecorder(strg, &history.Config{})
+	publisher := &recordingPublisher{}
+	mediator.events = publisher
+	assert := assert.New(t)
+
+	now := time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC)
+	shopItem := shop.Item{Article: "111222", SizeID: 10, Storefront: "uk"}
+	for index, status := range []shop.StockStatus{
+		shop.ItemStatusSoldOut,
+		shop.ItemStatusSoldOut,
+		shop.ItemStatusInStock,
+	} {
+		mediator.handleObservation(watch.Observation{
+			Item:   shopItem,
+			Option: shop.ItemOption{Name: "3-4 years", Number: 10, Price: "£12", StockStatus: status},
+			Time:   now.Add(time.Duration(index) * time.Hour),
+		})
+	}
+	for _, event := range publisher.events {
+		recorder.Handle(event)
+	}
+
+	changes, err := mediator.StockHistory("111222")
+	assert.NoError(err)
+	assert.Len(changes, 2, "repeated status is not a change")
+	assert.Equal(shop.ItemStatusSoldOut, changes[0].Status)
+	assert.Equal(shop.ItemStatusInStock, changes[1].Status)
+	assert.Equal(shop.Money{Amount: 1200, Currency: "GBP"}, changes[1].Price)
+	assert.Equal(now.Add(2*time.Hour), changes[1].Time)
+}
+
+func TestSetPriceDropAlert_isNotOverwrittenByConcurrentObservation(t *testing.T) {
+	storage := storage.NewMemoryStorage()
+	mediator := newTestMediator(storage)
+	assert := assert.New(t)
+
+	item := subscription.Item{
+		Active:   true,
+		User:     subscription.User{ID: "user-1"},
+		ShopItem: shop.Item{Article: "111222", SizeID: 10, Storefront: "uk"},
+	}
+	_, err := storage.CreateSubscription(item)
+	assert.NoError(err)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			mediator.handleObservation(watch.Observation{
+				Item:   item.ShopItem,
+				Option: shop.ItemOption{Number: 10, Price: fmt.Sprintf("£%d", 10+i)},
+			})
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		assert.NoError(mediator.SetPriceDropAlert(item, true))
+	}
+	wg.Wait()
+
+	subscriptions, err := storage.ReadSubscriptions()
+	assert.NoError(err)
+	assert.True(subscriptions[0].NotifyOnPriceDrop)
+	assert.Equal(shop.Money{Amount: 20900, Currency: "GBP"}, subscriptions[0].LastPrice)
+}
+
+func TestHandleObservation_tracksPriceOfEverySizeOfMultiSizeSubscription(t *testing.T) {
+	storage := storage.NewMemoryStorage()
+	mediator := newTestMediator(storage)
+	assert := assert.New(t)
+
+	shopItem := shop.NewMultiSizeItem("111222", 10, 11)
+	shopItem.Storefront = "uk"
+	_, err := storage.CreateSubscription(subscription.Item{
+		Active:            true,
+		User:              subscription.User{ID: "user-1"},
+		ShopItem:          shopItem,
+		NotifyOnPriceDrop: true,
+	})
+	assert.NoError(err)
+
+	for _, observed := range []struct {
+		size  int
+		price string
+	}{
+		{size: 10, price: "£12"},
+		{size: 11, price: "£14"},
+		{size: 10, price: "£12"},
+		{size: 11, price: "£10"},
+		{size: 12, price: "£5"},
+	} {
+		mediator.handleObservation(watch.Observation{
+			Item:   shopItem.WithSize(observed.size),
+			Option: shop.ItemOption{Name: fmt.Sprintf("size %d", observed.size), Number: observed.size, Price: observed.price},
+		})
+	}
+
+	notifications := takeNotifications(t, storage)
+	assert.Len(notifications, 1, "sizes are compared with their own prices only")
+	assert.Equal(subscription.NotificationPriceDrop, notifications[0].Kind)
+	assert.Equal("size 11", notifications[0].Size)
+	assert.Equal(shop.Money{Amount: 1400, Currency: "GBP"}, notifications[0].PreviousPrice)
+
+	subscriptions, err := storage.ReadSubscriptions()
+	assert.NoError(err)
+	assert.Equal(map[int]subscription.SizeState{
+		10: {LastPrice: shop.Money{Amount: 1200, Currency: "GBP"}},
+		11: {LastPrice: shop.Money{Amount: 1000, Currency: "GBP"}},
+	}, subscriptions[0].Sizes)
+}
+
+// countingStorage counts reads of all active subscriptions
+type countingStorage struct {
+	*storage.MemoryStorage
+	fullReads int
+}
+
+func (s *countingStorage) ReadSubscriptions() ([]subscription.Item, error) {
+	s.fullReads++
+
+	return s.MemoryStorage.ReadSubscriptions()
+}
+
+func TestHandleInStockItem_reconcilesOnlyTheItem(t *testing.T) {
+	strg := &countingStorage{MemoryStorage: storage.NewMemoryStorage()}
+	mediator := newTestMediator(strg.MemoryStorage)
+	mediator.StorageBackend = strg
+	assert := assert.New(t)
+
+	shopItem := shop.Item{Article: "111222", SizeID: 10, Storefront: "uk"}
+	other := shop.Item{Article: "333444", SizeID: 10, Storefront: "uk"}
+	for _, item := range []subscription.Item{
+		{Active: true, User: subscription.User{ID: "user-1"}, ShopItem: shopItem},
+		{Active: true, User: subscription.User{ID: "user-2"}, ShopItem: shopItem},
+		{Active: true, User: subscription.User{ID: "user-1"}, ShopItem: other},
+	} {
+		_, err := strg.CreateSubscription(item)
+		assert.NoError(err)
+	}
+	assert.NoError(mediator.reconcileAll())
+	strg.fullReads = 0
+
+	mediator.handleInStockItem(watch.Transition{Item: shopItem})
+
+	assert.Equal(0, strg.fullReads)
+	assert.Equal([]watch.WatchedItem{{Item: other, Subscribers: []string{"user-1"}}}, mediator.WatchedItems())
+}
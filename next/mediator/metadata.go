@@ -0,0 +1,177 @@
+package mediator
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/subscription"
+)
+
+const (
+	// metadataRefreshInterval is how often subscriptions are checked for missing or stale item metadata
+	metadataRefreshInterval = time.Hour
+	// metadataMaxAge is how long item metadata is trusted before it is fetched again
+	metadataMaxAge = 7 * 24 * time.Hour
+)
+
+// staleArticle groups shop items of one article which need their metadata refreshed,
+// so the article is fetched once for all of them
+type staleArticle struct {
+	Storefront string
+	Article    string
+	Items      []shop.Item
+	// RefreshedAt is the oldest refresh time among the items
+	RefreshedAt time.Time
+}
+
+// metadataStale checks whether the subscription lacks item metadata or it has not been refreshed for too long
+func metadataStale(item subscription.Item, now time.Time) bool {
+	shopItem := item.ShopItem
+	if shopItem.Description == "" || shopItem.SizeString == "" || shopItem.URL == "" {
+		return true
+	}
+
+	return now.Sub(item.MetadataRefreshedAt) > metadataMaxAge
+}
+
+// staleArticles lists articles of subscriptions with stale metadata, the longest not refreshed go first
+func staleArticles(subscriptions []subscription.Item, now time.Time) []*staleArticle {
+	articles := make([]*staleArticle, 0)
+	byKey := make(map[string]*staleArticle)
+
+	for _, item := range subscriptions {
+		if !metadataStale(item, now) {
+			continue
+		}
+
+		key := item.ShopItem.Storefront + "|" + item.ShopItem.Article
+		article, ok := byKey[key]
+		if !ok {
+			article = &staleArticle{
+				Storefront:  item.ShopItem.Storefront,
+				Article:     item.ShopItem.Article,
+				RefreshedAt: item.MetadataRefreshedAt,
+			}
+			byKey[key] = article
+			articles = append(articles, article)
+		}
+
+		if item.MetadataRefreshedAt.Before(article.RefreshedAt) {
+			article.RefreshedAt = item.MetadataRefreshedAt
+		}
+
+		known := false
+		for _, shopItem := range article.Items {
+			if shopItem.Equal(item.ShopItem) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			article.Items = append(article.Items, item.ShopItem)
+		}
+	}
+
+	sort.SliceStable(articles, func(i, j int) bool {
+		return articles[i].RefreshedAt.Before(articles[j].RefreshedAt)
+	})
+
+	return articles
+}
+
+// refreshMetadataLoop refreshes item metadata on start and then periodically till the mediator is stopped
+func (m *SubscriptionMediator) refreshMetadataLoop() {
+	m.refreshMetadata(time.Now())
+
+	ticker := time.NewTicker(metadataRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.refreshMetadata(now)
+		}
+	}
+}
+
+// refreshMetadata re-fetches description, size names and URL of articles
+// whose active subscriptions have missing or stale metadata
+func (m *SubscriptionMediator) refreshMetadata(now time.Time) {
+	subscriptions, err := m.StorageBackend.ReadSubscriptions()
+	if err != nil {
+		log.Println("[ERROR] mediator: could not read subscriptions to refresh metadata: " + err.Error())
+		return
+	}
+
+	articles := staleArticles(subscriptions, now)
+	if len(articles) == 0 {
+		return
+	}
+
+	refreshed := 0
+	for _, article := range articles {
+		if m.ctx.Err() != nil {
+			return
+		}
+
+		if err := m.refreshArticle(article, now); err != nil {
+			log.Printf("[WARN] mediator: could not refresh metadata of article %s: %v", article.Article, err)
+			continue
+		}
+		refreshed++
+	}
+
+	log.Printf("[INFO] mediator: refreshed metadata of %d out of %d articles", refreshed, len(articles))
+}
+
+// refreshArticle fetches the article once and updates metadata of all its shop items in storage.
+// If only part of metadata is fetched, it is stored anyway and the article stays stale to be fetched again.
+func (m *SubscriptionMediator) refreshArticle(article *staleArticle, now time.Time) error {
+	client, err := m.storefronts.Client(article.Storefront)
+	if err != nil {
+		return err
+	}
+
+	// requests are made with background priority, so they never hold up users
+	extendedOptions, optionsErr := client.GetItemExtendedOptionContext(m.ctx, article.Article)
+	url, urlErr := client.GetItemURLByArticleContext(m.ctx, article.Article)
+	if optionsErr != nil && urlErr != nil {
+		return optionsErr
+	}
+
+	refreshedAt := now
+	if optionsErr != nil || urlErr != nil {
+		refreshedAt = article.RefreshedAt
+	}
+
+	m.subscriptionsLock.Lock()
+	defer m.subscriptionsLock.Unlock()
+
+	for _, item := range article.Items {
+		if urlErr == nil {
+			item.URL = url
+		}
+		if optionsErr == nil {
+			item.Description = extendedOptions.Description
+			if item.SizeID == shop.SizeAny {
+				item.SizeString = sizeString(client, extendedOptions.Options, item.SizeIDs)
+			} else if option, ok := client.FindOptionBySize(extendedOptions.Options, item.SizeID); ok {
+				item.SizeString = option.Name
+			}
+		}
+
+		if err := m.StorageBackend.UpdateSubscriptionMetadata(item, refreshedAt); err != nil {
+			return err
+		}
+	}
+
+	if optionsErr != nil {
+		return optionsErr
+	}
+
+	return urlErr
+}
@@ -0,0 +1,187 @@
+package mediator
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/events"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/storage"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/subscription"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/testutils"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/watch"
+)
+
+func TestRefreshMetadata_fillsMissingAndStaleMetadata(t *testing.T) {
+	strg := storage.NewMemoryStorage()
+	watcher, _ := watch.New(nil, &watch.Config{UpdateInterval: 2 * time.Second})
+	requests := make(map[string]int)
+	mediator := New(
+		strg,
+		strg,
+		strg,
+		events.NewBus(),
+		watcher,
+		next.NewStorefronts("uk", map[string]*next.Client{
+			"uk": next.NewClient(
+				testutils.NewClientWithHandler(func(req *http.Request) (*http.Response, error) {
+					requests[req.URL.Path]++
+					if strings.HasPrefix(req.URL.Path, "/uk/itemstock/getextendedoptions") {
+						return testutils.NewResponse(http.StatusOK, `{
+							"Description": "Pyjamas",
+							"Options": [
+								{"OptionName": "3-4 years", "OptionNumber": "10"},
+								{"OptionName": "4-5 years", "OptionNumber": "11"}
+							]
+						}`), nil
+					}
+
+					response := testutils.NewResponse(http.StatusOK, "")
+					response.Request = &http.Request{URL: &url.URL{
+						Scheme:   "https",
+						Host:     "www.next.co.uk",
+						Path:     "/style/st123/111222",
+						Fragment: "111222",
+					}}
+
+					return response, nil
+				}),
+				next.Config{Lang: "uk"},
+			),
+		}),
+	)
+	assert := assert.New(t)
+
+	now := time.Now()
+	subscriptions := []subscription.Item{
+		{
+			Active:   true,
+			User:     subscription.User{ID: "user-1"},
+			ShopItem: shop.Item{Article: "111222", SizeID: 10, Storefront: "uk"},
+		},
+		{
+			Active:   true,
+			User:     subscription.User{ID: "user-2"},
+			ShopItem: shop.NewMultiSizeItem("111222", 10, 11),
+			// refreshed long ago, so the size names may be outdated
+			MetadataRefreshedAt: now.Add(-2 * metadataMaxAge),
+		},
+		{
+			Active: true,
+			User:   subscription.User{ID: "user-3"},
+			ShopItem: shop.Item{
+				Article:     "333444",
+				SizeID:      5,
+				Description: "Shirt",
+				SizeString:  "M",
+				URL:         "https://www.next.co.uk/style/st333/333444#333444",
+			},
+			MetadataRefreshedAt: now.Add(-time.Hour),
+		},
+	}
+	subscriptions[1].ShopItem.Description = "Old pyjamas"
+	subscriptions[1].ShopItem.SizeString = "10, 11"
+	subscriptions[1].ShopItem.URL = "https://www.next.co.uk/old"
+	subscriptions[1].ShopItem.Storefront = "uk"
+	for _, item := range subscriptions {
+		_, err := strg.CreateSubscription(item)
+		assert.NoError(err)
+	}
+
+	mediator.refreshMetadata(now)
+
+	// the article is fetched once for both of its subscriptions, fresh subscription is left alone
+	assert.Equal(map[string]int{"/uk/itemstock/getextendedoptions/111222": 1, "/uk/search": 1}, requests)
+
+	refreshed, err := strg.ReadAllSubscriptions()
+	assert.NoError(err)
+	assert.Len(refreshed, 3)
+	for _, item := range refreshed {
+		switch item.User.ID {
+		case "user-1":
+			assert.Equal("Pyjamas", item.ShopItem.Description)
+			assert.Equal("3-4 years", item.ShopItem.SizeString)
+			assert.Equal("https://www.next.co.uk/style/st123/111222#111222", item.ShopItem.URL)
+			assert.True(now.Equal(item.MetadataRefreshedAt))
+		case "user-2":
+			assert.Equal("Pyjamas", item.ShopItem.Description)
+			assert.Equal("3-4 years, 4-5 years", item.ShopItem.SizeString)
+			assert.Equal("https://www.next.co.uk/style/st123/111222#111222", item.ShopItem.URL)
+			assert.True(now.Equal(item.MetadataRefreshedAt))
+		case "user-3":
+			assert.Equal("Shirt", item.ShopItem.Description)
+			assert.True(now.Add(-time.Hour).Equal(item.MetadataRefreshedAt))
+		}
+	}
+}
+
+func TestStaleArticles_ordersByOldestRefresh(t *testing.T) {
+	now := time.Now()
+	fresh := shop.Item{Article: "555-666", SizeID: 1, Description: "Coat", SizeString: "S", URL: "https://next/coat"}
+	subscriptions := []subscription.Item{
+		{ShopItem: fresh, MetadataRefreshedAt: now},
+		{ShopItem: shop.Item{Article: "111-222", SizeID: 1}, MetadataRefreshedAt: now.Add(-time.Hour)},
+		{ShopItem: shop.Item{Article: "333-444", SizeID: 1}},
+		{ShopItem: shop.Item{Article: "111-222", SizeID: 1}, MetadataRefreshedAt: now.Add(-time.Minute)},
+		{ShopItem: shop.Item{Article: "111-222", SizeID: 2}, MetadataRefreshedAt: now},
+	}
+
+	articles := staleArticles(subscriptions, now)
+
+	assert := assert.New(t)
+	assert.Len(articles, 2)
+	assert.Equal("333-444", articles[0].Article)
+	assert.Equal("111-222", articles[1].Article)
+	assert.Equal([]shop.Item{{Article: "111-222", SizeID: 1}, {Article: "111-222", SizeID: 2}}, articles[1].Items)
+}
+
+func TestRefreshArticle_storesPartialMetadataWhenURLLookupFails(t *testing.T) {
+	strg := storage.NewMemoryStorage()
+	watcher, _ := watch.New(nil, &watch.Config{UpdateInterval: 2 * time.Second})
+	mediator := New(
+		strg,
+		strg,
+		strg,
+		events.NewBus(),
+		watcher,
+		next.NewStorefronts("uk", map[string]*next.Client{
+			"uk": next.NewClient(
+				testutils.NewClientWithHandler(func(req *http.Request) (*http.Response, error) {
+					if strings.HasPrefix(req.URL.Path, "/uk/itemstock/getextendedoptions") {
+						return testutils.NewResponse(http.StatusOK, `{
+							"Description": "Pyjamas",
+							"Options": [{"OptionName": "3-4 years", "OptionNumber": "10"}]
+						}`), nil
+					}
+
+					return testutils.NewResponse(http.StatusInternalServerError, ""), nil
+				}),
+				next.Config{Lang: "uk"},
+			),
+		}),
+	)
+	assert := assert.New(t)
+
+	now := time.Now()
+	_, err := strg.CreateSubscription(subscription.Item{
+		Active:   true,
+		User:     subscription.User{ID: "user-1"},
+		ShopItem: shop.Item{Article: "111222", SizeID: 10, Storefront: "uk"},
+	})
+	assert.NoError(err)
+
+	mediator.refreshMetadata(now)
+
+	subscriptions, err := strg.ReadSubscriptions()
+	assert.NoError(err)
+	assert.Equal("Pyjamas", subscriptions[0].ShopItem.Description)
+	assert.Equal("3-4 years", subscriptions[0].ShopItem.SizeString)
+	assert.Empty(subscriptions[0].ShopItem.URL)
+	assert.True(metadataStale(subscriptions[0], now), "missing URL is fetched again next time")
+}
@@ -0,0 +1,26 @@
+package outbox
+
+import "time"
+
+const (
+	defaultPollInterval   = 2 * time.Second
+	defaultInitialBackoff = 5 * time.Second
+	defaultMaxBackoff     = 10 * time.Minute
+	defaultMaxAttempts    = 10
+	// defaultDeliveredRetention keeps delivered entries long enough to recognize repeated events
+	defaultDeliveredRetention = 7 * 24 * time.Hour
+)
+
+// Config holds configuration of notification delivery
+type Config struct {
+	// PollInterval is how often pending notifications are looked up
+	PollInterval time.Duration
+	// InitialBackoff is the delay after the first failed attempt, it doubles with every next failure
+	InitialBackoff time.Duration
+	// MaxBackoff limits the delay between attempts
+	MaxBackoff time.Duration
+	// MaxAttempts is how many times delivery is tried before the notification is moved to dead letters
+	MaxAttempts int
+	// DeliveredRetention is how long delivered notifications are kept, dead ones are kept for inspection
+	DeliveredRetention time.Duration
+}
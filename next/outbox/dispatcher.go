@@ -0,0 +1,190 @@
+package outbox
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/events"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/subscription"
+)
+
+const (
+	// batchSize limits number of notifications delivered per poll
+	batchSize = 50
+	// cleanupInterval is how often delivered notifications past the retention are removed
+	cleanupInterval = time.Hour
+)
+
+// Deliverer delivers the notification to its recipient, nil error means the delivery is acknowledged
+type Deliverer func(subscription.Notification) error
+
+// Dispatcher delivers persisted notifications and retries failed deliveries with exponential backoff
+type Dispatcher struct {
+	store          Store
+	deliver        Deliverer
+	events         events.Publisher
+	pollInterval   time.Duration
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	maxAttempts    int
+	retention      time.Duration
+	stopCh         chan struct{}
+	stopOnce       sync.Once
+}
+
+// Start runs the delivery loop until Stop is called
+func (d *Dispatcher) Start() {
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+	cleanupTicker := time.NewTicker(cleanupInterval)
+	defer cleanupTicker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			d.dispatchDue(now)
+		case now := <-cleanupTicker.C:
+			d.cleanup(now)
+		case <-d.stopCh:
+			return
+		}
+	}
+}
+
+// Stop stops the delivery loop, pending notifications stay in the store
+func (d *Dispatcher) Stop() {
+	d.stopOnce.Do(func() {
+		log.Println("[INFO] Stopping notification dispatcher")
+		close(d.stopCh)
+	})
+}
+
+// DeadLetters lists notifications which could not be delivered
+func (d *Dispatcher) DeadLetters() ([]Entry, error) {
+	return d.store.ReadDeadNotifications()
+}
+
+// dispatchDue tries to deliver notifications whose time has come
+func (d *Dispatcher) dispatchDue(now time.Time) {
+	entries, err := d.store.ReadDueNotifications(now, batchSize)
+	if err != nil {
+		log.Printf("[ERROR] outbox: could not read due notifications: %s\n", err.Error())
+		return
+	}
+
+	for _, entry := range entries {
+		d.attempt(entry, now)
+	}
+}
+
+// cleanup removes delivered notifications which are older than the retention
+func (d *Dispatcher) cleanup(now time.Time) {
+	removed, err := d.store.RemoveDeliveredNotifications(now.Add(-d.retention))
+	if err != nil {
+		log.Printf("[ERROR] outbox: could not remove delivered notifications: %s\n", err.Error())
+		return
+	}
+
+	if removed > 0 {
+		log.Printf("[INFO] outbox: %d delivered notification(s) removed\n", removed)
+	}
+}
+
+// attempt delivers the entry and records the outcome
+func (d *Dispatcher) attempt(entry Entry, now time.Time) {
+	err := d.deliver(entry.Notification)
+
+	var postponed *PostponedError
+	switch {
+	case err == nil:
+		entry.Status = StatusDelivered
+		entry.DeliveredAt = now
+		entry.LastError = ""
+		d.events.Publish(events.NotificationSent{Notification: entry.Notification, Attempts: entry.Attempts, Time: now})
+	case errors.As(err, &postponed):
+		entry.NextAttempt = postponed.Until
+	default:
+		entry.Attempts++
+		entry.LastError = err.Error()
+		if entry.Attempts >= d.maxAttempts {
+			log.Printf("[ERROR] outbox: giving up delivery of %s notification to user <%s> after %d attempts: %s\n",
+				entry.Notification.Kind,
+				entry.Notification.Item.User.ID,
+				entry.Attempts,
+				err.Error(),
+			)
+			entry.Status = StatusDead
+		} else {
+			entry.NextAttempt = now.Add(d.backoff(entry.Attempts))
+			log.Printf("[WARN] outbox: delivery of %s notification to user <%s> failed, retrying at %s: %s\n",
+				entry.Notification.Kind,
+				entry.Notification.Item.User.ID,
+				entry.NextAttempt.Format(time.RFC3339),
+				err.Error(),
+			)
+		}
+		d.events.Publish(events.NotificationFailed{
+			Notification: entry.Notification,
+			Attempts:     entry.Attempts,
+			Error:        entry.LastError,
+			GaveUp:       entry.Status == StatusDead,
+			Time:         now,
+		})
+	}
+
+	if err := d.store.UpdateNotification(entry); err != nil {
+		log.Printf("[ERROR] outbox: could not update notification %s: %s\n", entry.ID, err.Error())
+	}
+}
+
+// backoff returns delay before the next attempt after given number of failed ones
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.initialBackoff
+	for i := 1; i < attempts && delay < d.maxBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > d.maxBackoff {
+		return d.maxBackoff
+	}
+
+	return delay
+}
+
+// NewDispatcher instantiates Dispatcher delivering notifications from the store, outcomes are published as events
+func NewDispatcher(store Store, deliver Deliverer, publisher events.Publisher, config *Config) *Dispatcher {
+	d := &Dispatcher{
+		store:          store,
+		deliver:        deliver,
+		events:         publisher,
+		pollInterval:   config.PollInterval,
+		initialBackoff: config.InitialBackoff,
+		maxBackoff:     config.MaxBackoff,
+		maxAttempts:    config.MaxAttempts,
+		retention:      config.DeliveredRetention,
+		stopCh:         make(chan struct{}),
+	}
+
+	if d.pollInterval <= 0 {
+		d.pollInterval = defaultPollInterval
+	}
+	if d.initialBackoff <= 0 {
+		d.initialBackoff = defaultInitialBackoff
+	}
+	if d.maxBackoff <= 0 {
+		d.maxBackoff = defaultMaxBackoff
+	}
+	if d.maxBackoff < d.initialBackoff {
+		d.maxBackoff = d.initialBackoff
+	}
+	if d.maxAttempts <= 0 {
+		d.maxAttempts = defaultMaxAttempts
+	}
+	if d.retention <= 0 {
+		d.retention = defaultDeliveredRetention
+	}
+
+	return d
+}
@@ -0,0 +1,180 @@
+package outbox
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/events"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/subscription"
+)
+
+type fakeStore struct {
+	entries map[string]Entry
+}
+
+func (s *fakeStore) EnqueueNotification(entry Entry) (bool, error) {
+	if _, ok := s.entries[entry.ID]; ok {
+		return false, nil
+	}
+	s.entries[entry.ID] = entry
+
+	return true, nil
+}
+
+func (s *fakeStore) ReadDueNotifications(now time.Time, limit int) ([]Entry, error) {
+	var ret []Entry
+	for _, entry := range s.entries {
+		if entry.Status == StatusPending && !entry.NextAttempt.After(now) && len(ret) < limit {
+			ret = append(ret, entry)
+		}
+	}
+
+	return ret, nil
+}
+
+func (s *fakeStore) ReadDeadNotifications() ([]Entry, error) {
+	var ret []Entry
+	for _, entry := range s.entries {
+		if entry.Status == StatusDead {
+			ret = append(ret, entry)
+		}
+	}
+
+	return ret, nil
+}
+
+func (s *fakeStore) UpdateNotification(entry Entry) error {
+	s.entries[entry.ID] = entry
+
+	return nil
+}
+
+func (s *fakeStore) RemoveDeliveredNotifications(before time.Time) (int64, error) {
+	var removed int64
+	for id, entry := range s.entries {
+		if entry.Status == StatusDelivered && entry.DeliveredAt.Before(before) {
+			delete(s.entries, id)
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+func TestDispatcher_retriesWithBackoffUntilDelivered(t *testing.T) {
+	assert := assert.New(t)
+	store := &fakeStore{entries: make(map[string]Entry)}
+	failures := 2
+	var delivered []subscription.Notification
+	dispatcher := NewDispatcher(store, func(notification subscription.Notification) error {
+		if failures > 0 {
+			failures--
+			return errors.New("telegram is down")
+		}
+		delivered = append(delivered, notification)
+
+		return nil
+	}, events.NewBus(), &Config{InitialBackoff: time.Second, MaxBackoff: time.Minute})
+
+	now := time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC)
+	entry := NewEntry(subscription.Notification{Kind: subscription.NotificationInStock, Time: now}, now)
+	_, err := store.EnqueueNotification(entry)
+	assert.NoError(err)
+
+	dispatcher.dispatchDue(now)
+	assert.Equal(now.Add(time.Second), store.entries[entry.ID].NextAttempt)
+
+	dispatcher.dispatchDue(now.Add(500 * time.Millisecond))
+	assert.Equal(1, store.entries[entry.ID].Attempts, "the next attempt is not due yet")
+
+	dispatcher.dispatchDue(now.Add(time.Second))
+	assert.Equal(now.Add(3*time.Second), store.entries[entry.ID].NextAttempt)
+	assert.Equal("telegram is down", store.entries[entry.ID].LastError)
+
+	dispatcher.dispatchDue(now.Add(3 * time.Second))
+	assert.Len(delivered, 1)
+	assert.Equal(StatusDelivered, store.entries[entry.ID].Status)
+
+	dispatcher.dispatchDue(now.Add(time.Hour))
+	assert.Len(delivered, 1, "delivered notification is not sent again")
+}
+
+func TestDispatcher_movesFailingNotificationsToDeadLetters(t *testing.T) {
+	assert := assert.New(t)
+	store := &fakeStore{entries: make(map[string]Entry)}
+	dispatcher := NewDispatcher(store, func(subscription.Notification) error {
+		return errors.New("bot was blocked by the user")
+	}, events.NewBus(), &Config{MaxAttempts: 2})
+
+	now := time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC)
+	_, err := store.EnqueueNotification(NewEntry(subscription.Notification{Kind: subscription.NotificationInStock}, now))
+	assert.NoError(err)
+
+	dispatcher.dispatchDue(now)
+	dispatcher.dispatchDue(now.Add(time.Hour))
+
+	dead, err := dispatcher.DeadLetters()
+	assert.NoError(err)
+	assert.Len(dead, 1)
+	assert.Equal(2, dead[0].Attempts)
+}
+
+func TestDispatcher_postponesWithoutCountingAttempts(t *testing.T) {
+	assert := assert.New(t)
+	store := &fakeStore{entries: make(map[string]Entry)}
+	now := time.Date(2026, 1, 15, 23, 30, 0, 0, time.UTC)
+	morning := now.Add(7*time.Hour + 30*time.Minute)
+	dispatcher := NewDispatcher(store, func(subscription.Notification) error {
+		return &PostponedError{Until: morning}
+	}, events.NewBus(), &Config{})
+
+	entry := NewEntry(subscription.Notification{Kind: subscription.NotificationInStock}, now)
+	_, err := store.EnqueueNotification(entry)
+	assert.NoError(err)
+
+	dispatcher.dispatchDue(now)
+
+	assert.Equal(0, store.entries[entry.ID].Attempts)
+	assert.Equal(morning, store.entries[entry.ID].NextAttempt)
+	assert.Equal(StatusPending, store.entries[entry.ID].Status)
+}
+
+func TestKey_differsForDifferentEvents(t *testing.T) {
+	now := time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC)
+	notification := subscription.Notification{
+		Kind: subscription.NotificationInStock,
+		Item: subscription.Item{User: subscription.User{ID: "user-1"}},
+		Time: now,
+	}
+	other := notification
+	other.Item.User.ID = "user-2"
+	later := notification
+	later.Time = now.Add(time.Minute)
+
+	assert.Equal(t, Key(notification), Key(notification))
+	assert.NotEqual(t, Key(notification), Key(other))
+	assert.NotEqual(t, Key(notification), Key(later))
+}
+
+func TestDispatcher_removesDeliveredNotificationsPastRetention(t *testing.T) {
+	assert := assert.New(t)
+	store := &fakeStore{entries: make(map[string]Entry)}
+	dispatcher := NewDispatcher(store, func(subscription.Notification) error {
+		return nil
+	}, events.NewBus(), &Config{DeliveredRetention: 24 * time.Hour})
+
+	now := time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC)
+	entry := NewEntry(subscription.Notification{Kind: subscription.NotificationInStock, Time: now}, now)
+	_, err := store.EnqueueNotification(entry)
+	assert.NoError(err)
+	dispatcher.dispatchDue(now)
+
+	dispatcher.cleanup(now.Add(time.Hour))
+	assert.Len(store.entries, 1, "recently delivered notification is kept")
+
+	dispatcher.cleanup(now.Add(25 * time.Hour))
+	assert.Len(store.entries, 0)
+}
@@ -0,0 +1,91 @@
+package outbox
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/subscription"
+)
+
+// Status is a delivery state of the outbox entry
+type Status string
+
+const (
+	// StatusPending entries are waiting for (another) delivery attempt
+	StatusPending Status = "pending"
+	// StatusDelivered entries are acknowledged by the recipient's messenger
+	StatusDelivered Status = "delivered"
+	// StatusDead entries ran out of delivery attempts, they are kept for inspection
+	StatusDead Status = "dead"
+)
+
+// Entry is a notification persisted until it is delivered
+type Entry struct {
+	// ID is an idempotency key, the same notification is enqueued only once
+	ID           string `bson:"_id"`
+	Notification subscription.Notification
+	Status       Status
+	Attempts     int
+	NextAttempt  time.Time
+	LastError    string
+	CreatedAt    time.Time
+	DeliveredAt  time.Time
+}
+
+// Store persists outbox entries
+type Store interface {
+	// EnqueueNotification stores new entry, false is returned if entry with the same ID already exists
+	EnqueueNotification(Entry) (bool, error)
+	// ReadDueNotifications reads pending entries whose next attempt is due, the oldest attempts go first
+	ReadDueNotifications(now time.Time, limit int) ([]Entry, error)
+	// ReadDeadNotifications reads entries which ran out of delivery attempts
+	ReadDeadNotifications() ([]Entry, error)
+	UpdateNotification(Entry) error
+	// RemoveDeliveredNotifications removes entries delivered before given time, number of removed ones is returned
+	RemoveDeliveredNotifications(before time.Time) (int64, error)
+}
+
+// NewEntry builds pending entry of the notification keyed by its content
+func NewEntry(notification subscription.Notification, now time.Time) Entry {
+	return Entry{
+		ID:           Key(notification),
+		Notification: notification,
+		Status:       StatusPending,
+		NextAttempt:  now,
+		CreatedAt:    now,
+	}
+}
+
+// Key builds idempotency key of the notification: the same event reported to the same user has the same key
+func Key(n subscription.Notification) string {
+	hash := sha256.New()
+	_, _ = fmt.Fprintf(hash, "%s|%s|%s|%s|%d|%v|%s|%s|%s|%s|%s|%s|%d",
+		n.Kind,
+		n.Item.User.ID,
+		n.Item.ShopItem.Storefront,
+		n.Item.ShopItem.Article,
+		n.Item.ShopItem.SizeID,
+		n.Item.ShopItem.SizeIDs,
+		n.Size,
+		n.Status,
+		n.Price,
+		n.PreviousPrice,
+		n.StockMessage,
+		n.PreviousStockMessage,
+		n.Time.UnixNano(),
+	)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// PostponedError is returned by Deliverer when the notification must not be delivered until particular time,
+// e.g. during user's quiet hours. Postponing does not count as a failed attempt.
+type PostponedError struct {
+	Until time.Time
+}
+
+func (e *PostponedError) Error() string {
+	return "delivery is postponed till " + e.Until.Format(time.RFC3339)
+}
@@ -0,0 +1,124 @@
+package schedule
+
+import (
+	"fmt"
+	"time"
+)
+
+// clockLayout is the format of window boundaries
+const clockLayout = "15:04"
+
+// WindowConfig describes a daily time window, e.g. from "06:00" till "01:00".
+// The window may span midnight, the end is not included.
+type WindowConfig struct {
+	Start string
+	End   string
+}
+
+// Window is a daily time window in particular time zone
+type Window struct {
+	start    time.Duration
+	end      time.Duration
+	location *time.Location
+}
+
+// Contains checks whether the moment is inside the window, nil window contains any moment
+func (w *Window) Contains(t time.Time) bool {
+	if w == nil {
+		return true
+	}
+
+	clock := sinceMidnight(t.In(w.location))
+	if w.start < w.end {
+		return clock >= w.start && clock < w.end
+	}
+
+	return clock >= w.start || clock < w.end
+}
+
+// NextEnd returns the closest moment after t when the window ends
+func (w *Window) NextEnd(t time.Time) time.Time {
+	return w.next(t, w.end)
+}
+
+// NextStart returns the closest moment after t when the window starts
+func (w *Window) NextStart(t time.Time) time.Time {
+	return w.next(t, w.start)
+}
+
+func (w *Window) next(t time.Time, clock time.Duration) time.Time {
+	t = t.In(w.location)
+	hours, minutes := int(clock/time.Hour), int(clock%time.Hour/time.Minute)
+
+	next := time.Date(t.Year(), t.Month(), t.Day(), hours, minutes, 0, 0, w.location)
+	if !next.After(t) {
+		next = time.Date(t.Year(), t.Month(), t.Day()+1, hours, minutes, 0, 0, w.location)
+	}
+
+	return next
+}
+
+func (w *Window) String() string {
+	if w == nil {
+		return "always"
+	}
+
+	return fmt.Sprintf("%s-%s %s", formatClock(w.start), formatClock(w.end), w.location)
+}
+
+// NewWindow parses window configuration, nil is returned if the window is not configured
+func NewWindow(config WindowConfig, location *time.Location) (*Window, error) {
+	if config.Start == "" && config.End == "" {
+		return nil, nil
+	}
+
+	start, err := parseClock(config.Start)
+	if err != nil {
+		return nil, err
+	}
+
+	end, err := parseClock(config.End)
+	if err != nil {
+		return nil, err
+	}
+
+	if start == end {
+		return nil, fmt.Errorf("window start and end must differ, got %s", config.Start)
+	}
+
+	if location == nil {
+		location = time.Local
+	}
+
+	return &Window{start: start, end: end, location: location}, nil
+}
+
+// LoadLocation loads time zone by its IANA name, empty name means local time zone
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+
+	return time.LoadLocation(name)
+}
+
+func parseClock(value string) (time.Duration, error) {
+	parsed, err := time.Parse(clockLayout, value)
+	if err != nil {
+		return 0, fmt.Errorf("could not parse time of day '%s', HH:MM is expected", value)
+	}
+
+	return time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute, nil
+}
+
+func formatClock(clock time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(clock/time.Hour), int(clock%time.Hour/time.Minute))
+}
+
+func sinceMidnight(t time.Time) time.Duration {
+	hours := time.Duration(t.Hour()) * time.Hour
+	minutes := time.Duration(t.Minute()) * time.Minute
+	seconds := time.Duration(t.Second()) * time.Second
+
+	return hours + minutes + seconds
+}
@@ -0,0 +1,75 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWindowContains(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	assert.NoError(t, err)
+
+	window, err := NewWindow(WindowConfig{Start: "06:00", End: "01:00"}, london)
+	assert.NoError(t, err)
+
+	tests := map[string]bool{
+		"2026-01-15T05:59:00Z": false,
+		"2026-01-15T06:00:00Z": true,
+		"2026-01-15T23:30:00Z": true,
+		"2026-01-16T00:59:59Z": true,
+		"2026-01-16T01:00:00Z": false,
+		// 05:30 UTC is 06:30 in London in summer
+		"2026-07-15T05:30:00Z": true,
+	}
+
+	for moment, expected := range tests {
+		parsed, err := time.Parse(time.RFC3339, moment)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, window.Contains(parsed), moment)
+	}
+}
+
+func TestWindowContains_sameDayWindow(t *testing.T) {
+	window, err := NewWindow(WindowConfig{Start: "09:00", End: "18:00"}, time.UTC)
+	assert.NoError(t, err)
+
+	assert.False(t, window.Contains(time.Date(2026, 1, 15, 8, 59, 0, 0, time.UTC)))
+	assert.True(t, window.Contains(time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)))
+	assert.False(t, window.Contains(time.Date(2026, 1, 15, 18, 0, 0, 0, time.UTC)))
+}
+
+func TestWindowNextEnd(t *testing.T) {
+	window, err := NewWindow(WindowConfig{Start: "23:00", End: "07:30"}, time.UTC)
+	assert.NoError(t, err)
+
+	assert.Equal(t,
+		time.Date(2026, 1, 16, 7, 30, 0, 0, time.UTC),
+		window.NextEnd(time.Date(2026, 1, 15, 23, 15, 0, 0, time.UTC)),
+	)
+	assert.Equal(t,
+		time.Date(2026, 1, 15, 7, 30, 0, 0, time.UTC),
+		window.NextEnd(time.Date(2026, 1, 15, 2, 0, 0, 0, time.UTC)),
+	)
+	assert.Equal(t,
+		time.Date(2026, 1, 15, 23, 0, 0, 0, time.UTC),
+		window.NextStart(time.Date(2026, 1, 15, 8, 0, 0, 0, time.UTC)),
+	)
+}
+
+func TestNewWindow(t *testing.T) {
+	window, err := NewWindow(WindowConfig{}, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, window)
+	assert.True(t, window.Contains(time.Now()), "missing window contains any moment")
+
+	_, err = NewWindow(WindowConfig{Start: "06:00"}, nil)
+	assert.Error(t, err)
+
+	_, err = NewWindow(WindowConfig{Start: "6am", End: "01:00"}, nil)
+	assert.Error(t, err)
+
+	_, err = NewWindow(WindowConfig{Start: "06:00", End: "06:00"}, nil)
+	assert.Error(t, err)
+}
@@ -3,18 +3,88 @@ package shop
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 )
 
+// SizeAny is used as Item.SizeID when any size of the article is watched, or one of Item.SizeIDs if they are set
+const SizeAny = -1
+
 // Item describes one particular item
 type Item struct {
-	Article     string
-	SizeID      int
+	Article string
+	SizeID  int
+	// SizeIDs lists chosen sizes when SizeID is SizeAny, empty list means any size at all
+	SizeIDs     []int
+	Storefront  string
 	Description string
 	SizeString  string
 	URL         string
 }
 
+// Equal checks whether both items point to the same article and size(s) at the same storefront
+func (i Item) Equal(other Item) bool {
+	if i.Article != other.Article || i.SizeID != other.SizeID || i.Storefront != other.Storefront {
+		return false
+	}
+
+	if len(i.SizeIDs) != len(other.SizeIDs) {
+		return false
+	}
+	for index := range i.SizeIDs {
+		if i.SizeIDs[index] != other.SizeIDs[index] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CoversSize checks whether the size is one of the item's sizes
+func (i Item) CoversSize(size int) bool {
+	if i.SizeID != SizeAny {
+		return i.SizeID == size
+	}
+
+	if len(i.SizeIDs) == 0 {
+		return true
+	}
+
+	for _, id := range i.SizeIDs {
+		if id == size {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Covers checks whether the item with particular size is one of the item's sizes at the same storefront
+func (i Item) Covers(other Item) bool {
+	return i.Article == other.Article && i.Storefront == other.Storefront && i.CoversSize(other.SizeID)
+}
+
+// WithSize returns copy of the item pointing to particular size
+func (i Item) WithSize(size int) Item {
+	i.SizeID = size
+	i.SizeIDs = nil
+
+	return i
+}
+
+// NewMultiSizeItem instantiates new Item object watching several sizes, no sizes means any size
+func NewMultiSizeItem(article string, sizes ...int) Item {
+	item := NewItem(article, SizeAny)
+	if len(sizes) == 0 {
+		return item
+	}
+
+	item.SizeIDs = append([]int(nil), sizes...)
+	sort.Ints(item.SizeIDs)
+
+	return item
+}
+
 // ItemExtendedOption holds extended option response from Next API
 type ItemExtendedOption struct {
 	Description string
@@ -23,26 +93,22 @@ type ItemExtendedOption struct {
 
 // ItemOption holds option data in ItemExtendedOption Options[] slice
 type ItemOption struct {
-	Article           string
-	Name              string `json:"OptionName"`
-	Number            int    `json:"OptionNumber,string"`
-	Price             string
-	StockStatusString string `json:"StockStatus"`
+	Article     string
+	Name        string `json:"OptionName"`
+	Number      int    `json:"OptionNumber,string"`
+	Price       string
+	StockStatus StockStatus
+	// StockMessage is a human-readable restock estimate, e.g. "середина января"
+	StockMessage string
 }
 
-const (
-	// ItemStatusInStock holds string representation of in-stock item
-	ItemStatusInStock = "InStock"
-
-	// ItemStatusComingSoon holds string representation of coming soon item
-	ItemStatusComingSoon = "ComingSoon"
-
-	// ItemStatusUnknown is a placeholder for unknown status
-	ItemStatusUnknown = "Unknown"
-)
+// ParsedPrice parses raw price string of the option
+func (item ItemOption) ParsedPrice() (Money, error) {
+	return ParsePrice(item.Price)
+}
 
 func (item ItemOption) String() string {
-	return fmt.Sprintf("[%s] %s, %s", item.StockStatusString, item.Name, item.Price)
+	return fmt.Sprintf("[%s] %s, %s", item.StockStatus, item.Name, item.Price)
 }
 
 // NormalizeArticle normalizes article to meet canonical representation
@@ -57,3 +123,25 @@ func NormalizeArticle(article string) string {
 func NewItem(article string, size int) Item {
 	return Item{Article: NormalizeArticle(article), SizeID: size}
 }
+
+// Product describes a product found by search
+type Product struct {
+	Article  string
+	Title    string
+	Price    string
+	URL      string
+	ImageURL string
+}
+
+// SearchResult holds a single page of search results
+type SearchResult struct {
+	Products []Product
+	Total    int
+	Page     int
+	PageSize int
+}
+
+// HasNextPage checks whether there are more results after current page
+func (r SearchResult) HasNextPage() bool {
+	return r.Page*r.PageSize < r.Total
+}
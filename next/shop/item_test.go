@@ -23,3 +23,37 @@ func TestNormalizeArticle(t *testing.T) {
 		})
 	}
 }
+
+func TestItemEqual(t *testing.T) {
+	item := Item{Article: "111222", SizeID: 10, Storefront: "uk", Description: "Pyjamas"}
+
+	assert := assert.New(t)
+	assert.True(item.Equal(Item{Article: "111222", SizeID: 10, Storefront: "uk"}))
+	assert.False(item.Equal(Item{Article: "111222", SizeID: 11, Storefront: "uk"}))
+	assert.False(item.Equal(Item{Article: "111222", SizeID: 10, Storefront: "ua"}))
+	assert.False(item.Equal(Item{Article: "111333", SizeID: 10, Storefront: "uk"}))
+}
+
+func TestItemCovers(t *testing.T) {
+	assert := assert.New(t)
+
+	single := Item{Article: "111222", SizeID: 10, Storefront: "uk"}
+	anySize := NewMultiSizeItem("111-222")
+	anySize.Storefront = "uk"
+	several := NewMultiSizeItem("111-222", 12, 10)
+	several.Storefront = "uk"
+
+	assert.Equal([]int{10, 12}, several.SizeIDs)
+	assert.False(anySize.Equal(several))
+	assert.True(several.Equal(Item{Article: "111222", SizeID: SizeAny, SizeIDs: []int{10, 12}, Storefront: "uk"}))
+
+	for _, size := range []int{10, 11, 12} {
+		concrete := single.WithSize(size)
+		assert.Equal(size == 10, single.Covers(concrete), size)
+		assert.True(anySize.Covers(concrete), size)
+		assert.Equal(size != 11, several.Covers(concrete), size)
+	}
+
+	assert.False(anySize.Covers(Item{Article: "111222", SizeID: 10, Storefront: "ua"}))
+	assert.Equal(Item{Article: "111222", SizeID: 11, Storefront: "uk"}, several.WithSize(11))
+}
@@ -0,0 +1,157 @@
+package shop
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// currencySymbols maps symbols and abbreviations used by storefronts to ISO 4217 currency codes
+var currencySymbols = []struct {
+	symbol   string
+	currency string
+}{
+	{symbol: "грн", currency: "UAH"},
+	{symbol: "₴", currency: "UAH"},
+	{symbol: "uah", currency: "UAH"},
+	{symbol: "£", currency: "GBP"},
+	{symbol: "gbp", currency: "GBP"},
+	{symbol: "€", currency: "EUR"},
+	{symbol: "eur", currency: "EUR"},
+	{symbol: "$", currency: "USD"},
+	{symbol: "usd", currency: "USD"},
+}
+
+// Money holds an amount in minor units (pennies, cents, kopecks) and ISO 4217 currency code
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// IsZero checks whether the price is not known
+func (m Money) IsZero() bool {
+	return m.Amount == 0 && m.Currency == ""
+}
+
+// Comparable checks whether both amounts are in the same currency
+func (m Money) Comparable(other Money) bool {
+	return m.Currency == other.Currency
+}
+
+// Less checks whether the amount is less than other one of the same currency
+func (m Money) Less(other Money) bool {
+	return m.Comparable(other) && m.Amount < other.Amount
+}
+
+func (m Money) String() string {
+	amount := fmt.Sprintf("%d.%02d", m.Amount/100, m.Amount%100)
+	if m.Currency == "" {
+		return amount
+	}
+
+	return amount + " " + m.Currency
+}
+
+// ParsePrice parses price strings of different storefronts,
+// e.g. "635 грн", "£22.50", "29,99 €" or "1.234,50 €".
+// For price ranges like "£18 - £22" the lowest price is returned.
+// Currency is empty if it is not present in the string.
+func ParsePrice(s string) (Money, error) {
+	lower := strings.ToLower(s)
+
+	var money Money
+	for _, cs := range currencySymbols {
+		if strings.Contains(lower, cs.symbol) {
+			money.Currency = cs.currency
+			break
+		}
+	}
+
+	number := firstNumber(s)
+	if number == "" {
+		return Money{}, fmt.Errorf("no amount found in price <%s>", s)
+	}
+
+	amount, err := parseAmount(number)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid price <%s>: %s", s, err.Error())
+	}
+	money.Amount = amount
+
+	return money, nil
+}
+
+// firstNumber extracts the first sequence of digits with group and decimal separators
+func firstNumber(s string) string {
+	var b strings.Builder
+	started := false
+	for _, r := range s {
+		switch {
+		case unicode.IsDigit(r):
+			started = true
+			b.WriteRune(r)
+		case started && (r == '.' || r == ','):
+			b.WriteRune(r)
+		case started && (r == ' ' || r == '\u00a0' || r == '\u202f'):
+			// thousands separator in some locales
+		case started:
+			return strings.TrimRight(b.String(), ".,")
+		}
+	}
+
+	return strings.TrimRight(b.String(), ".,")
+}
+
+// parseAmount converts a number with locale specific separators into minor units
+func parseAmount(number string) (int64, error) {
+	integer, fraction := number, ""
+
+	lastDot := strings.LastIndex(number, ".")
+	lastComma := strings.LastIndex(number, ",")
+	decimalAt := -1
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		// both are present: the last one separates decimals
+		decimalAt = lastDot
+		if lastComma > lastDot {
+			decimalAt = lastComma
+		}
+	case lastDot >= 0 || lastComma >= 0:
+		separator := lastDot
+		if lastComma >= 0 {
+			separator = lastComma
+		}
+		// a single separator followed by other than 3 digits is a decimal one, e.g. 29,99 and 22.5 but not 1.234
+		if strings.Count(number, number[separator:separator+1]) == 1 && len(number)-separator-1 != 3 {
+			decimalAt = separator
+		}
+	}
+
+	if decimalAt >= 0 {
+		integer, fraction = number[:decimalAt], number[decimalAt+1:]
+	}
+
+	integer = strings.NewReplacer(".", "", ",", "").Replace(integer)
+	if integer == "" {
+		integer = "0"
+	}
+
+	if len(fraction) > 2 {
+		return 0, errors.New("too many decimal digits")
+	}
+	fraction += strings.Repeat("0", 2-len(fraction))
+
+	major, err := strconv.ParseInt(integer, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	minor, err := strconv.ParseInt(fraction, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return major*100 + minor, nil
+}
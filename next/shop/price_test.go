@@ -0,0 +1,55 @@
+package shop
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Money
+	}{
+		{input: "635 грн", expected: Money{Amount: 63500, Currency: "UAH"}},
+		{input: "1 235 грн", expected: Money{Amount: 123500, Currency: "UAH"}},
+		{input: "1\u00a0235 грн", expected: Money{Amount: 123500, Currency: "UAH"}},
+		{input: "£22", expected: Money{Amount: 2200, Currency: "GBP"}},
+		{input: "£22.50", expected: Money{Amount: 2250, Currency: "GBP"}},
+		{input: "£1,022.50", expected: Money{Amount: 102250, Currency: "GBP"}},
+		{input: "£18 - £22", expected: Money{Amount: 1800, Currency: "GBP"}},
+		{input: "29,99 €", expected: Money{Amount: 2999, Currency: "EUR"}},
+		{input: "1.234,50 €", expected: Money{Amount: 123450, Currency: "EUR"}},
+		{input: "1.234 €", expected: Money{Amount: 123400, Currency: "EUR"}},
+		{input: "500", expected: Money{Amount: 50000}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run("Parse <"+test.input+">", func(t *testing.T) {
+			money, err := ParsePrice(test.input)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, money)
+		})
+	}
+}
+
+func TestParsePrice_returnsErrorWithoutAmount(t *testing.T) {
+	for _, input := range []string{"", "грн", "free"} {
+		_, err := ParsePrice(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestMoneyLess(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(Money{Amount: 100, Currency: "GBP"}.Less(Money{Amount: 200, Currency: "GBP"}))
+	assert.False(Money{Amount: 200, Currency: "GBP"}.Less(Money{Amount: 100, Currency: "GBP"}))
+	assert.False(Money{Amount: 100, Currency: "GBP"}.Less(Money{Amount: 200, Currency: "EUR"}))
+}
+
+func TestMoneyString(t *testing.T) {
+	assert.Equal(t, "635.00 UAH", Money{Amount: 63500, Currency: "UAH"}.String())
+	assert.Equal(t, "22.05", Money{Amount: 2205}.String())
+}
@@ -0,0 +1,86 @@
+package shop
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// restockMonths lists prefixes of month names in English, Russian and Ukrainian, e.g. "end of January",
+// "середина января" or "кінець січня"
+var restockMonths = [][]string{
+	{"jan", "янв", "січ"},
+	{"feb", "фев", "лют"},
+	{"mar", "мар", "берез"},
+	{"apr", "апр", "квіт"},
+	{"may", "мая", "май", "трав"},
+	{"jun", "июн", "черв"},
+	{"jul", "июл", "лип"},
+	{"aug", "авг", "серп"},
+	{"sep", "сен", "верес"},
+	{"oct", "окт", "жовт"},
+	{"nov", "ноя", "листоп"},
+	{"dec", "дек", "груд"},
+}
+
+// restockPeriodDays maps words naming part of the month to its last day, the end of the month is the default
+var restockPeriodDays = map[string]int{
+	"early":     10,
+	"beginning": 10,
+	"start":     10,
+	"начало":    10,
+	"початок":   10,
+	"mid":       20,
+	"middle":    20,
+	"середина":  20,
+}
+
+// RestockEstimate guesses the latest date the coming soon item is expected by from its stock message,
+// e.g. "середина января" or "end of January". False is returned if the message names no month.
+func RestockEstimate(message string, now time.Time) (time.Time, bool) {
+	words := strings.FieldsFunc(strings.ToLower(message), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	month, day := time.Month(0), 0
+	for _, word := range words {
+		if d, ok := restockPeriodDays[word]; ok {
+			day = d
+			continue
+		}
+		if m, ok := restockMonth(word); ok && month == 0 {
+			month = m
+		}
+	}
+	if month == 0 {
+		return time.Time{}, false
+	}
+
+	// the month closest to now is meant, e.g. January mentioned in December is the one of the next year
+	year := now.Year()
+	switch {
+	case month-now.Month() < -6:
+		year++
+	case month-now.Month() > 6:
+		year--
+	}
+
+	// day 0 of the next month is the last day of the month
+	if day == 0 {
+		return time.Date(year, month+1, 0, 0, 0, 0, 0, now.Location()), true
+	}
+
+	return time.Date(year, month, day, 0, 0, 0, 0, now.Location()), true
+}
+
+func restockMonth(word string) (time.Month, bool) {
+	for index, prefixes := range restockMonths {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(word, prefix) {
+				return time.Month(index + 1), true
+			}
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,36 @@
+package shop
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestockEstimate(t *testing.T) {
+	now := time.Date(2026, 12, 10, 15, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		message  string
+		expected time.Time
+	}{
+		"russian middle":    {"середина декабря", time.Date(2026, 12, 20, 0, 0, 0, 0, time.UTC)},
+		"russian beginning": {"Начало января", time.Date(2027, 1, 10, 0, 0, 0, 0, time.UTC)},
+		"russian end":       {"конец февраля", time.Date(2027, 2, 28, 0, 0, 0, 0, time.UTC)},
+		"ukrainian":         {"кінець листопада", time.Date(2026, 11, 30, 0, 0, 0, 0, time.UTC)},
+		"english":           {"Coming soon: mid-March", time.Date(2027, 3, 20, 0, 0, 0, 0, time.UTC)},
+		"month only":        {"December", time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)},
+		"half a year ago":   {"end of July", time.Date(2026, 7, 31, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for name, test := range tests {
+		estimate, ok := RestockEstimate(test.message, now)
+		assert.True(t, ok, name)
+		assert.Equal(t, test.expected, estimate, name)
+	}
+
+	_, ok := RestockEstimate("coming soon", now)
+	assert.False(t, ok)
+	_, ok = RestockEstimate("", now)
+	assert.False(t, ok)
+}
@@ -0,0 +1,87 @@
+package shop
+
+import (
+	"encoding/json"
+	"expvar"
+	"log"
+)
+
+// StockStatus describes availability of an item option as reported by Next
+type StockStatus string
+
+const (
+	// ItemStatusInStock means the option can be bought right now
+	ItemStatusInStock StockStatus = "InStock"
+
+	// ItemStatusLowStock means the option can be bought, but only a few pieces are left
+	ItemStatusLowStock StockStatus = "LowStock"
+
+	// ItemStatusLastFew is another flavour of low stock used by some storefronts
+	ItemStatusLastFew StockStatus = "LastFew"
+
+	// ItemStatusComingSoon means the option is expected to be restocked, see ItemOption.StockMessage
+	ItemStatusComingSoon StockStatus = "ComingSoon"
+
+	// ItemStatusSoldOut means the option is sold out and no restock is expected
+	ItemStatusSoldOut StockStatus = "SoldOut"
+
+	// ItemStatusNotAvailable means the option is not sold at the storefront
+	ItemStatusNotAvailable StockStatus = "NotAvailable"
+
+	// ItemStatusDiscontinued is not returned by Next, it is used when the article is not found for a while
+	ItemStatusDiscontinued StockStatus = "Discontinued"
+
+	// ItemStatusUnknown is a placeholder for unknown status
+	ItemStatusUnknown StockStatus = "Unknown"
+)
+
+// unknownStockStatuses counts unrecognized statuses returned by Next, so API changes are noticed
+var unknownStockStatuses = expvar.NewMap("next_unknown_stock_statuses")
+
+// Known checks whether the status is one of the statuses Next is known to return
+func (s StockStatus) Known() bool {
+	switch s {
+	case ItemStatusInStock, ItemStatusLowStock, ItemStatusLastFew,
+		ItemStatusComingSoon, ItemStatusSoldOut, ItemStatusNotAvailable:
+		return true
+	}
+
+	return false
+}
+
+// Purchasable checks whether the item option with the status can be bought
+func (s StockStatus) Purchasable() bool {
+	return s == ItemStatusInStock || s == ItemStatusLowStock || s == ItemStatusLastFew
+}
+
+// UnmarshalJSON keeps the raw value of unrecognized statuses and reports them
+func (s *StockStatus) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*s = StockStatus(raw)
+	if !s.Known() {
+		reportUnknownStockStatus(raw)
+	}
+
+	return nil
+}
+
+func reportUnknownStockStatus(raw string) {
+	if unknownStockStatuses.Get(raw) == nil {
+		log.Printf("[WARN] shop: unknown stock status '%s', Next API might have changed\n", raw)
+	}
+	unknownStockStatuses.Add(raw, 1)
+}
+
+// UnknownStockStatusCount returns how many times the unrecognized status has been seen
+func UnknownStockStatusCount(raw string) int64 {
+	counter, ok := unknownStockStatuses.Get(raw).(*expvar.Int)
+	if !ok {
+		return 0
+	}
+
+	return counter.Value()
+}
@@ -0,0 +1,37 @@
+package shop
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStockStatus_UnmarshalJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	var option ItemOption
+	assert.NoError(json.Unmarshal([]byte(`{"StockStatus": "LowStock"}`), &option))
+	assert.Equal(ItemStatusLowStock, option.StockStatus)
+	assert.True(option.StockStatus.Known())
+	assert.True(option.StockStatus.Purchasable())
+
+	seen := UnknownStockStatusCount("BackOrder")
+	assert.NoError(json.Unmarshal([]byte(`{"StockStatus": "BackOrder"}`), &option))
+	assert.Equal(StockStatus("BackOrder"), option.StockStatus)
+	assert.False(option.StockStatus.Known())
+	assert.False(option.StockStatus.Purchasable())
+	assert.Equal(seen+1, UnknownStockStatusCount("BackOrder"), "counters are process-wide, only the change is checked")
+}
+
+func TestStockStatus_Purchasable(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, status := range []StockStatus{ItemStatusInStock, ItemStatusLowStock, ItemStatusLastFew} {
+		assert.True(status.Purchasable(), status)
+	}
+
+	for _, status := range []StockStatus{ItemStatusComingSoon, ItemStatusSoldOut, ItemStatusNotAvailable, ItemStatusUnknown} {
+		assert.False(status.Purchasable(), status)
+	}
+}
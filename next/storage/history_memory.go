@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"sort"
+	"time"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/history"
+)
+
+// maxHistoryRecords limits number of stock history records kept in memory, the oldest ones are dropped
+const maxHistoryRecords = 10000
+
+// AddHistoryRecord stores the stock history record
+func (m *MemoryStorage) AddHistoryRecord(record history.Record) error {
+	m.historyLock.Lock()
+	defer m.historyLock.Unlock()
+
+	m.history = append(m.history, record)
+	if len(m.history) > maxHistoryRecords {
+		m.history = append([]history.Record(nil), m.history[len(m.history)-maxHistoryRecords:]...)
+	}
+
+	return nil
+}
+
+// ReadHistory reads up to history.ReadLimit latest records of the article at every storefront, the oldest go first
+func (m *MemoryStorage) ReadHistory(article string) ([]history.Record, error) {
+	m.historyLock.Lock()
+	defer m.historyLock.Unlock()
+
+	ret := make([]history.Record, 0)
+	for _, record := range m.history {
+		if record.Article == article {
+			ret = append(ret, record)
+		}
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Time.Before(ret[j].Time)
+	})
+	if len(ret) > history.ReadLimit {
+		ret = ret[len(ret)-history.ReadLimit:]
+	}
+
+	return ret, nil
+}
+
+// RemoveHistoryBefore removes records older than given time, number of removed records is returned
+func (m *MemoryStorage) RemoveHistoryBefore(before time.Time) (int64, error) {
+	m.historyLock.Lock()
+	defer m.historyLock.Unlock()
+
+	kept := make([]history.Record, 0, len(m.history))
+	for _, record := range m.history {
+		if !record.Time.Before(before) {
+			kept = append(kept, record)
+		}
+	}
+
+	removed := int64(len(m.history) - len(kept))
+	m.history = kept
+
+	return removed, nil
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/history"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
+)
+
+func TestStorageMemory_historyKeepsRecordsTillRetention(t *testing.T) {
+	testHistoryKeepsRecordsTillRetention(t, NewMemoryStorage())
+}
+
+func testHistoryKeepsRecordsTillRetention(t *testing.T, store history.Store) {
+	assert := assert.New(t)
+	now := time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	records := []history.Record{
+		{
+			Storefront: "uk",
+			Article:    "111222",
+			SizeID:     10,
+			SizeName:   "3-4 years",
+			Status:     shop.ItemStatusInStock,
+			Price:      shop.Money{Amount: 1200, Currency: "GBP"},
+			Time:       now,
+		},
+		{
+			Storefront:   "uk",
+			Article:      "111222",
+			SizeID:       10,
+			SizeName:     "3-4 years",
+			Status:       shop.ItemStatusComingSoon,
+			StockMessage: "end of January",
+			Time:         now.Add(-time.Hour),
+		},
+		{Storefront: "uk", Article: "333444", SizeID: 5, Status: shop.ItemStatusSoldOut, Time: now},
+		{Storefront: "uk", Article: "111222", SizeID: 11, Status: shop.ItemStatusSoldOut, Time: now.Add(-48 * time.Hour)},
+	}
+	for _, record := range records {
+		assert.NoError(store.AddHistoryRecord(record))
+	}
+
+	read, err := store.ReadHistory("111222")
+	assert.NoError(err)
+	assert.Equal([]history.Record{records[3], records[1], records[0]}, read, "records of the article, the oldest first")
+
+	removed, err := store.RemoveHistoryBefore(now.Add(-24 * time.Hour))
+	assert.NoError(err)
+	assert.Equal(int64(1), removed)
+
+	read, err = store.ReadHistory("111222")
+	assert.NoError(err)
+	assert.Equal([]history.Record{records[1], records[0]}, read)
+}
+
+func TestStorageMemory_historyIsBounded(t *testing.T) {
+	strg := NewMemoryStorage()
+	assert := assert.New(t)
+	now := time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	for i := 0; i < maxHistoryRecords+10; i++ {
+		assert.NoError(strg.AddHistoryRecord(history.Record{
+			Article: "111222",
+			SizeID:  10,
+			Time:    now.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+
+	assert.Len(strg.history, maxHistoryRecords)
+	assert.Equal(now.Add(10*time.Minute), strg.history[0].Time)
+}
+
+func TestStorageMemory_historyReadsLatestRecords(t *testing.T) {
+	testHistoryReadsLatestRecords(t, NewMemoryStorage())
+}
+
+func testHistoryReadsLatestRecords(t *testing.T, store history.Store) {
+	assert := assert.New(t)
+	now := time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	for i := 0; i < history.ReadLimit+10; i++ {
+		assert.NoError(store.AddHistoryRecord(history.Record{
+			Article: "111222",
+			SizeID:  10,
+			Time:    now.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+
+	records, err := store.ReadHistory("111222")
+	assert.NoError(err)
+	assert.Len(records, history.ReadLimit)
+	assert.Equal(now.Add(10*time.Minute), records[0].Time)
+	assert.Equal(now.Add(time.Duration(history.ReadLimit+9)*time.Minute), records[len(records)-1].Time)
+}
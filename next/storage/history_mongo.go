@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/history"
+)
+
+// AddHistoryRecord stores the stock history record
+func (m *MongoStorage) AddHistoryRecord(record history.Record) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := m.client.Database("next").Collection("history").InsertOne(ctx, &record)
+
+	return err
+}
+
+// createHistoryIndexes lets history of the article be read and outdated records be removed
+// without scanning the whole collection
+func (m *MongoStorage) createHistoryIndexes(ctx context.Context) error {
+	_, err := m.client.Database("next").Collection("history").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "article", Value: 1}, {Key: "time", Value: 1}}},
+		{Keys: bson.D{{Key: "time", Value: 1}}},
+	})
+
+	return err
+}
+
+// ReadHistory reads up to history.ReadLimit latest records of the article at every storefront, the oldest go first
+func (m *MongoStorage) ReadHistory(article string) ([]history.Record, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// the latest records are taken and put in chronological order afterwards
+	cursor, err := m.client.Database("next").Collection("history").Find(
+		ctx,
+		bson.M{"article": article},
+		options.Find().SetSort(bson.D{{Key: "time", Value: -1}}).SetLimit(history.ReadLimit),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	returnRecords := make([]history.Record, 0)
+	if err := cursor.All(ctx, &returnRecords); err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(returnRecords)-1; i < j; i, j = i+1, j-1 {
+		returnRecords[i], returnRecords[j] = returnRecords[j], returnRecords[i]
+	}
+
+	return returnRecords, nil
+}
+
+// RemoveHistoryBefore removes records older than given time, number of removed records is returned
+func (m *MongoStorage) RemoveHistoryBefore(before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := m.client.Database("next").Collection("history").DeleteMany(ctx, bson.M{"time": bson.M{"$lt": before}})
+	if err != nil {
+		return 0, err
+	}
+
+	return res.DeletedCount, nil
+}
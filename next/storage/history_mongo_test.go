@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorageMongo_historyKeepsRecordsTillRetention(t *testing.T) {
+	assert := assert.New(t)
+
+	container, err := createContainer()
+	assert.NoError(err)
+	defer func() {
+		if err := container.stop(); err != nil {
+			panic("Cannot stop mongo container")
+		}
+	}()
+
+	strg, err := NewMongo(fmt.Sprintf("mongodb://127.0.0.1:%d", container.Port))
+	assert.NoError(err)
+
+	testHistoryKeepsRecordsTillRetention(t, strg)
+}
+
+func TestStorageMongo_historyReadsLatestRecords(t *testing.T) {
+	assert := assert.New(t)
+
+	container, err := createContainer()
+	assert.NoError(err)
+	defer func() {
+		if err := container.stop(); err != nil {
+			panic("Cannot stop mongo container")
+		}
+	}()
+
+	strg, err := NewMongo(fmt.Sprintf("mongodb://127.0.0.1:%d", container.Port))
+	assert.NoError(err)
+
+	testHistoryReadsLatestRecords(t, strg)
+}
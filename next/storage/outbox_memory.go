@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/outbox"
+)
+
+// EnqueueNotification stores the outbox entry unless entry with the same ID exists
+func (m *MemoryStorage) EnqueueNotification(entry outbox.Entry) (bool, error) {
+	m.outboxLock.Lock()
+	defer m.outboxLock.Unlock()
+
+	if _, ok := m.outbox[entry.ID]; ok {
+		return false, nil
+	}
+
+	m.outbox[entry.ID] = &entry
+
+	return true, nil
+}
+
+// ReadDueNotifications reads pending entries whose next attempt is due, the oldest attempts go first
+func (m *MemoryStorage) ReadDueNotifications(now time.Time, limit int) ([]outbox.Entry, error) {
+	m.outboxLock.Lock()
+	defer m.outboxLock.Unlock()
+
+	ret := make([]outbox.Entry, 0)
+	for _, entry := range m.outbox {
+		if entry.Status == outbox.StatusPending && !entry.NextAttempt.After(now) {
+			ret = append(ret, *entry)
+		}
+	}
+
+	sortEntries(ret)
+	if len(ret) > limit {
+		ret = ret[:limit]
+	}
+
+	return ret, nil
+}
+
+// ReadDeadNotifications reads entries which ran out of delivery attempts
+func (m *MemoryStorage) ReadDeadNotifications() ([]outbox.Entry, error) {
+	m.outboxLock.Lock()
+	defer m.outboxLock.Unlock()
+
+	ret := make([]outbox.Entry, 0)
+	for _, entry := range m.outbox {
+		if entry.Status == outbox.StatusDead {
+			ret = append(ret, *entry)
+		}
+	}
+
+	sortEntries(ret)
+
+	return ret, nil
+}
+
+// UpdateNotification replaces stored outbox entry with the given one
+func (m *MemoryStorage) UpdateNotification(entry outbox.Entry) error {
+	m.outboxLock.Lock()
+	defer m.outboxLock.Unlock()
+
+	if _, ok := m.outbox[entry.ID]; !ok {
+		return errors.New("no notification found")
+	}
+
+	m.outbox[entry.ID] = &entry
+
+	return nil
+}
+
+// RemoveDeliveredNotifications removes entries delivered before given time, number of removed ones is returned
+func (m *MemoryStorage) RemoveDeliveredNotifications(before time.Time) (int64, error) {
+	m.outboxLock.Lock()
+	defer m.outboxLock.Unlock()
+
+	var removed int64
+	for id, entry := range m.outbox {
+		if entry.Status == outbox.StatusDelivered && entry.DeliveredAt.Before(before) {
+			delete(m.outbox, id)
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+func sortEntries(entries []outbox.Entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].NextAttempt.Equal(entries[j].NextAttempt) {
+			return entries[i].NextAttempt.Before(entries[j].NextAttempt)
+		}
+
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+}
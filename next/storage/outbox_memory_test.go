@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/outbox"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/subscription"
+)
+
+func TestStorageMemory_outboxKeepsEntriesTillDelivery(t *testing.T) {
+	testOutboxKeepsEntriesTillDelivery(t, NewMemoryStorage())
+}
+
+func testOutboxKeepsEntriesTillDelivery(t *testing.T, store outbox.Store) {
+	assert := assert.New(t)
+	now := time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	first := outbox.NewEntry(subscription.Notification{
+		Kind: subscription.NotificationInStock,
+		Item: subscription.Item{User: subscription.User{ID: "user-1"}},
+		Time: now,
+	}, now)
+	second := outbox.NewEntry(subscription.Notification{
+		Kind: subscription.NotificationInStock,
+		Item: subscription.Item{User: subscription.User{ID: "user-2"}},
+		Time: now,
+	}, now.Add(time.Second))
+
+	for _, entry := range []outbox.Entry{first, second} {
+		enqueued, err := store.EnqueueNotification(entry)
+		assert.NoError(err)
+		assert.True(enqueued)
+	}
+	enqueued, err := store.EnqueueNotification(first)
+	assert.NoError(err)
+	assert.False(enqueued, "the same notification is enqueued once")
+
+	due, err := store.ReadDueNotifications(now, 10)
+	assert.NoError(err)
+	assert.Len(due, 1)
+	assert.Equal("user-1", due[0].Notification.Item.User.ID)
+
+	due, err = store.ReadDueNotifications(now.Add(time.Minute), 10)
+	assert.NoError(err)
+	assert.Len(due, 2)
+	assert.Equal(first.ID, due[0].ID)
+
+	first.Status = outbox.StatusDelivered
+	first.DeliveredAt = now
+	assert.NoError(store.UpdateNotification(first))
+	second.Status = outbox.StatusDead
+	second.Attempts = 10
+	assert.NoError(store.UpdateNotification(second))
+
+	due, err = store.ReadDueNotifications(now.Add(time.Minute), 10)
+	assert.NoError(err)
+	assert.Len(due, 0)
+
+	dead, err := store.ReadDeadNotifications()
+	assert.NoError(err)
+	assert.Len(dead, 1)
+	assert.Equal(10, dead[0].Attempts)
+
+	assert.Error(store.UpdateNotification(outbox.Entry{ID: "missing"}))
+
+	removed, err := store.RemoveDeliveredNotifications(now)
+	assert.NoError(err)
+	assert.Equal(int64(0), removed)
+
+	removed, err = store.RemoveDeliveredNotifications(now.Add(time.Hour))
+	assert.NoError(err)
+	assert.Equal(int64(1), removed, "only delivered notification is removed")
+
+	dead, err = store.ReadDeadNotifications()
+	assert.NoError(err)
+	assert.Len(dead, 1)
+
+	enqueued, err = store.EnqueueNotification(first)
+	assert.NoError(err)
+	assert.True(enqueued, "removed notification may be enqueued again")
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/outbox"
+)
+
+// duplicateKeyErrorCodes are codes MongoDB reports when unique index, e.g. _id, is violated
+var duplicateKeyErrorCodes = map[int]bool{11000: true, 11001: true, 12582: true}
+
+// EnqueueNotification stores the outbox entry unless entry with the same ID exists.
+// The entry is inserted right away, so concurrent enqueues of the same notification can't both succeed.
+func (m *MongoStorage) EnqueueNotification(entry outbox.Entry) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := m.client.Database("next").Collection("outbox").InsertOne(ctx, &entry); err != nil {
+		if isDuplicateKeyError(err) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+// isDuplicateKeyError checks whether the write failed because document with the same key exists
+func isDuplicateKeyError(err error) bool {
+	var writeException mongo.WriteException
+	if errors.As(err, &writeException) {
+		for _, writeError := range writeException.WriteErrors {
+			if duplicateKeyErrorCodes[writeError.Code] {
+				return true
+			}
+		}
+	}
+
+	var commandError mongo.CommandError
+	if errors.As(err, &commandError) {
+		return duplicateKeyErrorCodes[int(commandError.Code)]
+	}
+
+	return false
+}
+
+// ReadDueNotifications reads pending entries whose next attempt is due, the oldest attempts go first
+func (m *MongoStorage) ReadDueNotifications(now time.Time, limit int) ([]outbox.Entry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := m.client.Database("next").Collection("outbox").Find(
+		ctx,
+		bson.M{"status": outbox.StatusPending, "nextattempt": bson.M{"$lte": now}},
+		options.Find().SetSort(bson.D{{Key: "nextattempt", Value: 1}, {Key: "createdat", Value: 1}}).SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	returnEntries := make([]outbox.Entry, 0)
+	if err := cursor.All(ctx, &returnEntries); err != nil {
+		return nil, err
+	}
+
+	return returnEntries, nil
+}
+
+// ReadDeadNotifications reads entries which ran out of delivery attempts
+func (m *MongoStorage) ReadDeadNotifications() ([]outbox.Entry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := m.client.Database("next").Collection("outbox").Find(
+		ctx,
+		bson.M{"status": outbox.StatusDead},
+		options.Find().SetSort(bson.D{{Key: "nextattempt", Value: 1}, {Key: "createdat", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	returnEntries := make([]outbox.Entry, 0)
+	if err := cursor.All(ctx, &returnEntries); err != nil {
+		return nil, err
+	}
+
+	return returnEntries, nil
+}
+
+// UpdateNotification replaces stored outbox entry with the given one
+func (m *MongoStorage) UpdateNotification(entry outbox.Entry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := m.client.Database("next").Collection("outbox").ReplaceOne(ctx, bson.M{"_id": entry.ID}, &entry)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return errors.New("no notification found")
+	}
+
+	return nil
+}
+
+// RemoveDeliveredNotifications removes entries delivered before given time, number of removed ones is returned
+func (m *MongoStorage) RemoveDeliveredNotifications(before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := m.client.Database("next").Collection("outbox").DeleteMany(
+		ctx,
+		bson.M{"status": outbox.StatusDelivered, "deliveredat": bson.M{"$lt": before}},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.DeletedCount, nil
+}
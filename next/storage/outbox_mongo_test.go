@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestStorageMongo_outboxKeepsEntriesTillDelivery(t *testing.T) {
+	assert := assert.New(t)
+
+	container, err := createContainer()
+	assert.NoError(err)
+	defer func() {
+		if err := container.stop(); err != nil {
+			panic("Cannot stop mongo container")
+		}
+	}()
+
+	strg, err := NewMongo(fmt.Sprintf("mongodb://127.0.0.1:%d", container.Port))
+	assert.NoError(err)
+
+	testOutboxKeepsEntriesTillDelivery(t, strg)
+}
+
+func TestIsDuplicateKeyError(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(isDuplicateKeyError(mongo.WriteException{
+		WriteErrors: mongo.WriteErrors{{Code: 11000, Message: "E11000 duplicate key error"}},
+	}))
+	assert.True(isDuplicateKeyError(fmt.Errorf("enqueue: %w", mongo.CommandError{Code: 11000})))
+	assert.False(isDuplicateKeyError(mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 121}}}))
+	assert.False(isDuplicateKeyError(errors.New("connection refused")))
+}
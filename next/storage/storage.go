@@ -1,6 +1,10 @@
 package storage
 
 import (
+	"time"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/history"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/outbox"
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next/subscription"
 )
@@ -11,8 +15,14 @@ type Storage interface {
 	EnableSubscription(subscription.Item) error
 	ReadAllSubscriptions() ([]subscription.Item, error)
 	ReadSubscriptions() ([]subscription.Item, error)
+	ReadSubscriptionsByArticle(shop.Item) ([]subscription.Item, error)
 	ReadSubscriptionsByShopItem(shop.Item) ([]subscription.Item, error)
 	ReadUserAllSubscriptions(subscription.User) ([]subscription.Item, error)
 	ReadUserSubscriptions(subscription.User) ([]subscription.Item, error)
 	RemoveSubscription(subscription.Item) (bool, error)
+	UpdateSubscription(subscription.Item) error
+	UpdateSubscriptionMetadata(shop.Item, time.Time) error
+
+	outbox.Store
+	history.Store
 }
@@ -3,7 +3,10 @@ package storage
 import (
 	"errors"
 	"sync"
+	"time"
 
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/history"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/outbox"
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next/subscription"
 )
@@ -11,11 +14,20 @@ import (
 // MemoryStorage describes in-memory storage for subscriptions
 type MemoryStorage struct {
 	items     map[string][]*subscription.Item
-	itemsLock sync.Locker
+	itemsLock sync.RWMutex
+
+	outbox     map[string]*outbox.Entry
+	outboxLock sync.Mutex
+
+	history     []history.Record
+	historyLock sync.Mutex
 }
 
 // ReadSubscriptions reads all subscriptions from subscription storage
 func (m *MemoryStorage) ReadSubscriptions() ([]subscription.Item, error) {
+	m.itemsLock.RLock()
+	defer m.itemsLock.RUnlock()
+
 	ret := make([]subscription.Item, 0, len(m.items))
 	for _, items := range m.items {
 		for _, item := range items {
@@ -40,10 +52,7 @@ func (m *MemoryStorage) CreateSubscription(item subscription.Item) (bool, error)
 
 	userSubscriptions := m.items[item.User.ID]
 	for _, el := range userSubscriptions {
-		// TODO (@maxim-nazarenko): consider implement Comparable on subscription.Item
-		if item.User.ID == el.User.ID &&
-			item.ShopItem.Article == el.ShopItem.Article &&
-			item.ShopItem.SizeID == el.ShopItem.SizeID {
+		if item.User.ID == el.User.ID && item.ShopItem.Equal(el.ShopItem) {
 			return false, nil
 		}
 	}
@@ -82,6 +91,9 @@ func (m *MemoryStorage) EnableSubscription(item subscription.Item) error {
 }
 
 func (m *MemoryStorage) ReadAllSubscriptions() ([]subscription.Item, error) {
+	m.itemsLock.RLock()
+	defer m.itemsLock.RUnlock()
+
 	ret := make([]subscription.Item, 0, len(m.items))
 	for _, items := range m.items {
 		for _, item := range items {
@@ -92,11 +104,33 @@ func (m *MemoryStorage) ReadAllSubscriptions() ([]subscription.Item, error) {
 	return ret, nil
 }
 
+// ReadSubscriptionsByArticle reads active subscriptions to any size of the item's article at its storefront
+func (m *MemoryStorage) ReadSubscriptionsByArticle(item shop.Item) ([]subscription.Item, error) {
+	m.itemsLock.RLock()
+	defer m.itemsLock.RUnlock()
+
+	ret := make([]subscription.Item, 0)
+	for _, items := range m.items {
+		for _, userItem := range items {
+			if userItem.Active &&
+				userItem.ShopItem.Article == item.Article &&
+				userItem.ShopItem.Storefront == item.Storefront {
+				ret = append(ret, *userItem)
+			}
+		}
+	}
+
+	return ret, nil
+}
+
 func (m *MemoryStorage) ReadSubscriptionsByShopItem(item shop.Item) ([]subscription.Item, error) {
+	m.itemsLock.RLock()
+	defer m.itemsLock.RUnlock()
+
 	ret := make([]subscription.Item, 0, len(m.items))
 	for _, items := range m.items {
 		for _, userItem := range items {
-			if userItem.Active && userItem.ShopItem.Article == item.Article && userItem.ShopItem.SizeID == item.SizeID {
+			if userItem.Active && userItem.ShopItem.Covers(item) {
 				ret = append(ret, *userItem)
 			}
 		}
@@ -106,6 +140,9 @@ func (m *MemoryStorage) ReadSubscriptionsByShopItem(item shop.Item) ([]subscript
 }
 
 func (m *MemoryStorage) ReadUserSubscriptions(user subscription.User) ([]subscription.Item, error) {
+	m.itemsLock.RLock()
+	defer m.itemsLock.RUnlock()
+
 	userItems, ok := m.items[user.ID]
 	if !ok {
 		return nil, errors.New("no such user found")
@@ -122,6 +159,9 @@ func (m *MemoryStorage) ReadUserSubscriptions(user subscription.User) ([]subscri
 }
 
 func (m *MemoryStorage) ReadUserAllSubscriptions(user subscription.User) ([]subscription.Item, error) {
+	m.itemsLock.RLock()
+	defer m.itemsLock.RUnlock()
+
 	userItems, ok := m.items[user.ID]
 	if !ok {
 		return nil, errors.New("no such user found")
@@ -145,7 +185,7 @@ func (m *MemoryStorage) RemoveSubscription(item subscription.Item) (bool, error)
 	}
 
 	for index, userItem := range userSubscriptions {
-		if userItem.ShopItem.Article == item.ShopItem.Article && userItem.ShopItem.SizeID == item.ShopItem.SizeID {
+		if userItem.ShopItem.Equal(item.ShopItem) {
 			userSubscriptions[index] = userSubscriptions[len(userSubscriptions)-1]
 			m.items[item.User.ID] = userSubscriptions[:len(userSubscriptions)-1]
 
@@ -156,6 +196,41 @@ func (m *MemoryStorage) RemoveSubscription(item subscription.Item) (bool, error)
 	return false, nil
 }
 
+// UpdateSubscription replaces stored subscription with the given one
+func (m *MemoryStorage) UpdateSubscription(item subscription.Item) error {
+	m.itemsLock.Lock()
+	defer m.itemsLock.Unlock()
+	userItem, err := m.findUserItem(item)
+
+	if err != nil {
+		return err
+	}
+
+	*userItem = item
+
+	return nil
+}
+
+// UpdateSubscriptionMetadata sets description, size name and URL of the shop item
+// in subscriptions of every user and records when they were refreshed
+func (m *MemoryStorage) UpdateSubscriptionMetadata(item shop.Item, refreshedAt time.Time) error {
+	m.itemsLock.Lock()
+	defer m.itemsLock.Unlock()
+
+	for _, userSubscriptions := range m.items {
+		for _, userItem := range userSubscriptions {
+			if userItem.ShopItem.Equal(item) {
+				userItem.ShopItem.Description = item.Description
+				userItem.ShopItem.SizeString = item.SizeString
+				userItem.ShopItem.URL = item.URL
+				userItem.MetadataRefreshedAt = refreshedAt
+			}
+		}
+	}
+
+	return nil
+}
+
 func (m *MemoryStorage) findUserItem(item subscription.Item) (*subscription.Item, error) {
 	userSubscriptions, ok := m.items[item.User.ID]
 	if !ok {
@@ -163,7 +238,7 @@ func (m *MemoryStorage) findUserItem(item subscription.Item) (*subscription.Item
 	}
 
 	for _, userItem := range userSubscriptions {
-		if userItem.ShopItem.Article == item.ShopItem.Article && userItem.ShopItem.SizeID == item.ShopItem.SizeID {
+		if userItem.ShopItem.Equal(item.ShopItem) {
 			return userItem, nil
 		}
 	}
@@ -174,7 +249,7 @@ func (m *MemoryStorage) findUserItem(item subscription.Item) (*subscription.Item
 // NewMemoryStorage constructs new instance of MemoryStorage
 func NewMemoryStorage() *MemoryStorage {
 	return &MemoryStorage{
-		items:     make(map[string][]*subscription.Item),
-		itemsLock: &sync.Mutex{},
+		items:  make(map[string][]*subscription.Item),
+		outbox: make(map[string]*outbox.Entry),
 	}
 }
@@ -1,7 +1,9 @@
 package storage
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
 
@@ -444,6 +446,18 @@ func TestStorageMemory_readSubscriptionsByShopItem(t *testing.T) {
 	)
 	assert.NoError(err)
 
+	_, err = strg.CreateSubscription(
+		subscription.Item{
+			Active: false,
+			User:   subscription.User{ID: "user-2"},
+			ShopItem: shop.Item{
+				Article: "222-222",
+				SizeID:  10,
+			},
+		},
+	)
+	assert.NoError(err)
+
 	_, err = strg.CreateSubscription(
 		subscription.Item{
 			Active: true,
@@ -582,3 +596,190 @@ func TestStorageMemory_removeSubscription(t *testing.T) {
 	assert.NoError(err)
 	assert.Equal(1, len(subscriptions))
 }
+
+func TestStorageMemory_storefrontDistinguishesSubscriptions(t *testing.T) {
+	strg := NewMemoryStorage()
+	assert := assert.New(t)
+
+	for _, storefront := range []string{"uk", "ua"} {
+		added, err := strg.CreateSubscription(
+			subscription.Item{
+				Active: true,
+				User:   subscription.User{ID: "user-1"},
+				ShopItem: shop.Item{
+					Article:    "111-222",
+					SizeID:     10,
+					Storefront: storefront,
+				},
+			},
+		)
+		assert.NoError(err)
+		assert.True(added)
+	}
+
+	subscriptions, err := strg.ReadSubscriptionsByShopItem(shop.Item{Article: "111-222", SizeID: 10, Storefront: "uk"})
+	assert.NoError(err)
+	assert.Len(subscriptions, 1)
+	assert.Equal("uk", subscriptions[0].ShopItem.Storefront)
+}
+
+func TestStorageMemory_updateSubscription(t *testing.T) {
+	strg := NewMemoryStorage()
+	assert := assert.New(t)
+
+	item := subscription.Item{
+		Active:   true,
+		User:     subscription.User{ID: "user-1"},
+		ShopItem: shop.Item{Article: "111-222", SizeID: 10},
+	}
+	_, err := strg.CreateSubscription(item)
+	assert.NoError(err)
+
+	item.LastPrice = shop.Money{Amount: 63500, Currency: "UAH"}
+	item.NotifyOnPriceDrop = true
+	assert.NoError(strg.UpdateSubscription(item))
+
+	subscriptions, err := strg.ReadSubscriptions()
+	assert.NoError(err)
+	assert.Len(subscriptions, 1)
+	assert.Equal(shop.Money{Amount: 63500, Currency: "UAH"}, subscriptions[0].LastPrice)
+	assert.True(subscriptions[0].NotifyOnPriceDrop)
+
+	err = strg.UpdateSubscription(
+		subscription.Item{
+			User:     subscription.User{ID: "user-1"},
+			ShopItem: shop.Item{Article: "111-222", SizeID: 11},
+		},
+	)
+	assert.Error(err)
+}
+
+func TestStorageMemory_updateSubscriptionMetadata(t *testing.T) {
+	strg := NewMemoryStorage()
+	assert := assert.New(t)
+
+	for _, user := range []string{"user-1", "user-2"} {
+		_, err := strg.CreateSubscription(subscription.Item{
+			Active:   true,
+			User:     subscription.User{ID: user},
+			ShopItem: shop.Item{Article: "111-222", SizeID: 10},
+		})
+		assert.NoError(err)
+	}
+	_, err := strg.CreateSubscription(subscription.Item{
+		Active:   true,
+		User:     subscription.User{ID: "user-1"},
+		ShopItem: shop.Item{Article: "111-222", SizeID: 11},
+	})
+	assert.NoError(err)
+
+	refreshedAt := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.NoError(strg.UpdateSubscriptionMetadata(
+		shop.Item{
+			Article:     "111-222",
+			SizeID:      10,
+			Description: "Pyjamas",
+			SizeString:  "3-4 years",
+			URL:         "https://next/111222",
+		},
+		refreshedAt,
+	))
+
+	subscriptions, err := strg.ReadAllSubscriptions()
+	assert.NoError(err)
+	assert.Len(subscriptions, 3)
+	for _, item := range subscriptions {
+		if item.ShopItem.SizeID != 10 {
+			assert.Empty(item.ShopItem.Description)
+			assert.True(item.MetadataRefreshedAt.IsZero())
+			continue
+		}
+		assert.Equal("Pyjamas", item.ShopItem.Description)
+		assert.Equal("3-4 years", item.ShopItem.SizeString)
+		assert.Equal("https://next/111222", item.ShopItem.URL)
+		assert.Equal(refreshedAt, item.MetadataRefreshedAt)
+	}
+}
+
+func TestStorageMemory_readSubscriptionsByShopItemMatchesSeveralSizes(t *testing.T) {
+	strg := NewMemoryStorage()
+	assert := assert.New(t)
+
+	subscriptions := []subscription.Item{
+		{Active: true, User: subscription.User{ID: "user-1"}, ShopItem: shop.Item{Article: "111-222", SizeID: 10}},
+		{Active: true, User: subscription.User{ID: "user-2"}, ShopItem: shop.Item{Article: "111-222", SizeID: 11}},
+		{Active: true, User: subscription.User{ID: "user-3"}, ShopItem: shop.Item{Article: "111-222", SizeID: shop.SizeAny}},
+		{Active: true, User: subscription.User{ID: "user-4"}, ShopItem: shop.Item{Article: "111-222", SizeID: shop.SizeAny, SizeIDs: []int{9, 10}}},
+		{Active: true, User: subscription.User{ID: "user-5"}, ShopItem: shop.Item{Article: "111-222", SizeID: shop.SizeAny, SizeIDs: []int{11, 12}}},
+	}
+	for _, item := range subscriptions {
+		added, err := strg.CreateSubscription(item)
+		assert.NoError(err)
+		assert.True(added)
+	}
+
+	found, err := strg.ReadSubscriptionsByShopItem(shop.Item{Article: "111-222", SizeID: 10})
+	assert.NoError(err)
+
+	users := make([]string, 0, len(found))
+	for _, item := range found {
+		users = append(users, item.User.ID)
+	}
+	assert.ElementsMatch([]string{"user-1", "user-3", "user-4"}, users)
+}
+
+func TestStorageMemory_readSubscriptionsByArticle(t *testing.T) {
+	strg := NewMemoryStorage()
+	assert := assert.New(t)
+
+	subscriptions := []subscription.Item{
+		{Active: true, User: subscription.User{ID: "user-1"}, ShopItem: shop.Item{Article: "111-222", SizeID: 10}},
+		{Active: false, User: subscription.User{ID: "user-2"}, ShopItem: shop.Item{Article: "111-222", SizeID: 11}},
+		{Active: true, User: subscription.User{ID: "user-3"}, ShopItem: shop.Item{Article: "111-222", SizeID: shop.SizeAny}},
+		{Active: true, User: subscription.User{ID: "user-4"}, ShopItem: shop.Item{Article: "333-444", SizeID: 10}},
+		{
+			Active:   true,
+			User:     subscription.User{ID: "user-5"},
+			ShopItem: shop.Item{Article: "111-222", SizeID: 10, Storefront: "ua"},
+		},
+	}
+	for _, item := range subscriptions {
+		added, err := strg.CreateSubscription(item)
+		assert.NoError(err)
+		assert.True(added)
+	}
+
+	found, err := strg.ReadSubscriptionsByArticle(shop.Item{Article: "111-222", SizeID: 12})
+	assert.NoError(err)
+
+	users := make([]string, 0, len(found))
+	for _, item := range found {
+		users = append(users, item.User.ID)
+	}
+	assert.ElementsMatch([]string{"user-1", "user-3"}, users)
+}
+
+func TestStorageMemory_readsWhileSubscriptionsChange(t *testing.T) {
+	strg := NewMemoryStorage()
+	item := subscription.Item{Active: true, ShopItem: shop.Item{Article: "111-222", SizeID: 10}}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			item.User = subscription.User{ID: fmt.Sprintf("user-%d", i)}
+			_, _ = strg.CreateSubscription(item)
+			_ = strg.DisableSubscription(item)
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		_, _ = strg.ReadSubscriptions()
+		_, _ = strg.ReadAllSubscriptions()
+		_, _ = strg.ReadSubscriptionsByArticle(item.ShopItem)
+		_, _ = strg.ReadSubscriptionsByShopItem(item.ShopItem)
+		_, _ = strg.ReadUserSubscriptions(subscription.User{ID: "user-1"})
+		_, _ = strg.ReadUserAllSubscriptions(subscription.User{ID: "user-1"})
+	}
+	<-done
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -25,14 +26,35 @@ type SubscriptionUser struct {
 type ShopItem struct {
 	Article     string
 	SizeID      int
+	SizeIDs     []int
+	Storefront  string
 	Description string
 	SizeString  string
 	URL         string
 }
+type Money struct {
+	Amount   int64
+	Currency string
+}
+type SizeState struct {
+	LastPrice       Money
+	StockMessage    string
+	InStockNotified bool
+}
 type SubscriptionItem struct {
-	Active   bool
-	ShopItem ShopItem
-	User     SubscriptionUser
+	Active              bool
+	ShopItem            ShopItem
+	User                SubscriptionUser
+	LastPrice           Money
+	NotifyOnPriceDrop   bool
+	TargetPrice         Money
+	StockMessage        string
+	NotifyOnStatuses    []string
+	KeepWatching        bool
+	InStockNotified     bool
+	WatchDiscontinued   bool
+	MetadataRefreshedAt time.Time
+	Sizes               map[int]SizeState
 }
 
 type MongoStorage struct {
@@ -115,13 +137,36 @@ func (m *MongoStorage) ReadUserAllSubscriptions(user subscription.User) ([]subsc
 	return returnItems, nil
 }
 
+// ReadSubscriptionsByArticle reads active subscriptions to any size of the item's article at its storefront
+func (m *MongoStorage) ReadSubscriptionsByArticle(item shop.Item) ([]subscription.Item, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := m.client.Database("next").Collection("subscriptions").Find(
+		ctx,
+		activeFilter(articleFilter(item)),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var returnItems []subscription.Item
+	err = cursor.All(ctx, &returnItems)
+	if err != nil {
+		return nil, err
+	}
+
+	return returnItems, nil
+}
+
 func (m *MongoStorage) ReadSubscriptionsByShopItem(item shop.Item) ([]subscription.Item, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	cursor, err := m.client.Database("next").Collection("subscriptions").Find(
 		ctx,
-		bson.M{"shopitem.article": item.Article, "shopitem.sizeid": item.SizeID},
+		activeFilter(coveringFilter(item)),
 	)
 
 	if err != nil {
@@ -143,11 +188,7 @@ func (m *MongoStorage) CreateSubscription(item subscription.Item) (bool, error)
 
 	res := m.client.Database("next").Collection("subscriptions").FindOne(
 		ctx,
-		bson.M{
-			"user.id":          item.User.ID,
-			"shopitem.article": item.ShopItem.Article,
-			"shopitem.sizeid":  item.ShopItem.SizeID,
-		},
+		subscriptionFilter(item),
 	)
 
 	if res.Err() == nil {
@@ -173,7 +214,7 @@ func (m *MongoStorage) DisableSubscription(item subscription.Item) error {
 
 	_, err := m.client.Database("next").Collection("subscriptions").UpdateOne(
 		ctx,
-		bson.M{"shopitem.article": item.ShopItem.Article, "shopitem.sizeid": item.ShopItem.SizeID, "user.id": item.User.ID},
+		subscriptionFilter(item),
 		bson.M{
 			"$set": bson.M{"active": false},
 		},
@@ -191,7 +232,7 @@ func (m *MongoStorage) EnableSubscription(item subscription.Item) error {
 
 	_, err := m.client.Database("next").Collection("subscriptions").UpdateOne(
 		ctx,
-		bson.M{"shopitem.article": item.ShopItem.Article, "shopitem.sizeid": item.ShopItem.SizeID, "user.id": item.User.ID},
+		subscriptionFilter(item),
 		bson.M{
 			"$set": bson.M{"active": true},
 		},
@@ -209,7 +250,7 @@ func (m *MongoStorage) RemoveSubscription(item subscription.Item) (bool, error)
 
 	_, err := m.client.Database("next").Collection("subscriptions").DeleteOne(
 		ctx,
-		bson.M{"shopitem.article": item.ShopItem.Article, "shopitem.sizeid": item.ShopItem.SizeID, "user.id": item.User.ID},
+		subscriptionFilter(item),
 	)
 	if err != nil {
 		return false, err
@@ -218,6 +259,103 @@ func (m *MongoStorage) RemoveSubscription(item subscription.Item) (bool, error)
 	return true, nil
 }
 
+func (m *MongoStorage) UpdateSubscription(item subscription.Item) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := m.client.Database("next").Collection("subscriptions").ReplaceOne(
+		ctx,
+		subscriptionFilter(item),
+		&item,
+	)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return errors.New("no subscription found")
+	}
+
+	return nil
+}
+
+// UpdateSubscriptionMetadata sets description, size name and URL of the shop item
+// in subscriptions of every user and records when they were refreshed
+func (m *MongoStorage) UpdateSubscriptionMetadata(item shop.Item, refreshedAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := m.client.Database("next").Collection("subscriptions").UpdateMany(
+		ctx,
+		shopItemFilter(item),
+		bson.M{
+			"$set": bson.M{
+				"shopitem.description": item.Description,
+				"shopitem.sizestring":  item.SizeString,
+				"shopitem.url":         item.URL,
+				"metadatarefreshedat":  refreshedAt,
+			},
+		},
+	)
+
+	return err
+}
+
+// shopItemFilter builds a filter to find subscriptions for the shop item.
+// Subscriptions created before storefronts were introduced have no storefront field at all.
+func shopItemFilter(item shop.Item) bson.M {
+	filter := articleFilter(item)
+	filter["shopitem.sizeid"] = item.SizeID
+	if item.SizeID == shop.SizeAny {
+		if len(item.SizeIDs) == 0 {
+			filter["shopitem.sizeids"] = bson.M{"$in": bson.A{nil, bson.A{}}}
+		} else {
+			filter["shopitem.sizeids"] = item.SizeIDs
+		}
+	}
+
+	return filter
+}
+
+// coveringFilter builds a filter to find subscriptions watching particular size of the article:
+// the size itself, any size or several sizes including the size
+func coveringFilter(item shop.Item) bson.M {
+	filter := articleFilter(item)
+	filter["$or"] = bson.A{
+		bson.M{"shopitem.sizeid": item.SizeID},
+		bson.M{"shopitem.sizeid": shop.SizeAny, "shopitem.sizeids": bson.M{"$in": bson.A{nil, bson.A{}}}},
+		bson.M{"shopitem.sizeid": shop.SizeAny, "shopitem.sizeids": item.SizeID},
+	}
+
+	return filter
+}
+
+func articleFilter(item shop.Item) bson.M {
+	filter := bson.M{"shopitem.article": item.Article}
+	if item.Storefront == "" {
+		filter["shopitem.storefront"] = bson.M{"$in": bson.A{"", nil}}
+	} else {
+		filter["shopitem.storefront"] = item.Storefront
+	}
+
+	return filter
+}
+
+// activeFilter narrows the filter down to active subscriptions
+func activeFilter(filter bson.M) bson.M {
+	filter["active"] = true
+
+	return filter
+}
+
+// subscriptionFilter builds a filter to find particular user's subscription
+func subscriptionFilter(item subscription.Item) bson.M {
+	filter := shopItemFilter(item.ShopItem)
+	filter["user.id"] = item.User.ID
+
+	return filter
+}
+
 func NewMongo(uri string) (*MongoStorage, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -227,6 +365,9 @@ func NewMongo(uri string) (*MongoStorage, error) {
 		return nil, err
 	}
 	mongoStorage := MongoStorage{client: client}
+	if err := mongoStorage.createHistoryIndexes(ctx); err != nil {
+		return nil, fmt.Errorf("could not create stock history indexes: %w", err)
+	}
 
 	return &mongoStorage, nil
 }
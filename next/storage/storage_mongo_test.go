@@ -582,6 +582,18 @@ func TestStorageMongo_readSubscriptionsByShopItem(t *testing.T) {
 	)
 	assert.NoError(err)
 
+	_, err = strg.CreateSubscription(
+		subscription.Item{
+			Active: false,
+			User:   subscription.User{ID: "user-2"},
+			ShopItem: shop.Item{
+				Article: "222-222",
+				SizeID:  10,
+			},
+		},
+	)
+	assert.NoError(err)
+
 	_, err = strg.CreateSubscription(
 		subscription.Item{
 			Active: true,
@@ -738,3 +750,175 @@ func TestStorageMongo_removeSubscription(t *testing.T) {
 	assert.NoError(err)
 	assert.Equal(1, len(subscriptions))
 }
+
+func TestStorageMongo_updateSubscription(t *testing.T) {
+	assert := assert.New(t)
+
+	container, err := createContainer()
+	assert.NoError(err)
+	defer func() {
+		if err := container.stop(); err != nil {
+			panic("Cannot stop mongo container")
+		}
+	}()
+	strg, err := NewMongo(fmt.Sprintf("mongodb://127.0.0.1:%d", container.Port))
+	assert.NoError(err)
+
+	item := subscription.Item{
+		Active:   true,
+		User:     subscription.User{ID: "user-1"},
+		ShopItem: shop.Item{Article: "111-222", SizeID: 10},
+	}
+	_, err = strg.CreateSubscription(item)
+	assert.NoError(err)
+
+	item.LastPrice = shop.Money{Amount: 63500, Currency: "UAH"}
+	item.NotifyOnPriceDrop = true
+	assert.NoError(strg.UpdateSubscription(item))
+
+	subscriptions, err := strg.ReadSubscriptions()
+	assert.NoError(err)
+	assert.Equal(1, len(subscriptions))
+	assert.Equal(shop.Money{Amount: 63500, Currency: "UAH"}, subscriptions[0].LastPrice)
+	assert.True(subscriptions[0].NotifyOnPriceDrop)
+
+	err = strg.UpdateSubscription(
+		subscription.Item{
+			User:     subscription.User{ID: "user-1"},
+			ShopItem: shop.Item{Article: "111-222", SizeID: 11},
+		},
+	)
+	assert.Error(err)
+}
+
+func TestStorageMongo_updateSubscriptionMetadata(t *testing.T) {
+	assert := assert.New(t)
+
+	container, err := createContainer()
+	assert.NoError(err)
+	defer func() {
+		if err := container.stop(); err != nil {
+			panic("Cannot stop mongo container")
+		}
+	}()
+	strg, err := NewMongo(fmt.Sprintf("mongodb://127.0.0.1:%d", container.Port))
+	assert.NoError(err)
+
+	for _, user := range []string{"user-1", "user-2"} {
+		_, err = strg.CreateSubscription(subscription.Item{
+			Active:   true,
+			User:     subscription.User{ID: user},
+			ShopItem: shop.Item{Article: "111-222", SizeID: 10},
+		})
+		assert.NoError(err)
+	}
+	_, err = strg.CreateSubscription(subscription.Item{
+		Active:   true,
+		User:     subscription.User{ID: "user-1"},
+		ShopItem: shop.Item{Article: "111-222", SizeID: 11},
+	})
+	assert.NoError(err)
+
+	refreshedAt := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.NoError(strg.UpdateSubscriptionMetadata(
+		shop.Item{
+			Article:     "111-222",
+			SizeID:      10,
+			Description: "Pyjamas",
+			SizeString:  "3-4 years",
+			URL:         "https://next/111222",
+		},
+		refreshedAt,
+	))
+
+	subscriptions, err := strg.ReadAllSubscriptions()
+	assert.NoError(err)
+	assert.Len(subscriptions, 3)
+	for _, item := range subscriptions {
+		if item.ShopItem.SizeID != 10 {
+			assert.Empty(item.ShopItem.Description)
+			assert.True(item.MetadataRefreshedAt.IsZero())
+			continue
+		}
+		assert.Equal("Pyjamas", item.ShopItem.Description)
+		assert.Equal("3-4 years", item.ShopItem.SizeString)
+		assert.Equal("https://next/111222", item.ShopItem.URL)
+		assert.True(refreshedAt.Equal(item.MetadataRefreshedAt))
+	}
+}
+
+func TestStorageMongo_readSubscriptionsByShopItemMatchesSeveralSizes(t *testing.T) {
+	assert := assert.New(t)
+
+	container, err := createContainer()
+	assert.NoError(err)
+	defer func() {
+		if err := container.stop(); err != nil {
+			panic("Cannot stop mongo container")
+		}
+	}()
+	strg, err := NewMongo(fmt.Sprintf("mongodb://127.0.0.1:%d", container.Port))
+	assert.NoError(err)
+
+	subscriptions := []subscription.Item{
+		{Active: true, User: subscription.User{ID: "user-1"}, ShopItem: shop.Item{Article: "111-222", SizeID: 10}},
+		{Active: true, User: subscription.User{ID: "user-2"}, ShopItem: shop.Item{Article: "111-222", SizeID: 11}},
+		{Active: true, User: subscription.User{ID: "user-3"}, ShopItem: shop.Item{Article: "111-222", SizeID: shop.SizeAny}},
+		{Active: true, User: subscription.User{ID: "user-4"}, ShopItem: shop.Item{Article: "111-222", SizeID: shop.SizeAny, SizeIDs: []int{9, 10}}},
+		{Active: true, User: subscription.User{ID: "user-5"}, ShopItem: shop.Item{Article: "111-222", SizeID: shop.SizeAny, SizeIDs: []int{11, 12}}},
+	}
+	for _, item := range subscriptions {
+		added, err := strg.CreateSubscription(item)
+		assert.NoError(err)
+		assert.True(added)
+	}
+
+	found, err := strg.ReadSubscriptionsByShopItem(shop.Item{Article: "111-222", SizeID: 10})
+	assert.NoError(err)
+
+	users := make([]string, 0, len(found))
+	for _, item := range found {
+		users = append(users, item.User.ID)
+	}
+	assert.ElementsMatch([]string{"user-1", "user-3", "user-4"}, users)
+}
+
+func TestStorageMongo_readSubscriptionsByArticle(t *testing.T) {
+	assert := assert.New(t)
+
+	container, err := createContainer()
+	assert.NoError(err)
+	defer func() {
+		if err := container.stop(); err != nil {
+			panic("Cannot stop mongo container")
+		}
+	}()
+	strg, err := NewMongo(fmt.Sprintf("mongodb://127.0.0.1:%d", container.Port))
+	assert.NoError(err)
+
+	subscriptions := []subscription.Item{
+		{Active: true, User: subscription.User{ID: "user-1"}, ShopItem: shop.Item{Article: "111-222", SizeID: 10}},
+		{Active: false, User: subscription.User{ID: "user-2"}, ShopItem: shop.Item{Article: "111-222", SizeID: 11}},
+		{Active: true, User: subscription.User{ID: "user-3"}, ShopItem: shop.Item{Article: "111-222", SizeID: shop.SizeAny}},
+		{Active: true, User: subscription.User{ID: "user-4"}, ShopItem: shop.Item{Article: "333-444", SizeID: 10}},
+		{
+			Active:   true,
+			User:     subscription.User{ID: "user-5"},
+			ShopItem: shop.Item{Article: "111-222", SizeID: 10, Storefront: "ua"},
+		},
+	}
+	for _, item := range subscriptions {
+		added, err := strg.CreateSubscription(item)
+		assert.NoError(err)
+		assert.True(added)
+	}
+
+	found, err := strg.ReadSubscriptionsByArticle(shop.Item{Article: "111-222", SizeID: 12})
+	assert.NoError(err)
+
+	users := make([]string, 0, len(found))
+	for _, item := range found {
+		users = append(users, item.User.ID)
+	}
+	assert.ElementsMatch([]string{"user-1", "user-3"}, users)
+}
@@ -0,0 +1,151 @@
+package next
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// ErrUnknownStorefront is returned when requested storefront is not configured
+var ErrUnknownStorefront = errors.New("unknown storefront")
+
+// StorefrontConfig describes a single Next storefront, e.g. next.co.uk or next.ua
+type StorefrontConfig struct {
+	// ID identifies the storefront, it matches the top-level domain of the storefront: uk, ua, de etc.
+	ID      string
+	BaseURL string
+	Lang    string
+}
+
+// Storefronts is a registry of configured storefronts, each of them served by its own Client
+type Storefronts struct {
+	clients   map[string]*Client
+	defaultID string
+}
+
+// Client returns client of the storefront, default storefront is used for empty id
+func (s *Storefronts) Client(id string) (*Client, error) {
+	client, ok := s.clients[s.Resolve(id)]
+	if !ok {
+		return nil, ErrUnknownStorefront
+	}
+
+	return client, nil
+}
+
+// Resolve returns id of the storefront which serves given id, so empty id becomes default storefront
+func (s *Storefronts) Resolve(id string) string {
+	if id == "" {
+		return s.defaultID
+	}
+
+	return id
+}
+
+// Has checks whether the storefront is configured
+func (s *Storefronts) Has(id string) bool {
+	_, ok := s.clients[id]
+
+	return ok
+}
+
+// DefaultID returns id of the default storefront
+func (s *Storefronts) DefaultID() string {
+	return s.defaultID
+}
+
+// IDs returns sorted list of configured storefronts
+func (s *Storefronts) IDs() []string {
+	ids := make([]string, 0, len(s.clients))
+	for id := range s.clients {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+// StorefrontIDFromHost derives storefront id from the host name, e.g. www.next.co.uk -> uk
+func StorefrontIDFromHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	labels := strings.Split(host, ".")
+	if len(labels) < 2 {
+		return ""
+	}
+
+	return labels[len(labels)-1]
+}
+
+// StorefrontIDFromURL derives storefront id from the storefront URL, e.g. https://www.next.ua -> ua
+func StorefrontIDFromURL(rawURL string) string {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	return StorefrontIDFromHost(parsedURL.Host)
+}
+
+// NewStorefronts creates a registry of storefronts.
+// If defaultID is empty and there is only one storefront, it becomes the default one.
+func NewStorefronts(defaultID string, clients map[string]*Client) *Storefronts {
+	if defaultID == "" && len(clients) == 1 {
+		for id := range clients {
+			defaultID = id
+		}
+	}
+
+	return &Storefronts{clients: clients, defaultID: defaultID}
+}
+
+// NewStorefrontsWithConfig creates a client for every configured storefront.
+// Common settings like timeouts and retries are taken from base config, rate limit is shared by all storefronts.
+func NewStorefrontsWithConfig(
+	httpClient HTTPClient,
+	base Config,
+	defaultID string,
+	storefronts []StorefrontConfig,
+) (*Storefronts, error) {
+	if len(storefronts) == 0 {
+		storefronts = []StorefrontConfig{
+			{ID: StorefrontIDFromURL(base.BaseURL), BaseURL: base.BaseURL, Lang: base.Lang},
+		}
+	}
+
+	// all storefronts are served by the same Next API, so they share one budget of requests
+	limiter := NewLimiter(base.RateLimit)
+	clients := make(map[string]*Client, len(storefronts))
+	for _, storefront := range storefronts {
+		if storefront.ID == "" {
+			storefront.ID = StorefrontIDFromURL(storefront.BaseURL)
+		}
+
+		if storefront.ID == "" {
+			return nil, errors.New("storefront id can't be determined for <" + storefront.BaseURL + ">")
+		}
+
+		config := base
+		config.BaseURL = storefront.BaseURL
+		config.Lang = storefront.Lang
+		clients[storefront.ID] = NewClientWithLimiter(httpClient, config, limiter)
+	}
+
+	if defaultID == "" {
+		defaultID = storefronts[0].ID
+		if defaultID == "" {
+			defaultID = StorefrontIDFromURL(storefronts[0].BaseURL)
+		}
+	}
+
+	if _, ok := clients[defaultID]; !ok {
+		return nil, errors.New("default storefront '" + defaultID + "' is not configured")
+	}
+
+	return NewStorefronts(defaultID, clients), nil
+}
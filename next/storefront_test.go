@@ -0,0 +1,109 @@
+package next
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorefrontIDFromHost(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "www.next.co.uk", expected: "uk"},
+		{input: "www.next.ua", expected: "ua"},
+		{input: "WWW.NEXT.DE", expected: "de"},
+		{input: "www.next.de:443", expected: "de"},
+		{input: "localhost", expected: ""},
+		{input: "", expected: ""},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run("Host <"+test.input+">", func(t *testing.T) {
+			assert.Equal(t, test.expected, StorefrontIDFromHost(test.input))
+		})
+	}
+}
+
+func TestNewStorefrontsWithConfig_fallsBackToClientConfig(t *testing.T) {
+	storefronts, err := NewStorefrontsWithConfig(nil, Config{BaseURL: "https://www.next.ua", Lang: "ru"}, "", nil)
+
+	assert := assert.New(t)
+	assert.NoError(err)
+	assert.Equal("ua", storefronts.DefaultID())
+	assert.Equal([]string{"ua"}, storefronts.IDs())
+
+	client, err := storefronts.Client("")
+	assert.NoError(err)
+	assert.Equal("https://www.next.ua", client.BaseURL)
+}
+
+func TestNewStorefrontsWithConfig_createsClientPerStorefront(t *testing.T) {
+	storefronts, err := NewStorefrontsWithConfig(
+		nil,
+		Config{BaseURL: "https://www.next.ua", Lang: "ru"},
+		"uk",
+		[]StorefrontConfig{
+			{ID: "ua", BaseURL: "https://www.next.ua", Lang: "ru"},
+			{BaseURL: "https://www.next.co.uk"},
+		},
+	)
+
+	assert := assert.New(t)
+	assert.NoError(err)
+	assert.Equal([]string{"ua", "uk"}, storefronts.IDs())
+
+	client, err := storefronts.Client("")
+	assert.NoError(err)
+	assert.Equal("https://www.next.co.uk", client.BaseURL)
+	assert.Equal("https://www.next.co.uk/search", client.buildEndpointURL(EndpointSearch))
+
+	client, err = storefronts.Client("ua")
+	assert.NoError(err)
+	assert.Equal("https://www.next.ua", client.BaseURL)
+
+	_, err = storefronts.Client("de")
+	assert.True(errors.Is(err, ErrUnknownStorefront))
+}
+
+func TestNewStorefrontsWithConfig_failsOnUnknownDefault(t *testing.T) {
+	_, err := NewStorefrontsWithConfig(
+		nil,
+		Config{},
+		"de",
+		[]StorefrontConfig{{ID: "ua", BaseURL: "https://www.next.ua", Lang: "ru"}},
+	)
+
+	assert.Error(t, err)
+}
+
+func TestNewStorefrontsWithConfig_sharesRateLimitBetweenStorefronts(t *testing.T) {
+	storefronts, err := NewStorefrontsWithConfig(
+		nil,
+		Config{RateLimit: RateLimitConfig{RequestsPerSecond: 1}},
+		"uk",
+		[]StorefrontConfig{
+			{ID: "ua", BaseURL: "https://www.next.ua", Lang: "ru"},
+			{ID: "uk", BaseURL: "https://www.next.co.uk"},
+		},
+	)
+
+	assert := assert.New(t)
+	assert.NoError(err)
+
+	limiters := make([]*Limiter, 0, 2)
+	for _, id := range storefronts.IDs() {
+		client, err := storefronts.Client(id)
+		assert.NoError(err)
+
+		limited, ok := client.HTTPClient.(*LimitedHTTPClient)
+		assert.True(ok)
+		limiters = append(limiters, limited.limiter)
+	}
+	assert.Len(limiters, 2)
+	assert.NotNil(limiters[0])
+	assert.Same(limiters[0], limiters[1])
+}
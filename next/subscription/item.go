@@ -1,10 +1,119 @@
 package subscription
 
-import "github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
+import (
+	"time"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
+)
 
 // Item represents a subscription item
 type Item struct {
 	Active   bool
 	User     User
 	ShopItem shop.Item
+	// LastPrice is the price seen during the last check
+	LastPrice shop.Money
+	// NotifyOnPriceDrop enables notifications when the price goes down
+	NotifyOnPriceDrop bool
+	// TargetPrice enables notification when the price falls to or below it, zero value disables it
+	TargetPrice shop.Money
+	// StockMessage is the restock estimate seen during the last check
+	StockMessage string
+	// NotifyOnStatuses lists stock statuses the subscriber wants to know about when item moves into them,
+	// becoming purchasable is always notified
+	NotifyOnStatuses []shop.StockStatus
+	// KeepWatching keeps the subscription active after in-stock notification, so every restock is notified
+	KeepWatching bool
+	// InStockNotified is set once the subscriber knows about the item in stock and cleared when it leaves stock,
+	// for multi-size subscriptions it is set while any of Sizes is
+	InStockNotified bool
+	// WatchDiscontinued keeps the subscription active even if its article appears discontinued
+	WatchDiscontinued bool
+	// MetadataRefreshedAt is when description, size name and URL of the shop item were last fetched
+	MetadataRefreshedAt time.Time
+	// Sizes holds what was seen for every size of multi-size subscription during the last check,
+	// single size subscriptions use LastPrice and StockMessage
+	Sizes map[int]SizeState
+}
+
+// SizeState holds the price and restock estimate of one size seen during the last check
+type SizeState struct {
+	LastPrice    shop.Money
+	StockMessage string
+	// InStockNotified is set once the subscriber knows about the size in stock and cleared when it leaves stock
+	InStockNotified bool
+}
+
+// Observed returns the state of the size seen during the last check
+func (i Item) Observed(size int) SizeState {
+	if i.ShopItem.SizeID != shop.SizeAny {
+		return SizeState{LastPrice: i.LastPrice, StockMessage: i.StockMessage, InStockNotified: i.InStockNotified}
+	}
+
+	return i.Sizes[size]
+}
+
+// Currency returns the currency of prices seen during the last check, it is empty if no price was seen
+func (i Item) Currency() string {
+	if i.LastPrice.Currency != "" {
+		return i.LastPrice.Currency
+	}
+
+	for _, state := range i.Sizes {
+		if state.LastPrice.Currency != "" {
+			return state.LastPrice.Currency
+		}
+	}
+
+	return ""
+}
+
+// WithObserved returns copy of the item remembering the state of the size
+func (i Item) WithObserved(size int, state SizeState) Item {
+	if i.ShopItem.SizeID != shop.SizeAny {
+		i.LastPrice = state.LastPrice
+		i.StockMessage = state.StockMessage
+		i.InStockNotified = state.InStockNotified
+
+		return i
+	}
+
+	// the map is copied, the original item may be shared
+	sizes := make(map[int]SizeState, len(i.Sizes)+1)
+	for id, s := range i.Sizes {
+		sizes[id] = s
+	}
+	sizes[size] = state
+	i.Sizes = sizes
+
+	i.InStockNotified = false
+	for _, s := range sizes {
+		i.InStockNotified = i.InStockNotified || s.InStockNotified
+	}
+
+	return i
+}
+
+// WithoutInStockNotified returns copy of the item as if the subscriber was not notified about any size in stock
+func (i Item) WithoutInStockNotified() Item {
+	for size, state := range i.Sizes {
+		if state.InStockNotified {
+			state.InStockNotified = false
+			i = i.WithObserved(size, state)
+		}
+	}
+	i.InStockNotified = false
+
+	return i
+}
+
+// WantsStatus checks whether the subscriber asked to be notified when item moves into the status
+func (i Item) WantsStatus(status shop.StockStatus) bool {
+	for _, s := range i.NotifyOnStatuses {
+		if s == status {
+			return true
+		}
+	}
+
+	return false
 }
@@ -0,0 +1,48 @@
+package subscription
+
+import (
+	"time"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
+)
+
+// NotificationKind describes the reason of a notification
+type NotificationKind string
+
+const (
+	// NotificationInStock is sent when watched item appears in stock
+	NotificationInStock NotificationKind = "in_stock"
+
+	// NotificationPriceDrop is sent when the price of watched item goes down
+	NotificationPriceDrop NotificationKind = "price_drop"
+
+	// NotificationPriceBelowTarget is sent when the price falls to or below the target price
+	NotificationPriceBelowTarget NotificationKind = "price_below_target"
+
+	// NotificationRestockDateChanged is sent when the restock estimate of coming soon item changes
+	NotificationRestockDateChanged NotificationKind = "restock_date_changed"
+
+	// NotificationStatusChanged is sent when the stock status the subscriber asked about is reached
+	NotificationStatusChanged NotificationKind = "status_changed"
+
+	// NotificationDiscontinued is sent when the subscription is disabled because its article is not found for a while
+	NotificationDiscontinued NotificationKind = "discontinued"
+)
+
+// Notification holds information to be delivered to the subscriber
+type Notification struct {
+	Kind NotificationKind
+	Item Item
+	// Size is the name of particular size the notification is about, it is set when the item watches several sizes
+	Size          string
+	Price         shop.Money
+	PreviousPrice shop.Money
+	// StockMessage and PreviousStockMessage hold restock estimates for NotificationRestockDateChanged
+	StockMessage         string
+	PreviousStockMessage string
+	// Status and PreviousStatus hold stock statuses for NotificationStatusChanged
+	Status         shop.StockStatus
+	PreviousStatus shop.StockStatus
+	// Time is when the event was observed
+	Time time.Time
+}
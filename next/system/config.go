@@ -3,6 +3,8 @@ package system
 import (
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next"
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next/bot/telegram"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/history"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/outbox"
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next/storage"
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next/watch"
 )
@@ -14,10 +16,22 @@ type Config struct {
 	Watch   watch.Config
 	Bot     telegram.Config
 	Storage storage.Config
+	Outbox  outbox.Config
+	History history.Config
+	Metrics MetricsConfig
 }
 
 type HTTPConfig struct {
-	Client next.Config
+	// Client holds settings shared by all storefronts, its BaseURL is used if no storefronts are configured
+	Client            next.Config
+	Storefronts       []next.StorefrontConfig
+	DefaultStorefront string
+}
+
+// MetricsConfig holds configuration of the metrics endpoint
+type MetricsConfig struct {
+	// Listen is an address where expvar counters are served at /debug/vars, empty value disables the endpoint
+	Listen string
 }
 
 type StorageConfig struct {
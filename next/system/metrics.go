@@ -0,0 +1,49 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log"
+	"net/http"
+	"time"
+)
+
+// metricsServer serves expvar counters, e.g. next_events and next_unknown_stock_statuses
+type metricsServer struct {
+	server *http.Server
+}
+
+func (m *metricsServer) start() {
+	log.Printf("[INFO] Serving metrics at http://%s/debug/vars\n", m.server.Addr)
+	if err := m.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Println("[ERROR] metrics: could not serve metrics: " + err.Error())
+	}
+}
+
+func (m *metricsServer) stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := m.server.Shutdown(ctx); err != nil {
+		log.Println("[ERROR] metrics: could not stop metrics server: " + err.Error())
+	}
+}
+
+// newMetricsServer creates metrics server, nil is returned if the endpoint is disabled
+func newMetricsServer(config MetricsConfig) *metricsServer {
+	if config.Listen == "" {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	return &metricsServer{
+		server: &http.Server{
+			Addr:              config.Listen,
+			Handler:           mux,
+			ReadHeaderTimeout: 5 * time.Second,
+		},
+	}
+}
@@ -0,0 +1,31 @@
+package system
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsServer_servesExpvarCounters(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(newMetricsServer(MetricsConfig{}), "empty address disables the endpoint")
+
+	// expvar panics on registering the same name twice, e.g. with -count=2
+	counter, ok := expvar.Get("test_metrics_counter").(*expvar.Int)
+	if !ok {
+		counter = expvar.NewInt("test_metrics_counter")
+	}
+	counter.Set(3)
+	metrics := newMetricsServer(MetricsConfig{Listen: "127.0.0.1:0"})
+	assert.NotNil(metrics)
+
+	recorder := httptest.NewRecorder()
+	metrics.server.Handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+
+	assert.Equal(http.StatusOK, recorder.Code)
+	assert.Contains(recorder.Body.String(), `"test_metrics_counter": 3`)
+}
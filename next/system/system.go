@@ -1,12 +1,16 @@
 package system
 
 import (
+	"context"
 	"fmt"
 	"log"
 
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next"
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next/bot/telegram"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/events"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/history"
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next/mediator"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/outbox"
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next/storage"
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next/watch"
 	"github.com/mitchellh/mapstructure"
@@ -24,11 +28,12 @@ func (s *System) StoppedCh() <-chan StopEvent {
 	return s.stoppedCh
 }
 
-// Start initializes all the components and runs the processing loop
-func (s *System) Start() error {
+// Start initializes all the components and runs the processing loop,
+// the system shuts down once the context is cancelled or Stop is called
+func (s *System) Start(ctx context.Context) error {
 	s.init()
 
-	return s.doStart()
+	return s.doStart(ctx)
 }
 
 // Stop stops and uninitialized system components
@@ -45,17 +50,17 @@ func (s *System) doStop() {
 	close(s.stopCh)
 }
 
-func newHTTPCLient(c Config) *next.Client {
-	httpClient := next.NewClient(
+func newStorefronts(c Config) (*next.Storefronts, error) {
+	return next.NewStorefrontsWithConfig(
 		nil,
 		c.HTTP.Client,
+		c.HTTP.DefaultStorefront,
+		c.HTTP.Storefronts,
 	)
-
-	return httpClient
 }
 
-func newWatcher(httpClient *next.Client, c Config) (*watch.ItemWatcher, error) {
-	w, err := watch.New(httpClient, &c.Watch)
+func newWatcher(storefronts *next.Storefronts, c Config) (*watch.ItemWatcher, error) {
+	w, err := watch.New(storefronts, &c.Watch)
 	if err != nil {
 		return nil, err
 	}
@@ -63,9 +68,13 @@ func newWatcher(httpClient *next.Client, c Config) (*watch.ItemWatcher, error) {
 	return w, nil
 }
 
-func newTelegramBot(httpClient *next.Client, mediator *mediator.SubscriptionMediator, c Config) (*telegram.Bot, error) {
+func newTelegramBot(
+	storefronts *next.Storefronts,
+	mediator *mediator.SubscriptionMediator,
+	c Config,
+) (*telegram.Bot, error) {
 	bot, err := telegram.New(
-		httpClient,
+		storefronts,
 		mediator,
 		&c.Bot,
 	)
@@ -115,42 +124,78 @@ func newStorage(storageConfig storage.Config) (storage.Storage, error) {
 	return factoryFunc()
 }
 
-func (s *System) doStart() error {
-	httpClient := newHTTPCLient(s.config)
+func (s *System) doStart(parent context.Context) error {
+	storefronts, err := newStorefronts(s.config)
+	if err != nil {
+		return err
+	}
 
-	watcher, err := newWatcher(httpClient, s.config)
+	watcher, err := newWatcher(storefronts, s.config)
 	if err != nil {
 		return err
 	}
 
-	if err := watcher.Start(); err != nil {
+	ctx, cancel := context.WithCancel(parent)
+	if err := watcher.Start(ctx); err != nil {
+		cancel()
 		return err
 	}
 
 	storage, err := newStorage(s.config.Storage)
 	if err != nil {
+		cancel()
 		return err
 	}
 
-	mediator := mediator.New(storage, watcher, httpClient)
+	bus := events.NewBus()
+	bus.Subscribe("audit", events.Audit)
+	bus.Subscribe("metrics", events.Count)
+
+	recorder := history.NewRecorder(storage, &s.config.History)
+	// a missed observation would distort stock history, so publishing waits for the recorder
+	bus.SubscribeBlocking("history", recorder.Handle)
+
+	mediator := mediator.New(storage, storage, storage, bus, watcher, storefronts)
 
-	bot, err := newTelegramBot(httpClient, mediator, s.config)
+	bot, err := newTelegramBot(storefronts, mediator, s.config)
 	if err != nil {
+		cancel()
 		return err
 	}
 
+	dispatcher := outbox.NewDispatcher(storage, bot.Deliver, bus, &s.config.Outbox)
+
 	go bot.Start()
 
 	go mediator.Start()
 
+	go dispatcher.Start()
+
+	go recorder.Start()
+
+	metrics := newMetricsServer(s.config.Metrics)
+	if metrics != nil {
+		go metrics.start()
+	}
+
 	go func() {
-		<-s.stopCh
+		select {
+		case <-s.stopCh:
+		case <-ctx.Done():
+		}
 		defer close(s.stoppedCh)
+		defer cancel()
 
 		log.Println("[INFO] Waiting for all subsystems to shut down")
 		mediator.Stop()
+		dispatcher.Stop()
 		bot.Stop()
 		watcher.Stop()
+		recorder.Stop()
+		bus.Close()
+		if metrics != nil {
+			metrics.stop()
+		}
 		log.Println("[INFO] All subsystems are shut down")
 		s.stoppedCh <- StopEvent{}
 	}()
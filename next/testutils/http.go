@@ -33,8 +33,17 @@ type MockHTTPClient struct {
 	Body io.ReadCloser
 }
 
-func (c *MockHTTPClient) Get(url string) (resp *http.Response, err error) {
-	return &http.Response{StatusCode: 200, Status: "OK", Body: c.Body}, nil
+func (c *MockHTTPClient) Do(req *http.Request) (resp *http.Response, err error) {
+	return &http.Response{StatusCode: 200, Status: "OK", Body: c.Body, Request: req}, nil
+}
+
+// MockHTTPHandlerClient delegates every request to the Handler function
+type MockHTTPHandlerClient struct {
+	Handler func(req *http.Request) (*http.Response, error)
+}
+
+func (c *MockHTTPHandlerClient) Do(req *http.Request) (*http.Response, error) {
+	return c.Handler(req)
 }
 
 func NewMockBody(payload string) *MockBody {
@@ -46,3 +55,17 @@ func NewMockBody(payload string) *MockBody {
 func NewClientWithPayload(payload string) *MockHTTPClient {
 	return &MockHTTPClient{Body: NewMockBody(payload)}
 }
+
+func NewClientWithHandler(handler func(req *http.Request) (*http.Response, error)) *MockHTTPHandlerClient {
+	return &MockHTTPHandlerClient{Handler: handler}
+}
+
+// NewResponse builds a response with given status code and payload
+func NewResponse(statusCode int, payload string) *http.Response {
+	return &http.Response{
+		StatusCode: statusCode,
+		Status:     http.StatusText(statusCode),
+		Header:     http.Header{},
+		Body:       NewMockBody(payload),
+	}
+}
@@ -1,6 +1,10 @@
 package testutils
 
-import "github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
+import (
+	"context"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
+)
 
 type MockNextClientHandlers struct {
 	GetOptionsByArticle   func(article string) ([]shop.ItemOption, error)
@@ -8,6 +12,7 @@ type MockNextClientHandlers struct {
 	GetItemExtendedOption func(article string) (shop.ItemExtendedOption, error)
 	FindOptionBySize      func(options []shop.ItemOption, size int) (shop.ItemOption, bool)
 	GetItemURLByArticle   func(article string) (string, error)
+	Search                func(query string, page int) (shop.SearchResult, error)
 }
 type MockNextClient struct {
 	Handlers MockNextClientHandlers
@@ -17,14 +22,29 @@ func (c *MockNextClient) GetOptionsByArticle(article string) ([]shop.ItemOption,
 	return c.Handlers.GetOptionsByArticle(article)
 }
 
+func (c *MockNextClient) GetOptionsByArticleContext(_ context.Context, article string) ([]shop.ItemOption, error) {
+	return c.Handlers.GetOptionsByArticle(article)
+}
+
 func (c *MockNextClient) GetItemOption(article string, size int) (shop.ItemOption, error) {
 	return c.Handlers.GetItemOption(article, size)
 }
 
+func (c *MockNextClient) GetItemOptionContext(_ context.Context, article string, size int) (shop.ItemOption, error) {
+	return c.Handlers.GetItemOption(article, size)
+}
+
 func (c *MockNextClient) GetItemExtendedOption(article string) (shop.ItemExtendedOption, error) {
 	return c.Handlers.GetItemExtendedOption(article)
 }
 
+func (c *MockNextClient) GetItemExtendedOptionContext(
+	_ context.Context,
+	article string,
+) (shop.ItemExtendedOption, error) {
+	return c.Handlers.GetItemExtendedOption(article)
+}
+
 func (c *MockNextClient) FindOptionBySize(options []shop.ItemOption, size int) (shop.ItemOption, bool) {
 	return c.Handlers.FindOptionBySize(options, size)
 }
@@ -33,6 +53,18 @@ func (c *MockNextClient) GetItemURLByArticle(article string) (string, error) {
 	return c.Handlers.GetItemURLByArticle(article)
 }
 
+func (c *MockNextClient) GetItemURLByArticleContext(_ context.Context, article string) (string, error) {
+	return c.Handlers.GetItemURLByArticle(article)
+}
+
+func (c *MockNextClient) Search(query string, page int) (shop.SearchResult, error) {
+	return c.Handlers.Search(query, page)
+}
+
+func (c *MockNextClient) SearchContext(_ context.Context, query string, page int) (shop.SearchResult, error) {
+	return c.Handlers.Search(query, page)
+}
+
 func NewMockNextClient(handlers MockNextClientHandlers) *MockNextClient {
 	return &MockNextClient{Handlers: handlers}
 }
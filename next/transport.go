@@ -0,0 +1,326 @@
+package next
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned when requests to Next API are paused by circuit breaker
+var ErrCircuitOpen = errors.New("circuit breaker is open, requests to Next API are paused")
+
+// RetryConfig holds configuration of retries for failed requests
+type RetryConfig struct {
+	// MaxAttempts is a total number of attempts per request, including the first one
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// CircuitBreakerConfig holds configuration of circuit breaker
+type CircuitBreakerConfig struct {
+	// FailureThreshold is a number of consecutive failures which opens the circuit, zero disables the breaker
+	FailureThreshold int
+	// OpenTimeout is a period when all requests are rejected before a probe request is allowed
+	OpenTimeout time.Duration
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+func (s circuitState) String() string {
+	switch s {
+	case circuitClosed:
+		return "closed"
+	case circuitOpen:
+		return "open"
+	case circuitHalfOpen:
+		return "half-open"
+	}
+
+	return "unknown"
+}
+
+// CircuitBreaker stops passing requests through after repeated failures
+type CircuitBreaker struct {
+	config        CircuitBreakerConfig
+	state         circuitState
+	failures      int
+	openedAt      time.Time
+	probeInFlight bool
+	lock          sync.Mutex
+	now           func() time.Time
+}
+
+// Allow checks whether a request may be performed
+func (b *CircuitBreaker) Allow() error {
+	if b == nil {
+		return nil
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if b.now().Sub(b.openedAt) < b.config.OpenTimeout {
+			return ErrCircuitOpen
+		}
+		b.setState(circuitHalfOpen)
+		b.probeInFlight = true
+	case circuitHalfOpen:
+		if b.probeInFlight {
+			return ErrCircuitOpen
+		}
+		b.probeInFlight = true
+	case circuitClosed:
+	}
+
+	return nil
+}
+
+// Success records successful request
+func (b *CircuitBreaker) Success() {
+	if b == nil {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.failures = 0
+	b.probeInFlight = false
+	b.setState(circuitClosed)
+}
+
+// Release frees the probe slot without recording an outcome, e.g. when the caller cancelled the request
+func (b *CircuitBreaker) Release() {
+	if b == nil {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.probeInFlight = false
+}
+
+// Failure records failed request
+func (b *CircuitBreaker) Failure() {
+	if b == nil {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.failures++
+	b.probeInFlight = false
+	if b.state == circuitHalfOpen || b.failures >= b.config.FailureThreshold {
+		b.openedAt = b.now()
+		b.setState(circuitOpen)
+	}
+}
+
+// setState changes current state and reports the change, must be called under lock
+func (b *CircuitBreaker) setState(state circuitState) {
+	if b.state == state {
+		return
+	}
+
+	log.Printf("[WARN] next: circuit breaker state changed: %s -> %s (consecutive failures: %d)\n",
+		b.state,
+		state,
+		b.failures,
+	)
+	b.state = state
+}
+
+// NewCircuitBreaker constructs new CircuitBreaker, nil is returned for disabled breaker
+func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
+	if config.FailureThreshold <= 0 {
+		return nil
+	}
+
+	return &CircuitBreaker{config: config, now: time.Now}
+}
+
+// ResilientHTTPClient retries failed requests with exponential backoff and guards Next API with circuit breaker
+type ResilientHTTPClient struct {
+	client  HTTPClient
+	retry   RetryConfig
+	breaker *CircuitBreaker
+}
+
+// Do performs the request, retrying it on network errors, 5xx and 429 responses
+func (c *ResilientHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		if err := c.breaker.Allow(); err != nil {
+			return nil, err
+		}
+
+		// timeout of the attempt itself is an ordinary failure,
+		// only the caller giving up on the request tells nothing about Next API health
+		resp, err := c.client.Do(req)
+		if req.Context().Err() != nil {
+			c.breaker.Release()
+			if err == nil {
+				err = req.Context().Err()
+			}
+			return resp, err
+		}
+		if !isRetriable(req.Context(), resp, err) {
+			c.breaker.Success()
+			return resp, err
+		}
+		c.breaker.Failure()
+
+		if attempt >= c.retry.MaxAttempts {
+			return resp, err
+		}
+
+		delay := c.backoff(attempt)
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				if retryAfter > c.retry.MaxBackoff {
+					// server asks to wait longer than we are ready to, let caller decide
+					return resp, err
+				}
+				delay = retryAfter
+			}
+			drainBody(resp.Body)
+		}
+
+		log.Printf("[INFO] next: retrying request to %s in %s (attempt %d of %d)\n",
+			req.URL.Path,
+			delay,
+			attempt+1,
+			c.retry.MaxAttempts,
+		)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff calculates exponential delay with jitter for given attempt
+func (c *ResilientHTTPClient) backoff(attempt int) time.Duration {
+	delay := c.retry.InitialBackoff
+	for i := 1; i < attempt && delay < c.retry.MaxBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > c.retry.MaxBackoff {
+		delay = c.retry.MaxBackoff
+	}
+
+	if delay <= 0 {
+		return 0
+	}
+
+	// equal jitter: keep half of the delay and randomize the rest
+	half := delay / 2
+
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1)) //nolint:gosec
+}
+
+func isRetriable(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		return ctx.Err() == nil
+	}
+
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// parseRetryAfter parses Retry-After header value in both delay-seconds and HTTP-date formats
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+
+	return 0, false
+}
+
+func drainBody(body io.ReadCloser) {
+	if body == nil {
+		return
+	}
+
+	_, _ = io.Copy(ioutil.Discard, body)
+	body.Close()
+}
+
+// TimeoutHTTPClient limits duration of every single request, reading of the response body included
+type TimeoutHTTPClient struct {
+	client  HTTPClient
+	timeout time.Duration
+}
+
+// Do performs the request within the timeout, it is cancelled once the response body is closed
+func (c *TimeoutHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), c.timeout)
+
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil || resp == nil || resp.Body == nil {
+		cancel()
+		return resp, err
+	}
+
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: cancel}
+
+	return resp, nil
+}
+
+// NewTimeoutHTTPClient wraps client with the timeout of every request
+func NewTimeoutHTTPClient(client HTTPClient, timeout time.Duration) *TimeoutHTTPClient {
+	return &TimeoutHTTPClient{client: client, timeout: timeout}
+}
+
+// NewResilientHTTPClient wraps client with retries and circuit breaker
+func NewResilientHTTPClient(client HTTPClient, retry RetryConfig, breaker CircuitBreakerConfig) *ResilientHTTPClient {
+	if retry.MaxAttempts < 1 {
+		retry.MaxAttempts = 1
+	}
+
+	if retry.MaxBackoff < retry.InitialBackoff {
+		retry.MaxBackoff = retry.InitialBackoff
+	}
+
+	return &ResilientHTTPClient{
+		client:  client,
+		retry:   retry,
+		breaker: NewCircuitBreaker(breaker),
+	}
+}
@@ -0,0 +1,173 @@
+package next
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/testutils"
+)
+
+func TestResilientHTTPClient_retriesServerErrors(t *testing.T) {
+	calls := 0
+	client := NewResilientHTTPClient(
+		testutils.NewClientWithHandler(func(req *http.Request) (*http.Response, error) {
+			calls++
+			if calls < 3 {
+				return testutils.NewResponse(http.StatusServiceUnavailable, ""), nil
+			}
+			return testutils.NewResponse(http.StatusOK, "{}"), nil
+		}),
+		RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond},
+		CircuitBreakerConfig{},
+	)
+
+	req, _ := http.NewRequest(http.MethodGet, "https://www.next.ua/ru/search", nil)
+	resp, err := client.Do(req)
+
+	assert := assert.New(t)
+	assert.NoError(err)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal(3, calls)
+}
+
+func TestResilientHTTPClient_doesNotRetryClientErrors(t *testing.T) {
+	calls := 0
+	client := NewResilientHTTPClient(
+		testutils.NewClientWithHandler(func(req *http.Request) (*http.Response, error) {
+			calls++
+			return testutils.NewResponse(http.StatusNotFound, ""), nil
+		}),
+		RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond},
+		CircuitBreakerConfig{},
+	)
+
+	req, _ := http.NewRequest(http.MethodGet, "https://www.next.ua/ru/search", nil)
+	resp, err := client.Do(req)
+
+	assert := assert.New(t)
+	assert.NoError(err)
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+	assert.Equal(1, calls)
+}
+
+func TestResilientHTTPClient_honorsRetryAfter(t *testing.T) {
+	var requestTimes []time.Time
+	client := NewResilientHTTPClient(
+		testutils.NewClientWithHandler(func(req *http.Request) (*http.Response, error) {
+			requestTimes = append(requestTimes, time.Now())
+			if len(requestTimes) == 1 {
+				resp := testutils.NewResponse(http.StatusTooManyRequests, "")
+				resp.Header.Set("Retry-After", "1")
+				return resp, nil
+			}
+			return testutils.NewResponse(http.StatusOK, "{}"), nil
+		}),
+		RetryConfig{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Second},
+		CircuitBreakerConfig{},
+	)
+
+	req, _ := http.NewRequest(http.MethodGet, "https://www.next.ua/ru/search", nil)
+	_, err := client.Do(req)
+
+	assert := assert.New(t)
+	assert.NoError(err)
+	assert.Len(requestTimes, 2)
+	assert.True(requestTimes[1].Sub(requestTimes[0]) >= time.Second)
+}
+
+func TestResilientHTTPClient_circuitBreakerRejectsRequestsWhenOpen(t *testing.T) {
+	calls := 0
+	client := NewResilientHTTPClient(
+		testutils.NewClientWithHandler(func(req *http.Request) (*http.Response, error) {
+			calls++
+			return nil, errors.New("connection refused")
+		}),
+		RetryConfig{MaxAttempts: 1},
+		CircuitBreakerConfig{FailureThreshold: 2, OpenTimeout: time.Hour},
+	)
+
+	req, _ := http.NewRequest(http.MethodGet, "https://www.next.ua/ru/search", nil)
+
+	assert := assert.New(t)
+	for i := 0; i < 2; i++ {
+		_, err := client.Do(req) //nolint:bodyclose
+		assert.Error(err)
+	}
+
+	_, err := client.Do(req) //nolint:bodyclose
+	assert.True(errors.Is(err, ErrCircuitOpen))
+	assert.Equal(2, calls)
+}
+
+func TestCircuitBreaker_allowsProbeAfterOpenTimeout(t *testing.T) {
+	now := time.Now()
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute})
+	breaker.now = func() time.Time { return now }
+
+	assert := assert.New(t)
+	assert.NoError(breaker.Allow())
+	breaker.Failure()
+	assert.Equal(ErrCircuitOpen, breaker.Allow())
+
+	now = now.Add(time.Minute)
+	assert.NoError(breaker.Allow())
+	assert.Equal(ErrCircuitOpen, breaker.Allow(), "only one probe request is allowed in half-open state")
+
+	breaker.Success()
+	assert.NoError(breaker.Allow())
+}
+
+func TestResilientHTTPClient_cancelledRequestDoesNotCloseHalfOpenCircuit(t *testing.T) {
+	now := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	client := NewResilientHTTPClient(
+		testutils.NewClientWithHandler(func(req *http.Request) (*http.Response, error) {
+			cancel()
+			return nil, req.Context().Err()
+		}),
+		RetryConfig{MaxAttempts: 1},
+		CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute},
+	)
+	client.breaker.now = func() time.Time { return now }
+	client.breaker.Failure()
+	now = now.Add(time.Minute)
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://www.next.ua/ru/search", nil)
+	_, err := client.Do(req) //nolint:bodyclose
+
+	assert := assert.New(t)
+	assert.True(errors.Is(err, context.Canceled))
+	assert.Equal(circuitHalfOpen, client.breaker.state, "cancelled probe is neither success nor failure")
+	assert.NoError(client.breaker.Allow(), "probe slot is released")
+}
+
+func TestResilientHTTPClient_retriesTimedOutAttemptsAndOpensCircuit(t *testing.T) {
+	attempts := 0
+	client := NewClient(
+		testutils.NewClientWithHandler(func(req *http.Request) (*http.Response, error) {
+			attempts++
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		}),
+		Config{
+			BaseURL:        "https://www.next.ua",
+			RequestTimeout: 5 * time.Millisecond,
+			Retry:          RetryConfig{MaxAttempts: 3},
+			CircuitBreaker: CircuitBreakerConfig{FailureThreshold: 3, OpenTimeout: time.Minute},
+		},
+	)
+	assert := assert.New(t)
+
+	_, err := client.GetItemExtendedOptionContext(context.Background(), "821585")
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(3, attempts, "hanging API is retried")
+
+	_, err = client.GetItemExtendedOptionContext(context.Background(), "821585")
+	assert.True(errors.Is(err, ErrCircuitOpen))
+	assert.Equal(3, attempts)
+}
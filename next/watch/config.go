@@ -2,9 +2,35 @@ package watch
 
 import (
 	"time"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/schedule"
 )
 
+// defaultNotFoundThreshold is used when number of not found results before an article is considered
+// discontinued is not configured
+const defaultNotFoundThreshold = 3
+
+// defaultConcurrency is used when number of concurrent checks is not configured
+const defaultConcurrency = 4
+
 // Config holds configuration for item watcher
 type Config struct {
+	// UpdateInterval is the usual polling interval of an article
 	UpdateInterval time.Duration
+	// MinInterval is the fastest polling pace used for recently changed articles, defaults to UpdateInterval
+	MinInterval time.Duration
+	// MaxInterval is the slowest polling pace used for long sold out and failing articles,
+	// defaults to ten times UpdateInterval
+	MaxInterval time.Duration
+	// Concurrency limits number of articles checked at the same time
+	Concurrency int
+	// Jitter spreads checks of one tick randomly over this duration instead of firing them all at once,
+	// zero disables spreading. It should not exceed UpdateInterval.
+	Jitter time.Duration
+	// NotFoundThreshold is how many consecutive checks must not find an article before it is considered discontinued
+	NotFoundThreshold int
+	// TimeZone is IANA name of the time zone used for scheduling, e.g. "Europe/London", local one by default
+	TimeZone string
+	// PollingWindow limits checks to part of the day, e.g. when Next restocks; checks run all day if not set
+	PollingWindow schedule.WindowConfig
 }
@@ -0,0 +1,157 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/testutils"
+)
+
+func TestWatcherStopWaitsForInFlightChecks(t *testing.T) {
+	var running int32
+	release := make(chan struct{})
+	w, err := New(
+		newStorefronts(next.NewClient(
+			testutils.NewClientWithHandler(func(req *http.Request) (*http.Response, error) {
+				atomic.AddInt32(&running, 1)
+				<-release
+
+				return testutils.NewResponse(http.StatusOK, `{"Options": [{"OptionNumber": "10", "StockStatus": "InStock"}]}`), nil
+			}),
+			next.Config{BaseURL: "https://www.next.ua"},
+		)),
+		&Config{UpdateInterval: time.Hour},
+	)
+	assert := assert.New(t)
+	assert.NoError(err)
+	assert.NoError(w.AddItem(shop.Item{Article: "821585", SizeID: 10, Storefront: "ua"}, "user-1"))
+	assert.NoError(w.Start(context.Background()))
+
+	w.Run()
+	assert.Eventually(func() bool {
+		return atomic.LoadInt32(&running) == 1
+	}, 2*time.Second, 5*time.Millisecond)
+
+	stopped := make(chan struct{})
+	go func() {
+		w.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		t.Fatal("watcher stopped before in-flight check finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("watcher did not stop")
+	}
+
+	transition, ok := <-w.TransitionsChan()
+	assert.True(ok)
+	assert.Equal(shop.ItemStatusInStock, transition.To)
+	_, ok = <-w.TransitionsChan()
+	assert.False(ok, "channel is closed after stop")
+	_, ok = <-w.ObservationsChan()
+	assert.True(ok)
+	_, ok = <-w.ObservationsChan()
+	assert.False(ok, "channel is closed after stop")
+}
+
+func TestWatcherStopsWhenContextIsDone(t *testing.T) {
+	w, err := New(newStorefronts(nil), &Config{UpdateInterval: time.Hour})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	assert.NoError(t, w.Start(ctx))
+	cancel()
+
+	select {
+	case _, ok := <-w.TransitionsChan():
+		assert.False(t, ok)
+	case <-time.After(2 * time.Second):
+		t.Fatal("watcher did not stop")
+	}
+
+	w.Stop()
+	w.Run()
+	assert.Error(t, w.Start(context.Background()), "stopped watcher can not be started again")
+}
+
+func TestWatcherConcurrentAddRemoveTickStop(t *testing.T) {
+	w, err := New(
+		newStorefronts(next.NewClient(
+			testutils.NewClientWithHandler(func(req *http.Request) (*http.Response, error) {
+				return testutils.NewResponse(http.StatusOK, `{"Options": [
+					{"OptionNumber": "10", "StockStatus": "InStock", "Price": "635 грн"},
+					{"OptionNumber": "11", "StockStatus": "SoldOut", "Price": "635 грн"}
+				]}`), nil
+			}),
+			next.Config{BaseURL: "https://www.next.ua"},
+		)),
+		&Config{UpdateInterval: time.Millisecond, Concurrency: 3, Jitter: time.Millisecond},
+	)
+	assert := assert.New(t)
+	assert.NoError(err)
+	assert.NoError(w.Start(context.Background()))
+
+	consumed := make(chan struct{})
+	go func() {
+		defer close(consumed)
+		transitions, observations := w.TransitionsChan(), w.ObservationsChan()
+		for transitions != nil || observations != nil {
+			select {
+			case _, ok := <-transitions:
+				if !ok {
+					transitions = nil
+				}
+			case _, ok := <-observations:
+				if !ok {
+					observations = nil
+				}
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				item := shop.Item{Article: fmt.Sprintf("%06d", j%5), SizeID: 10 + i%2, Storefront: "ua"}
+				subscriber := fmt.Sprintf("user-%d", i)
+				assert.NoError(w.AddItem(item, subscriber))
+				w.Run()
+				w.WatchedItems()
+				w.PollInterval(item)
+				if j%3 == 0 {
+					w.RemoveItem(item, subscriber)
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	w.Stop()
+	w.Run()
+
+	select {
+	case <-consumed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("channels are not closed after stop")
+	}
+}
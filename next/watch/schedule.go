@@ -0,0 +1,322 @@
+package watch
+
+import (
+	"log"
+	"time"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
+)
+
+const (
+	// recentChangePeriod is how long an article is polled at the fastest pace after its status changed
+	recentChangePeriod = 30 * time.Minute
+
+	// restockApproachPeriod is how long before and after the restock estimate coming soon article is polled faster
+	restockApproachPeriod = 7 * 24 * time.Hour
+
+	// longSoldOutPeriod is how long an article stays sold out before it is polled at the slowest pace
+	longSoldOutPeriod = 24 * time.Hour
+
+	// defaultMaxIntervalFactor defines default MaxInterval as a multiple of UpdateInterval
+	defaultMaxIntervalFactor = 10
+)
+
+// pollSchedule holds polling state of a single article
+type pollSchedule struct {
+	Interval     time.Duration
+	LastCheck    time.Time
+	LastChange   time.Time
+	SoldOutSince time.Time
+	ComingSoon   bool
+	Failures     int
+	NotFound     int
+	// RestockETA is the earliest restock estimate of coming soon sizes, zero if it is unknown
+	RestockETA time.Time
+}
+
+// NextCheck tells when the article should be checked next time
+func (s pollSchedule) NextCheck() time.Time {
+	return s.LastCheck.Add(s.Interval)
+}
+
+// intervalPolicy decides how often an article is polled
+type intervalPolicy struct {
+	Base time.Duration
+	Min  time.Duration
+	Max  time.Duration
+}
+
+func newIntervalPolicy(config *Config) intervalPolicy {
+	policy := intervalPolicy{
+		Base: config.UpdateInterval,
+		Min:  config.MinInterval,
+		Max:  config.MaxInterval,
+	}
+
+	if policy.Min <= 0 || policy.Min > policy.Base {
+		policy.Min = policy.Base
+	}
+	if policy.Max < policy.Base {
+		policy.Max = policy.Base * defaultMaxIntervalFactor
+	}
+
+	return policy
+}
+
+// interval calculates polling interval of the article:
+// failing articles are slowed down exponentially, recently changed ones are polled at the fastest pace,
+// coming soon ones faster as their restock estimate approaches and articles sold out for a long time
+// at the slowest pace.
+func (p intervalPolicy) interval(s pollSchedule, now time.Time) time.Duration {
+	interval := p.Base
+
+	switch {
+	case s.Failures > 0:
+		for i := 0; i < s.Failures && interval < p.Max; i++ {
+			interval *= 2
+		}
+	case !s.LastChange.IsZero() && now.Sub(s.LastChange) < recentChangePeriod:
+		interval = p.Min
+	case s.ComingSoon:
+		interval = p.comingSoonInterval(s.RestockETA, now)
+	case !s.SoldOutSince.IsZero() && now.Sub(s.SoldOutSince) > longSoldOutPeriod:
+		interval = p.Max
+	}
+
+	if interval < p.Min {
+		interval = p.Min
+	}
+	if interval > p.Max {
+		interval = p.Max
+	}
+
+	return interval
+}
+
+// comingSoonInterval shrinks in proportion to how close the restock estimate is, either ahead or just passed.
+// Articles with unknown estimate are polled twice as often as usual.
+func (p intervalPolicy) comingSoonInterval(eta, now time.Time) time.Duration {
+	if eta.IsZero() {
+		return p.Base / 2
+	}
+
+	distance := eta.Sub(now)
+	if distance < 0 {
+		distance = -distance
+	}
+	if distance >= restockApproachPeriod {
+		return p.Base
+	}
+
+	return time.Duration(float64(p.Base) * float64(distance) / float64(restockApproachPeriod))
+}
+
+// tick is how often the watcher looks for articles due to be checked
+func (p intervalPolicy) tick() time.Duration {
+	return p.Min
+}
+
+// isDue checks whether the article should be checked at the moment, must be called under itemsLock.
+// Half of a tick is tolerated, so an article is not postponed by a whole tick because of the check duration.
+func (w *ItemWatcher) isDue(key articleKey, now time.Time) bool {
+	schedule, ok := w.schedules[key]
+	if !ok {
+		return true
+	}
+
+	return !now.Before(schedule.NextCheck().Add(-w.policy.tick() / 2))
+}
+
+// markChecked remembers when the check of the article was scheduled, must be called under itemsLock
+func (w *ItemWatcher) markChecked(key articleKey, now time.Time) {
+	schedule := w.schedule(key)
+	schedule.LastCheck = now
+}
+
+// schedule returns polling state of the article creating it if needed, must be called under itemsLock
+func (w *ItemWatcher) schedule(key articleKey) *pollSchedule {
+	schedule, ok := w.schedules[key]
+	if !ok {
+		schedule = &pollSchedule{Interval: w.policy.Base, LastCheck: time.Now()}
+		w.schedules[key] = schedule
+	}
+
+	return schedule
+}
+
+// recordCheck updates polling state of the article after successful check
+func (w *ItemWatcher) recordCheck(key articleKey, observations []Observation, transitions []Transition) {
+	w.itemsLock.Lock()
+	defer w.itemsLock.Unlock()
+
+	if !w.watched(key) {
+		return
+	}
+
+	now := time.Now()
+	schedule := w.schedule(key)
+	schedule.Failures = 0
+	schedule.NotFound = 0
+
+	for _, transition := range transitions {
+		if transition.From != shop.ItemStatusUnknown {
+			schedule.LastChange = transition.Time
+		}
+	}
+
+	soldOut := len(observations) > 0
+	schedule.ComingSoon = false
+	schedule.RestockETA = time.Time{}
+	for _, observation := range observations {
+		switch observation.Option.StockStatus {
+		case shop.ItemStatusSoldOut:
+		case shop.ItemStatusComingSoon:
+			schedule.ComingSoon = true
+			soldOut = false
+			eta, ok := shop.RestockEstimate(observation.Option.StockMessage, now)
+			if ok && (schedule.RestockETA.IsZero() || eta.Before(schedule.RestockETA)) {
+				schedule.RestockETA = eta
+			}
+		default:
+			soldOut = false
+		}
+	}
+
+	if !soldOut {
+		schedule.SoldOutSince = time.Time{}
+	} else if schedule.SoldOutSince.IsZero() {
+		schedule.SoldOutSince = now
+	}
+
+	schedule.Interval = w.policy.interval(*schedule, now)
+}
+
+// recordFailure slows down polling of the article which could not be checked
+func (w *ItemWatcher) recordFailure(key articleKey) {
+	w.itemsLock.Lock()
+	defer w.itemsLock.Unlock()
+
+	if !w.watched(key) {
+		return
+	}
+
+	schedule := w.schedule(key)
+	schedule.Failures++
+	schedule.Interval = w.policy.interval(*schedule, time.Now())
+}
+
+// recordNotFound counts consecutive not found results of the article.
+// Once the threshold is reached, watched sizes of the article move into shop.ItemStatusDiscontinued,
+// the article is still checked at the slowest pace in case it comes back.
+func (w *ItemWatcher) recordNotFound(key articleKey) []Transition {
+	w.itemsLock.Lock()
+	defer w.itemsLock.Unlock()
+
+	if !w.watched(key) {
+		return nil
+	}
+
+	now := time.Now()
+	schedule := w.schedule(key)
+	schedule.NotFound++
+	schedule.Failures++
+	schedule.Interval = w.policy.interval(*schedule, now)
+
+	if schedule.NotFound < w.notFoundLimit {
+		log.Printf("[WARN] watcher: article %s is not found %d time(s) in a row\n", key.Article, schedule.NotFound)
+		return nil
+	}
+
+	var transitions []Transition
+	for itemKey, watched := range w.items {
+		if itemKey.Article != key.Article || itemKey.Storefront != key.Storefront {
+			continue
+		}
+
+		previous, known := w.statuses[itemKey]
+		if !known {
+			previous = shop.ItemStatusUnknown
+		}
+		if previous == shop.ItemStatusDiscontinued {
+			continue
+		}
+
+		w.statuses[itemKey] = shop.ItemStatusDiscontinued
+		transitions = append(transitions, Transition{
+			Item: watched.item,
+			From: previous,
+			To:   shop.ItemStatusDiscontinued,
+			Time: now,
+		})
+	}
+
+	if len(transitions) > 0 {
+		log.Printf("[WARN] watcher: article %s is not found %d times in a row, it appears discontinued\n",
+			key.Article,
+			schedule.NotFound,
+		)
+	}
+
+	return transitions
+}
+
+// watched checks whether any size of the article is watched, must be called under itemsLock
+func (w *ItemWatcher) watched(key articleKey) bool {
+	for itemKey := range w.items {
+		if itemKey.Article == key.Article && itemKey.Storefront == key.Storefront {
+			return true
+		}
+	}
+
+	return false
+}
+
+// forgetStatuses drops known statuses of the article's sizes which are no longer watched by any item,
+// must be called under itemsLock
+func (w *ItemWatcher) forgetStatuses(key articleKey) {
+	for statusKey := range w.statuses {
+		if statusKey.Article != key.Article || statusKey.Storefront != key.Storefront {
+			continue
+		}
+		if _, ok := w.items[statusKey]; ok {
+			continue
+		}
+
+		covered := false
+		if statusKey.SizeID != shop.SizeAny {
+			for _, watched := range w.items {
+				if watched.item.Covers(shop.Item{Storefront: key.Storefront, Article: key.Article, SizeID: statusKey.SizeID}) {
+					covered = true
+					break
+				}
+			}
+		}
+		if !covered {
+			delete(w.statuses, statusKey)
+		}
+	}
+}
+
+// forgetSchedule drops polling state of the article unless it is still watched, must be called under itemsLock
+func (w *ItemWatcher) forgetSchedule(key articleKey) {
+	if !w.watched(key) {
+		delete(w.schedules, key)
+	}
+}
+
+// PollInterval returns current polling interval of the item
+func (w *ItemWatcher) PollInterval(item shop.Item) (time.Duration, bool) {
+	w.itemsLock.Lock()
+	defer w.itemsLock.Unlock()
+
+	schedule, ok := w.schedules[articleKey{Storefront: item.Storefront, Article: item.Article}]
+	if !ok {
+		if !w.watched(articleKey{Storefront: item.Storefront, Article: item.Article}) {
+			return 0, false
+		}
+
+		return w.policy.Base, true
+	}
+
+	return schedule.Interval, true
+}
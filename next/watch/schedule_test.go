@@ -0,0 +1,151 @@
+package watch
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/testutils"
+)
+
+func TestIntervalPolicy(t *testing.T) {
+	now := time.Now()
+	policy := newIntervalPolicy(&Config{
+		UpdateInterval: 4 * time.Minute,
+		MinInterval:    time.Minute,
+		MaxInterval:    time.Hour,
+	})
+
+	tests := map[string]struct {
+		schedule pollSchedule
+		expected time.Duration
+	}{
+		"usual":            {pollSchedule{}, 4 * time.Minute},
+		"recently changed": {pollSchedule{LastChange: now.Add(-time.Minute)}, time.Minute},
+		"changed long ago": {pollSchedule{LastChange: now.Add(-time.Hour)}, 4 * time.Minute},
+		"coming soon":      {pollSchedule{ComingSoon: true}, 2 * time.Minute},
+		"coming soon in a month": {
+			pollSchedule{ComingSoon: true, RestockETA: now.Add(30 * 24 * time.Hour)},
+			4 * time.Minute,
+		},
+		"coming soon in three days": {
+			pollSchedule{ComingSoon: true, RestockETA: now.Add(84 * time.Hour)},
+			2 * time.Minute,
+		},
+		"coming soon tomorrow": {
+			pollSchedule{ComingSoon: true, RestockETA: now.Add(24 * time.Hour)},
+			time.Minute,
+		},
+		"coming soon overdue": {
+			pollSchedule{ComingSoon: true, RestockETA: now.Add(-84 * time.Hour)},
+			2 * time.Minute,
+		},
+		"sold out recently": {pollSchedule{SoldOutSince: now.Add(-time.Hour)}, 4 * time.Minute},
+		"sold out long ago": {pollSchedule{SoldOutSince: now.Add(-48 * time.Hour)}, time.Hour},
+		"failed once":       {pollSchedule{Failures: 1}, 8 * time.Minute},
+		"failed three times": {
+			pollSchedule{Failures: 3, LastChange: now},
+			32 * time.Minute,
+		},
+		"failing constantly": {pollSchedule{Failures: 100}, time.Hour},
+	}
+
+	for name, test := range tests {
+		assert.Equal(t, test.expected, policy.interval(test.schedule, now), name)
+	}
+}
+
+func TestNewIntervalPolicy_defaults(t *testing.T) {
+	policy := newIntervalPolicy(&Config{UpdateInterval: time.Minute})
+
+	assert.Equal(t, intervalPolicy{Base: time.Minute, Min: time.Minute, Max: 10 * time.Minute}, policy)
+}
+
+func TestWatcherSlowsDownFailingArticles(t *testing.T) {
+	w, err := New(
+		newStorefronts(next.NewClient(
+			testutils.NewClientWithHandler(func(req *http.Request) (*http.Response, error) {
+				return testutils.NewResponse(http.StatusInternalServerError, ""), nil
+			}),
+			next.Config{BaseURL: "https://www.next.ua"},
+		)),
+		&Config{UpdateInterval: time.Minute},
+	)
+	assert := assert.New(t)
+	assert.NoError(err)
+	defer w.Stop()
+
+	item := shop.Item{Article: "821585", SizeID: 10, Storefront: "ua"}
+	assert.NoError(w.AddItem(item, "user-1"))
+
+	jobs := w.scheduleChecks()
+	assert.Len(jobs, 1)
+	w.checkArticle(jobs[0].key, jobs[0].items)
+	w.finishChecks(jobs...)
+
+	interval, ok := w.PollInterval(item)
+	assert.True(ok)
+	assert.Equal(2*time.Minute, interval)
+	assert.Len(w.scheduleChecks(), 0, "the article is not due yet")
+
+	_, ok = w.PollInterval(shop.Item{Article: "111222", SizeID: 10, Storefront: "ua"})
+	assert.False(ok)
+}
+
+func TestWatcherDoesNotSlowDownArticlesWaitingForLimiter(t *testing.T) {
+	w, err := New(newStorefronts(next.NewClient(testutils.NewClientWithPayload(""), next.Config{})), &Config{
+		UpdateInterval: time.Minute,
+	})
+	assert := assert.New(t)
+	assert.NoError(err)
+	defer w.Stop()
+
+	item := shop.Item{Article: "821585", SizeID: 10, Storefront: "ua"}
+	assert.NoError(w.AddItem(item, "user-1"))
+	jobs := w.scheduleChecks()
+	assert.Len(jobs, 1)
+
+	w.handleCheckError(jobs[0].key, &next.APIError{
+		Op:      "get extended options",
+		Article: item.Article,
+		Err:     &next.TransportError{Err: &next.LimiterError{Err: context.DeadlineExceeded}},
+	})
+
+	interval, ok := w.PollInterval(item)
+	assert.True(ok)
+	assert.Equal(time.Minute, interval)
+}
+
+func TestWatcherTakesEarliestRestockEstimate(t *testing.T) {
+	w, err := New(newStorefronts(next.NewClient(testutils.NewClientWithPayload(""), next.Config{})), &Config{
+		UpdateInterval: time.Minute,
+	})
+	assert := assert.New(t)
+	assert.NoError(err)
+	defer w.Stop()
+
+	item := shop.NewMultiSizeItem("821585")
+	item.Storefront = "ua"
+	assert.NoError(w.AddItem(item, "user-1"))
+	jobs := w.scheduleChecks()
+	assert.Len(jobs, 1)
+
+	comingSoon := func(message string) shop.ItemOption {
+		return shop.ItemOption{StockStatus: shop.ItemStatusComingSoon, StockMessage: message}
+	}
+	soon := time.Now().Month()
+	later := soon%12 + 1
+	w.recordCheck(jobs[0].key, []Observation{
+		{Item: item.WithSize(10), Option: comingSoon("???")},
+		{Item: item.WithSize(11), Option: comingSoon(later.String())},
+		{Item: item.WithSize(12), Option: comingSoon(soon.String())},
+	}, nil)
+
+	expected, _ := shop.RestockEstimate(soon.String(), time.Now())
+	assert.Equal(expected, w.schedules[jobs[0].key].RestockETA)
+}
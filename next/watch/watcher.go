@@ -1,47 +1,210 @@
 package watch
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"math/rand"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next"
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/schedule"
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
 	"github.com/robfig/cron/v3"
 )
 
+// gracefulStopTimeout is how long Stop waits for in-flight checks before cancelling them
+const gracefulStopTimeout = 10 * time.Second
+
+// defaultRateLimitPause is used when Next asks to slow down without telling for how long
+const defaultRateLimitPause = time.Minute
+
 // Watcher interface to be implemented by different watchers
 type Watcher interface {
-	AddItem(*shop.Item) error
-	InStockChan() <-chan shop.Item
-	RemoveItem(shop.Item)
-	Start() error
+	AddItem(item shop.Item, subscriberID string) error
+	ObservationsChan() <-chan Observation
+	TransitionsChan() <-chan Transition
+	PollInterval(shop.Item) (time.Duration, bool)
+	RemoveItem(item shop.Item, subscriberID string)
+	WatchedItems() []WatchedItem
+	Start(ctx context.Context) error
 	Stop()
 }
 
+// Observation holds a result of a single check of watched item
+type Observation struct {
+	Item   shop.Item
+	Option shop.ItemOption
+	Time   time.Time
+}
+
+// Transition describes a change of item's stock status.
+// The first check of an item produces a transition from shop.ItemStatusUnknown.
+type Transition struct {
+	Item   shop.Item
+	Option shop.ItemOption
+	From   shop.StockStatus
+	To     shop.StockStatus
+	Price  shop.Money
+	Time   time.Time
+}
+
+// IntoPurchasable checks whether the item became available for purchase
+func (t Transition) IntoPurchasable() bool {
+	return !t.From.Purchasable() && t.To.Purchasable()
+}
+
+// WatchedItem describes watched item along with its subscribers
+type WatchedItem struct {
+	Item        shop.Item
+	Subscribers []string
+}
+
+// watchedItem is an entry of the watch list, the item is watched while it has subscribers
+type watchedItem struct {
+	item        shop.Item
+	subscribers map[string]bool
+}
+
+// itemKey identifies watched size (or set of sizes) of an article at particular storefront
+type itemKey struct {
+	Storefront string
+	Article    string
+	SizeID     int
+	SizeIDs    string
+}
+
+func newItemKey(item shop.Item) itemKey {
+	key := itemKey{Storefront: item.Storefront, Article: item.Article, SizeID: item.SizeID}
+	if len(item.SizeIDs) > 0 {
+		key.SizeIDs = fmt.Sprint(item.SizeIDs)
+	}
+
+	return key
+}
+
+// articleKey identifies an article at particular storefront, it is a unit of polling
+type articleKey struct {
+	Storefront string
+	Article    string
+}
+
+// checkJob is a scheduled check of one article
+type checkJob struct {
+	key   articleKey
+	items []shop.Item
+	delay time.Duration
+}
+
 // ItemWatcher holds information about items to watch after
 type ItemWatcher struct {
-	Client         *next.Client
+	Storefronts    *next.Storefronts
 	UpdateInterval time.Duration
+	Jitter         time.Duration
+	policy         intervalPolicy
+	window         *schedule.Window
+	schedules      map[articleKey]*pollSchedule
 	cron           *cron.Cron
-	items          []*shop.Item
+	items          map[itemKey]*watchedItem
 	itemsLock      sync.Locker
-	inStockChan    chan shop.Item
+	inFlight       map[articleKey]bool
+	jobs           chan checkJob
+	rnd            *rand.Rand
+	statuses       map[itemKey]shop.StockStatus
+	transitions    chan Transition
+	observations   chan Observation
+	pausedUntil    time.Time
+	concurrency    int
+	notFoundLimit  int
+	// stopped is set under itemsLock once no new checks may be started
+	stopped  bool
+	quit     chan struct{}
+	running  sync.WaitGroup
+	stopOnce sync.Once
+	ctx      context.Context
+	cancel   context.CancelFunc
 }
 
-// Start begins watcher's loop of checks
-func (w *ItemWatcher) Start() error {
+// Start begins watcher's loop of checks, the watcher is stopped when ctx is done
+func (w *ItemWatcher) Start(ctx context.Context) error {
+	for i := 0; i < w.concurrency; i++ {
+		if !w.track() {
+			return errors.New("watcher is stopped")
+		}
+		go w.worker()
+	}
+
 	w.cron.Start()
 
+	go func() {
+		select {
+		case <-ctx.Done():
+			w.Stop()
+		case <-w.quit:
+		}
+	}()
+
 	return nil
 }
 
-// Stop terminates periodic checking
+// Stop terminates periodic checking and waits for in-flight checks to finish.
+// Checks which do not finish in time are cancelled. Channels of the watcher are closed afterwards.
 func (w *ItemWatcher) Stop() {
-	log.Println("[INFO] Stopping web watcher")
+	w.stopOnce.Do(func() {
+		log.Println("[INFO] Stopping web watcher")
+
+		<-w.cron.Stop().Done()
+
+		w.itemsLock.Lock()
+		w.stopped = true
+		w.itemsLock.Unlock()
+		close(w.quit)
+
+		if !waitTimeout(&w.running, gracefulStopTimeout) {
+			log.Println("[WARN] watcher: in-flight checks did not finish in time, cancelling them")
+			w.cancel()
+			w.running.Wait()
+		}
+		w.cancel()
+
+		close(w.transitions)
+		close(w.observations)
+	})
+}
 
-	defer close(w.inStockChan)
-	w.cron.Stop()
+// track registers a goroutine to be waited for on stop, false is returned if the watcher is stopped
+func (w *ItemWatcher) track() bool {
+	w.itemsLock.Lock()
+	defer w.itemsLock.Unlock()
+
+	if w.stopped {
+		return false
+	}
+	w.running.Add(1)
+
+	return true
+}
+
+// waitTimeout waits for the group, false is returned if it takes longer than timeout
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
 }
 
 // Run satisfies cron.Job interface
@@ -51,68 +214,406 @@ func (w *ItemWatcher) Run() {
 
 func (w *ItemWatcher) onTimer() {
 	log.Println("ItemWatcher timer fired")
-	for _, item := range w.items {
-		go func(item shop.Item) {
-			shopItemInfo, err := w.Client.GetItemOption(item.Article, item.SizeID)
-			if err != nil {
-				log.Println("[ERROR] + " + err.Error())
-				return
+	if until, paused := w.pausedTill(); paused {
+		log.Printf("[INFO] watcher: checks are paused till %s\n", until.Format(time.RFC3339))
+		return
+	}
+
+	if now := time.Now(); !w.window.Contains(now) {
+		log.Printf("[DEBUG] watcher: outside of polling window %s, next checks at %s\n",
+			w.window,
+			w.window.NextStart(now).Format(time.RFC3339),
+		)
+		return
+	}
+
+	jobs := w.scheduleChecks()
+	if len(jobs) == 0 {
+		return
+	}
+
+	if !w.track() {
+		w.finishChecks(jobs...)
+		return
+	}
+	go w.dispatch(jobs)
+}
+
+// scheduleChecks plans checks of the articles which are not being checked at the moment
+func (w *ItemWatcher) scheduleChecks() []checkJob {
+	groups := w.itemsByArticle()
+	now := time.Now()
+
+	w.itemsLock.Lock()
+	defer w.itemsLock.Unlock()
+
+	jobs := make([]checkJob, 0, len(groups))
+	for key, items := range groups {
+		if !w.isDue(key, now) {
+			continue
+		}
+		if w.inFlight[key] {
+			log.Printf("[DEBUG] watcher: previous check of %s is still running, skipping\n", key.Article)
+			continue
+		}
+		w.inFlight[key] = true
+		w.markChecked(key, now)
+
+		var delay time.Duration
+		if w.Jitter > 0 {
+			delay = time.Duration(w.rnd.Int63n(int64(w.Jitter)))
+		}
+		jobs = append(jobs, checkJob{key: key, items: items, delay: delay})
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].delay < jobs[j].delay
+	})
+
+	return jobs
+}
+
+// dispatch hands scheduled checks over to workers at their time
+func (w *ItemWatcher) dispatch(jobs []checkJob) {
+	defer w.running.Done()
+
+	start := time.Now()
+	for index, job := range jobs {
+		timer := time.NewTimer(time.Until(start.Add(job.delay)))
+		select {
+		case <-timer.C:
+		case <-w.quit:
+			timer.Stop()
+			w.finishChecks(jobs[index:]...)
+			return
+		}
+
+		select {
+		case w.jobs <- job:
+		case <-w.quit:
+			w.finishChecks(jobs[index:]...)
+			return
+		}
+	}
+}
+
+// worker performs checks one by one until the watcher is stopped
+func (w *ItemWatcher) worker() {
+	defer w.running.Done()
+
+	for {
+		select {
+		case job := <-w.jobs:
+			w.checkArticle(job.key, job.items)
+			w.finishChecks(job)
+		case <-w.quit:
+			return
+		}
+	}
+}
+
+func (w *ItemWatcher) finishChecks(jobs ...checkJob) {
+	w.itemsLock.Lock()
+	defer w.itemsLock.Unlock()
+
+	for _, job := range jobs {
+		delete(w.inFlight, job.key)
+	}
+}
+
+// itemsByArticle groups watched items by storefront and article, so every article is requested only once per tick
+func (w *ItemWatcher) itemsByArticle() map[articleKey][]shop.Item {
+	w.itemsLock.Lock()
+	defer w.itemsLock.Unlock()
+
+	groups := make(map[articleKey][]shop.Item)
+	for _, watched := range w.items {
+		key := articleKey{Storefront: watched.item.Storefront, Article: watched.item.Article}
+		groups[key] = append(groups[key], watched.item)
+	}
+
+	return groups
+}
+
+// checkArticle fetches options of the article and fans them out to every watched item of that article
+func (w *ItemWatcher) checkArticle(key articleKey, items []shop.Item) {
+	client, err := w.Storefronts.Client(key.Storefront)
+	if err != nil {
+		log.Printf("[ERROR] watcher: storefront '%s' of article %s: %s\n", key.Storefront, key.Article, err.Error())
+		return
+	}
+
+	extendedOptions, err := client.GetItemExtendedOptionContext(w.ctx, key.Article)
+	if err != nil {
+		w.handleCheckError(key, err)
+		return
+	}
+
+	now := time.Now()
+	observations := make([]Observation, 0, len(items))
+	observed := make(map[int]bool, len(items))
+	observe := func(item shop.Item, option shop.ItemOption) {
+		option.Article = item.Article
+		if !observed[item.SizeID] {
+			observed[item.SizeID] = true
+			observations = append(observations, Observation{Item: item, Option: option, Time: now})
+		}
+	}
+
+	for _, item := range items {
+		if item.SizeID == shop.SizeAny {
+			// any or several sizes are watched, every covered size is observed on its own
+			for _, option := range extendedOptions.Options {
+				if item.CoversSize(option.Number) {
+					observe(item.WithSize(option.Number), option)
+				}
 			}
+			continue
+		}
+
+		option, found := client.FindOptionBySize(extendedOptions.Options, item.SizeID)
+		if !found {
+			log.Printf("[WARN] watcher: size %d not found for article %s\n", item.SizeID, key.Article)
+			continue
+		}
 
-			w.processInStockItems(shopItemInfo)
-		}(*item)
+		observe(item, option)
+	}
+
+	transitions := w.detectTransitions(observations)
+	w.recordCheck(key, observations, transitions)
+	w.publishObservations(observations...)
+	w.publishTransitions(transitions...)
+}
+
+// handleCheckError reacts on failed check depending on the error kind
+func (w *ItemWatcher) handleCheckError(key articleKey, err error) {
+	var rateLimitErr *next.RateLimitError
+	var limiterErr *next.LimiterError
+
+	switch {
+	case errors.Is(err, next.ErrItemNotFound):
+		w.publishTransitions(w.recordNotFound(key)...)
+	case errors.As(err, &rateLimitErr):
+		w.pause(rateLimitErr.RetryAfter)
+	case errors.Is(err, next.ErrRateLimited):
+		w.pause(0)
+	case errors.Is(err, next.ErrCircuitOpen), errors.Is(err, context.Canceled), errors.As(err, &limiterErr):
+		// the check was given up before Next answered, it tells nothing about the article
+		log.Printf("[DEBUG] watcher: check of %s skipped: %s\n", key.Article, err.Error())
+	default:
+		log.Println("[ERROR] watcher: " + err.Error())
+		w.recordFailure(key)
+	}
+}
+
+// pause suspends all checks for given duration
+func (w *ItemWatcher) pause(d time.Duration) {
+	if d <= 0 {
+		d = defaultRateLimitPause
+	}
+
+	w.itemsLock.Lock()
+	defer w.itemsLock.Unlock()
+
+	until := time.Now().Add(d)
+	if until.After(w.pausedUntil) {
+		log.Printf("[WARN] watcher: rate limited by Next, pausing checks for %s\n", d)
+		w.pausedUntil = until
 	}
 }
 
-// AddItem add given item to the list of watched items
-func (w *ItemWatcher) AddItem(item *shop.Item) error {
-	w.items = append(w.items, item)
+func (w *ItemWatcher) pausedTill() (time.Time, bool) {
+	w.itemsLock.Lock()
+	defer w.itemsLock.Unlock()
+
+	return w.pausedUntil, time.Now().Before(w.pausedUntil)
+}
+
+// AddItem adds the subscriber of given item to the watch list, the item is checked once regardless of
+// number of its subscribers
+func (w *ItemWatcher) AddItem(item shop.Item, subscriberID string) error {
+	w.itemsLock.Lock()
+	defer w.itemsLock.Unlock()
+
+	key := newItemKey(item)
+	watched, ok := w.items[key]
+	if !ok {
+		watched = &watchedItem{item: item, subscribers: make(map[string]bool)}
+		w.items[key] = watched
+	}
+	watched.subscribers[subscriberID] = true
 
 	return nil
 }
 
-// RemoveItem removes watching item from the list so it will not be processed next time when cron fires
-func (w *ItemWatcher) RemoveItem(item shop.Item) {
-	log.Printf("[DEBUG] watcher: removing item %v\n", item)
+// RemoveItem removes the subscriber of given item from the watch list,
+// the item will not be processed next time when cron fires once its last subscriber is gone
+func (w *ItemWatcher) RemoveItem(item shop.Item, subscriberID string) {
+	log.Printf("[DEBUG] watcher: removing subscriber <%s> of item %v\n", subscriberID, item)
 	w.itemsLock.Lock()
 	defer w.itemsLock.Unlock()
-	for index, it := range w.items {
-		if item.Article == it.Article && item.SizeID == it.SizeID {
-			w.items = append(w.items[:index], w.items[index+1:]...)
+
+	key := newItemKey(item)
+	watched, ok := w.items[key]
+	if !ok {
+		return
+	}
+
+	delete(watched.subscribers, subscriberID)
+	if len(watched.subscribers) > 0 {
+		return
+	}
+
+	delete(w.items, key)
+	w.forgetStatuses(articleKey{Storefront: item.Storefront, Article: item.Article})
+	w.forgetSchedule(articleKey{Storefront: item.Storefront, Article: item.Article})
+}
+
+// WatchedItems lists watched items with their subscribers
+func (w *ItemWatcher) WatchedItems() []WatchedItem {
+	w.itemsLock.Lock()
+	defer w.itemsLock.Unlock()
+
+	items := make([]WatchedItem, 0, len(w.items))
+	for _, watched := range w.items {
+		subscribers := make([]string, 0, len(watched.subscribers))
+		for subscriberID := range watched.subscribers {
+			subscribers = append(subscribers, subscriberID)
+		}
+		sort.Strings(subscribers)
+
+		items = append(items, WatchedItem{Item: watched.item, Subscribers: subscribers})
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		a, b := items[i].Item, items[j].Item
+		if a.Storefront != b.Storefront {
+			return a.Storefront < b.Storefront
+		}
+		if a.Article != b.Article {
+			return a.Article < b.Article
+		}
+
+		return a.SizeID < b.SizeID
+	})
+
+	return items
+}
+
+// ObservationsChan returns channel where results of every check will appear
+func (w *ItemWatcher) ObservationsChan() <-chan Observation {
+	return w.observations
+}
+
+func (w *ItemWatcher) publishObservations(observations ...Observation) {
+	for _, observation := range observations {
+		select {
+		case w.observations <- observation:
+		case <-w.ctx.Done():
 			return
 		}
 	}
 }
 
-// InStockChan returns channel where InStock items will appear
-func (w ItemWatcher) InStockChan() <-chan shop.Item {
-	return w.inStockChan
+// TransitionsChan returns channel where changes of items' stock statuses will appear
+func (w *ItemWatcher) TransitionsChan() <-chan Transition {
+	return w.transitions
 }
 
-func (w *ItemWatcher) processInStockItems(items ...shop.ItemOption) {
+// detectTransitions compares observed statuses with the last known ones and remembers the new ones
+func (w *ItemWatcher) detectTransitions(observations []Observation) []Transition {
 	w.itemsLock.Lock()
 	defer w.itemsLock.Unlock()
-	for _, item := range items {
-		if item.StockStatusString != shop.ItemStatusInStock {
+
+	transitions := make([]Transition, 0, len(observations))
+	for _, observation := range observations {
+		key := newItemKey(observation.Item)
+		previous, known := w.statuses[key]
+		if !known {
+			previous = shop.ItemStatusUnknown
+		}
+
+		current := observation.Option.StockStatus
+		if current == previous {
 			continue
 		}
-		w.inStockChan <- shop.Item{Article: item.Article, SizeID: item.Number}
+		w.statuses[key] = current
+
+		price, _ := observation.Option.ParsedPrice()
+		transitions = append(transitions, Transition{
+			Item:   observation.Item,
+			Option: observation.Option,
+			From:   previous,
+			To:     current,
+			Price:  price,
+			Time:   observation.Time,
+		})
+	}
+
+	return transitions
+}
+
+func (w *ItemWatcher) publishTransitions(transitions ...Transition) {
+	for _, transition := range transitions {
+		log.Printf("[DEBUG] watcher: %s/%s size %d: %s -> %s\n",
+			transition.Item.Storefront,
+			transition.Item.Article,
+			transition.Item.SizeID,
+			transition.From,
+			transition.To,
+		)
+
+		select {
+		case w.transitions <- transition:
+		case <-w.ctx.Done():
+			return
+		}
 	}
 }
 
 // New constructs new ItemWatcher instance
-func New(client *next.Client, config *Config) (*ItemWatcher, error) {
-	// TODO: add TZ support
+func New(storefronts *next.Storefronts, config *Config) (*ItemWatcher, error) {
+	location, err := schedule.LoadLocation(config.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+
+	window, err := schedule.NewWindow(config.PollingWindow, location)
+	if err != nil {
+		return nil, err
+	}
+
 	watcher := ItemWatcher{
-		Client:         client,
+		Storefronts:    storefronts,
 		UpdateInterval: config.UpdateInterval,
-		cron:           cron.New(),
+		Jitter:         config.Jitter,
+		policy:         newIntervalPolicy(config),
+		window:         window,
+		schedules:      make(map[articleKey]*pollSchedule),
+		cron:           cron.New(cron.WithLocation(location)),
+		items:          make(map[itemKey]*watchedItem),
 		itemsLock:      &sync.Mutex{},
+		inFlight:       make(map[articleKey]bool),
+		jobs:           make(chan checkJob),
+		quit:           make(chan struct{}),
+		concurrency:    config.Concurrency,
+		notFoundLimit:  config.NotFoundThreshold,
+		rnd:            rand.New(rand.NewSource(time.Now().UnixNano())),
+		statuses:       make(map[itemKey]shop.StockStatus),
+	}
+	watcher.ctx, watcher.cancel = context.WithCancel(context.Background())
+	if watcher.concurrency <= 0 {
+		watcher.concurrency = defaultConcurrency
+	}
+	if watcher.notFoundLimit <= 0 {
+		watcher.notFoundLimit = defaultNotFoundThreshold
 	}
 
-	watcher.inStockChan = make(chan shop.Item, 20)
-	interval := "@every " + watcher.UpdateInterval.String()
-	_, err := watcher.cron.AddJob(interval, &watcher)
+	watcher.transitions = make(chan Transition, 20)
+	watcher.observations = make(chan Observation, 100)
+	interval := "@every " + watcher.policy.tick().String()
+	_, err = watcher.cron.AddJob(interval, &watcher)
 	if err != nil {
 		return nil, err
 	}
@@ -1,18 +1,22 @@
 package watch
 
 import (
+	"context"
+	"net/http"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/maxim-nazarenko/nextshop-item-watcher/next/schedule"
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next/shop"
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next/testutils"
 
 	"github.com/maxim-nazarenko/nextshop-item-watcher/next"
 )
 
-func TestWatcherPassesInStockItemsToChannel(t *testing.T) {
+func TestWatcherPublishesTransitionOfInStockItem(t *testing.T) {
 	payload := `
 	{
 		"Description": "Розовая в цветочек - Теплая пижама",
@@ -51,30 +55,382 @@ func TestWatcherPassesInStockItemsToChannel(t *testing.T) {
 	}`
 
 	w, err := New(
-		next.NewClient(
+		newStorefronts(next.NewClient(
 			testutils.NewClientWithPayload(payload),
 			next.Config{
 				BaseURL: "https://www.next.ua",
 				Lang:    "ru",
 			},
-		),
+		)),
 		&Config{UpdateInterval: 10 * time.Millisecond},
 	)
 
 	assert.NoError(t, err)
 
-	err = w.AddItem(&shop.Item{Article: "821-585", SizeID: 11})
+	err = w.AddItem(shop.Item{Article: "821-585", SizeID: 11}, "user-1")
 	assert.NoError(t, err)
 
+	assert.NoError(t, w.Start(context.Background()))
+	defer w.Stop()
+
 	w.Run()
-	defer func() {
-		w.Stop()
-	}()
 
 	select {
-	case <-w.InStockChan():
-		return
+	case transition := <-w.TransitionsChan():
+		assert.Equal(t, shop.ItemStatusUnknown, transition.From)
+		assert.Equal(t, shop.ItemStatusInStock, transition.To)
+		assert.Equal(t, shop.Money{Amount: 63500, Currency: "UAH"}, transition.Price)
+		assert.True(t, transition.IntoPurchasable())
 	case <-time.After(2 * time.Second):
 		t.Error("No items received")
 	}
 }
+
+func TestWatcherRequestsEveryArticleOncePerTick(t *testing.T) {
+	payload := `
+	{
+		"Description": "Розовая в цветочек - Теплая пижама",
+		"Options": [
+			{
+				"OptionNumber": "10",
+				"StockStatus": "ComingSoon",
+				"OptionName": "EU XS стандартный",
+				"Price": "635 грн"
+			},
+			{
+				"OptionNumber": "11",
+				"StockStatus": "InStock",
+				"OptionName": "EU S стандартный",
+				"Price": "635 грн"
+			}
+		]
+	}`
+
+	var requests int32
+	w, err := New(
+		newStorefronts(next.NewClient(
+			testutils.NewClientWithHandler(func(req *http.Request) (*http.Response, error) {
+				atomic.AddInt32(&requests, 1)
+				return testutils.NewResponse(http.StatusOK, payload), nil
+			}),
+			next.Config{
+				BaseURL: "https://www.next.ua",
+				Lang:    "ru",
+			},
+		)),
+		&Config{UpdateInterval: time.Hour},
+	)
+	assert := assert.New(t)
+	assert.NoError(err)
+
+	assert.NoError(w.AddItem(shop.Item{Article: "821585", SizeID: 10}, "user-1"))
+	assert.NoError(w.AddItem(shop.Item{Article: "821585", SizeID: 11}, "user-1"))
+	assert.NoError(w.AddItem(shop.Item{Article: "821585", SizeID: 11}, "user-2"))
+
+	assert.NoError(w.Start(context.Background()))
+	defer w.Stop()
+	w.Run()
+
+	statuses := make(map[int]shop.StockStatus)
+	for i := 0; i < 2; i++ {
+		select {
+		case transition := <-w.TransitionsChan():
+			statuses[transition.Item.SizeID] = transition.To
+		case <-time.After(2 * time.Second):
+			t.Fatal("No items received")
+		}
+	}
+
+	assert.Equal(map[int]shop.StockStatus{10: shop.ItemStatusComingSoon, 11: shop.ItemStatusInStock}, statuses)
+	assert.Equal(int32(1), atomic.LoadInt32(&requests))
+	assert.Len(w.ObservationsChan(), 2, "one observation per watched size is expected")
+}
+
+func TestWatcherReportsDiscontinuedArticles(t *testing.T) {
+	w, err := New(
+		newStorefronts(next.NewClient(
+			testutils.NewClientWithHandler(func(req *http.Request) (*http.Response, error) {
+				return testutils.NewResponse(http.StatusNotFound, ""), nil
+			}),
+			next.Config{
+				BaseURL: "https://www.next.ua",
+				Lang:    "ru",
+			},
+		)),
+		&Config{UpdateInterval: time.Hour, NotFoundThreshold: 2},
+	)
+	assert := assert.New(t)
+	assert.NoError(err)
+	defer w.Stop()
+
+	item := shop.Item{Article: "821585", SizeID: 10}
+	assert.NoError(w.AddItem(item, "user-1"))
+	assert.NoError(w.AddItem(shop.Item{Article: "111222", SizeID: 10}, "user-1"))
+
+	check := func() {
+		w.checkArticle(articleKey{Article: "821585"}, []shop.Item{item})
+	}
+
+	check()
+	assert.Len(w.TransitionsChan(), 0, "a single miss is tolerated")
+
+	check()
+	check()
+	assert.Len(w.TransitionsChan(), 1, "discontinued article is reported once")
+	transition := <-w.TransitionsChan()
+	assert.Equal(item, transition.Item)
+	assert.Equal(shop.ItemStatusUnknown, transition.From)
+	assert.Equal(shop.ItemStatusDiscontinued, transition.To)
+
+	assert.Len(w.itemsByArticle(), 2, "discontinued article is still watched")
+	interval, _ := w.PollInterval(item)
+	assert.Equal(8*time.Hour, interval, "checks of discontinued article are slowed down")
+}
+
+func TestWatcherPausesChecksWhenRateLimited(t *testing.T) {
+	w, err := New(
+		newStorefronts(next.NewClient(
+			testutils.NewClientWithHandler(func(req *http.Request) (*http.Response, error) {
+				resp := testutils.NewResponse(http.StatusTooManyRequests, "")
+				resp.Header.Set("Retry-After", "60")
+				return resp, nil
+			}),
+			next.Config{
+				BaseURL: "https://www.next.ua",
+				Lang:    "ru",
+			},
+		)),
+		&Config{UpdateInterval: time.Hour},
+	)
+	assert := assert.New(t)
+	assert.NoError(err)
+	defer w.Stop()
+
+	w.checkArticle(articleKey{Article: "821585"}, []shop.Item{{Article: "821585", SizeID: 10}})
+
+	until, paused := w.pausedTill()
+	assert.True(paused)
+	assert.True(until.After(time.Now().Add(59 * time.Second)))
+}
+
+func TestWatcherPublishesOnlyStatusChanges(t *testing.T) {
+	statuses := []string{"SoldOut", "SoldOut", "ComingSoon", "InStock"}
+	var requests int32
+	w, err := New(
+		newStorefronts(next.NewClient(
+			testutils.NewClientWithHandler(func(req *http.Request) (*http.Response, error) {
+				status := statuses[atomic.AddInt32(&requests, 1)-1]
+				return testutils.NewResponse(
+					http.StatusOK,
+					`{"Options": [{"OptionNumber": "10", "StockStatus": "`+status+`", "Price": "£20"}]}`,
+				), nil
+			}),
+			next.Config{BaseURL: "https://www.next.co.uk"},
+		)),
+		&Config{UpdateInterval: time.Hour},
+	)
+	assert := assert.New(t)
+	assert.NoError(err)
+	defer w.Stop()
+
+	item := shop.Item{Article: "821585", SizeID: 10, Storefront: "ua"}
+	assert.NoError(w.AddItem(item, "user-1"))
+
+	for range statuses {
+		w.checkArticle(articleKey{Storefront: "ua", Article: "821585"}, []shop.Item{item})
+	}
+
+	assert.Len(w.TransitionsChan(), 3)
+	for _, expected := range [][2]shop.StockStatus{
+		{shop.ItemStatusUnknown, shop.ItemStatusSoldOut},
+		{shop.ItemStatusSoldOut, shop.ItemStatusComingSoon},
+		{shop.ItemStatusComingSoon, shop.ItemStatusInStock},
+	} {
+		transition := <-w.TransitionsChan()
+		assert.Equal(expected, [2]shop.StockStatus{transition.From, transition.To})
+	}
+}
+
+func TestWatcherObservesEveryCoveredSizeOfMultiSizeItems(t *testing.T) {
+	w, err := New(
+		newStorefronts(next.NewClient(
+			testutils.NewClientWithPayload(`{"Options": [
+				{"OptionNumber": "10", "StockStatus": "SoldOut", "Price": "£20"},
+				{"OptionNumber": "11", "StockStatus": "InStock", "Price": "£20"},
+				{"OptionNumber": "12", "StockStatus": "InStock", "Price": "£20"}
+			]}`),
+			next.Config{BaseURL: "https://www.next.co.uk"},
+		)),
+		&Config{UpdateInterval: time.Hour},
+	)
+	assert := assert.New(t)
+	assert.NoError(err)
+	defer w.Stop()
+
+	anySize := shop.NewMultiSizeItem("821585")
+	anySize.Storefront = "ua"
+	severalSizes := shop.NewMultiSizeItem("821585", 10, 11)
+	severalSizes.Storefront = "ua"
+	assert.NoError(w.AddItem(anySize, "user-1"))
+	assert.NoError(w.AddItem(severalSizes, "user-2"))
+	assert.Len(w.WatchedItems(), 2)
+
+	w.checkArticle(articleKey{Storefront: "ua", Article: "821585"}, []shop.Item{anySize, severalSizes})
+
+	assert.Len(w.ObservationsChan(), 3)
+	assert.Len(w.TransitionsChan(), 3)
+	sizes := make(map[int]shop.StockStatus)
+	for i := 0; i < 3; i++ {
+		transition := <-w.TransitionsChan()
+		assert.NotEqual(shop.SizeAny, transition.Item.SizeID)
+		assert.Equal(transition.Item.SizeID, transition.Option.Number)
+		sizes[transition.Item.SizeID] = transition.To
+	}
+	assert.Equal(map[int]shop.StockStatus{
+		10: shop.ItemStatusSoldOut,
+		11: shop.ItemStatusInStock,
+		12: shop.ItemStatusInStock,
+	}, sizes)
+
+	// sizes still covered by several sizes item keep their statuses
+	w.RemoveItem(anySize, "user-1")
+	w.itemsLock.Lock()
+	assert.Len(w.statuses, 2)
+	w.itemsLock.Unlock()
+}
+
+func TestWatcherLimitsConcurrentChecksAndSkipsRunningOnes(t *testing.T) {
+	var requests, running, maxRunning int32
+	release := make(chan struct{})
+	w, err := New(
+		newStorefronts(next.NewClient(
+			testutils.NewClientWithHandler(func(req *http.Request) (*http.Response, error) {
+				atomic.AddInt32(&requests, 1)
+				current := atomic.AddInt32(&running, 1)
+				defer atomic.AddInt32(&running, -1)
+				for {
+					max := atomic.LoadInt32(&maxRunning)
+					if current <= max || atomic.CompareAndSwapInt32(&maxRunning, max, current) {
+						break
+					}
+				}
+				<-release
+
+				return testutils.NewResponse(http.StatusOK, `{"Options": [{"OptionNumber": "10", "StockStatus": "SoldOut"}]}`), nil
+			}),
+			next.Config{BaseURL: "https://www.next.ua"},
+		)),
+		&Config{UpdateInterval: time.Hour, Concurrency: 2},
+	)
+	assert := assert.New(t)
+	assert.NoError(err)
+	defer w.Stop()
+
+	for _, article := range []string{"111111", "222222", "333333"} {
+		assert.NoError(w.AddItem(shop.Item{Article: article, SizeID: 10}, "user-1"))
+	}
+
+	assert.NoError(w.Start(context.Background()))
+	w.Run()
+	assert.Eventually(func() bool {
+		return atomic.LoadInt32(&running) == 2
+	}, 2*time.Second, 5*time.Millisecond)
+
+	// every article is either being checked or waiting for a worker
+	w.Run()
+	close(release)
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-w.TransitionsChan():
+		case <-time.After(2 * time.Second):
+			t.Fatal("No items received")
+		}
+	}
+	assert.Equal(int32(3), atomic.LoadInt32(&requests))
+	assert.Equal(int32(2), atomic.LoadInt32(&maxRunning))
+}
+
+func TestWatcherSpreadsChecksOverJitter(t *testing.T) {
+	w, err := New(newStorefronts(nil), &Config{UpdateInterval: time.Hour, Jitter: time.Minute})
+	assert := assert.New(t)
+	assert.NoError(err)
+	defer w.Stop()
+
+	for _, article := range []string{"111111", "222222", "333333"} {
+		assert.NoError(w.AddItem(shop.Item{Article: article, SizeID: 10}, "user-1"))
+	}
+
+	jobs := w.scheduleChecks()
+	assert.Len(jobs, 3)
+	for index, job := range jobs {
+		assert.True(job.delay >= 0 && job.delay < time.Minute)
+		if index > 0 {
+			assert.True(jobs[index-1].delay <= job.delay, "checks are dispatched in order of their delays")
+		}
+	}
+
+	assert.Len(w.scheduleChecks(), 0, "scheduled checks are not planned twice")
+}
+
+func TestWatcherSkipsChecksOutsideOfPollingWindow(t *testing.T) {
+	now := time.Now().UTC()
+	w, err := New(newStorefronts(nil), &Config{
+		UpdateInterval: time.Hour,
+		TimeZone:       "UTC",
+		PollingWindow: schedule.WindowConfig{
+			Start: now.Add(time.Hour).Format("15:04"),
+			End:   now.Add(2 * time.Hour).Format("15:04"),
+		},
+	})
+	assert := assert.New(t)
+	assert.NoError(err)
+	defer w.Stop()
+
+	assert.NoError(w.AddItem(shop.Item{Article: "821585", SizeID: 10}, "user-1"))
+	w.Run()
+
+	assert.Len(w.inFlight, 0)
+	assert.Len(w.scheduleChecks(), 1, "the article is still due")
+}
+
+func TestNewWatcher_validatesTimeSettings(t *testing.T) {
+	_, err := New(nil, &Config{UpdateInterval: time.Hour, TimeZone: "Mars/Olympus"})
+	assert.Error(t, err)
+
+	_, err = New(nil, &Config{UpdateInterval: time.Hour, PollingWindow: schedule.WindowConfig{Start: "06:00"}})
+	assert.Error(t, err)
+}
+
+func TestWatcherTracksSubscribersOfItems(t *testing.T) {
+	w, err := New(newStorefronts(nil), &Config{UpdateInterval: time.Hour})
+	assert := assert.New(t)
+	assert.NoError(err)
+	defer w.Stop()
+
+	small := shop.Item{Article: "821585", SizeID: 10, Storefront: "ua"}
+	large := shop.Item{Article: "821585", SizeID: 12, Storefront: "ua"}
+	assert.NoError(w.AddItem(small, "user-1"))
+	assert.NoError(w.AddItem(small, "user-2"))
+	assert.NoError(w.AddItem(small, "user-2"))
+	assert.NoError(w.AddItem(large, "user-1"))
+
+	assert.Equal([]WatchedItem{
+		{Item: small, Subscribers: []string{"user-1", "user-2"}},
+		{Item: large, Subscribers: []string{"user-1"}},
+	}, w.WatchedItems())
+
+	w.RemoveItem(small, "user-2")
+	w.RemoveItem(large, "user-1")
+	assert.Equal([]WatchedItem{{Item: small, Subscribers: []string{"user-1"}}}, w.WatchedItems())
+
+	w.RemoveItem(small, "user-3")
+	assert.Len(w.WatchedItems(), 1, "unknown subscriber does not affect the item")
+
+	w.RemoveItem(small, "user-1")
+	assert.Len(w.WatchedItems(), 0)
+}
+
+func newStorefronts(client *next.Client) *next.Storefronts {
+	return next.NewStorefronts("ua", map[string]*next.Client{"ua": client})
+}